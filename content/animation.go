@@ -0,0 +1,276 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"image"
+	"log"
+	"math"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hajimehoshi/ebiten/v2"
+)
+
+// 人物动画的名称，精灵图中缺少的动画使用 idle 代替
+const (
+	ClipIdle   = "idle"
+	ClipRun    = "run"
+	ClipHurt   = "hurt"
+	ClipDie    = "die"
+	ClipAttack = "attack"
+)
+
+const (
+	hurtFrames = 20                     // 受伤动画持续的帧数
+	attackTime = 150 * time.Millisecond // 开火后播放攻击动画的时间
+)
+
+// facingNames 按 directIdx 排列的朝向名称，动画可以按朝向分别定义，例如 run_left
+var facingNames = [4]string{"right", "down", "left", "up"}
+
+var spriteSheets map[string]*SpriteSheet // 按名称查找精灵图，供数据文件引用
+
+// AnimFrame 动画的一帧
+type AnimFrame struct {
+	rect     image.Rectangle // 在精灵图中的位置
+	duration int             // 持续的帧数（按每秒 60 帧计算）
+}
+
+// Clip 一段命名的动画
+type Clip struct {
+	Name   string
+	frames []AnimFrame
+	length int  // 所有帧持续的总帧数
+	loop   bool // 为 false 时播放一遍后停在最后一帧
+}
+
+// SpriteSheet 一张精灵图以及其中定义的动画，由 Aseprite 导出的 JSON 描述
+type SpriteSheet struct {
+	image  *Region
+	width  int // 每一帧的宽度
+	height int // 每一帧的高度
+	clips  map[string]*Clip
+}
+
+// asepriteSheet Aseprite 以 Array 格式导出的 JSON
+type asepriteSheet struct {
+	Frames []struct {
+		Frame struct {
+			X, Y, W, H int
+		} `json:"frame"`
+		Duration int `json:"duration"` // 毫秒
+	} `json:"frames"`
+	Meta struct {
+		Image     string `json:"image"`
+		FrameTags []struct {
+			Name      string `json:"name"`
+			From      int    `json:"from"`
+			To        int    `json:"to"`
+			Direction string `json:"direction"` // forward、reverse 或 pingpong
+			Repeat    string `json:"repeat"`    // 为空时循环播放
+		} `json:"frameTags"`
+	} `json:"meta"`
+}
+
+func InitAnimation() {
+	spriteSheets = make(map[string]*SpriteSheet)
+	if err := loadSpriteSheets(); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// loadSpriteSheets 加载 images 目录中除图集描述以外的所有精灵图 JSON，名称为文件名（不含扩展名）。
+// 重新加载时原地更新已有的精灵图，角色持有的精灵图随之改变
+func loadSpriteSheets() error {
+	loaded := make(map[string]*SpriteSheet)
+	for _, file := range AssetNames("images", ".json") {
+		name := strings.TrimSuffix(path.Base(file), ".json")
+		if name == "atlas" {
+			continue
+		}
+		raw, err := ReadAsset(file)
+		if err != nil {
+			return err
+		}
+		sheet, err := ParseSpriteSheet(raw)
+		if err != nil {
+			return fmt.Errorf("sprite sheet %s: %w", name, err)
+		}
+		loaded[name] = sheet
+	}
+	for name := range spriteSheets {
+		if loaded[name] == nil {
+			return fmt.Errorf("sprite sheet %s: missing", name)
+		}
+	}
+	for name, sheet := range loaded {
+		if old := spriteSheets[name]; old != nil {
+			*old = *sheet
+		} else {
+			spriteSheets[name] = sheet
+		}
+	}
+	return nil
+}
+
+// ParseSpriteSheet 解析 Aseprite 导出的 JSON，每个标签是一段动画，
+// 标签名为动画名称或者“动画名称_朝向”，图片为 imageByName 中与文件名同名的图片
+func ParseSpriteSheet(raw []byte) (*SpriteSheet, error) {
+	var doc asepriteSheet
+	if err := json.Unmarshal(raw, &doc); err != nil {
+		return nil, err
+	}
+	name := strings.TrimSuffix(doc.Meta.Image, ".png")
+	img, ok := imageByName[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown image %q", doc.Meta.Image)
+	}
+	if len(doc.Frames) == 0 {
+		return nil, fmt.Errorf("no frames")
+	}
+	s := &SpriteSheet{image: img, width: doc.Frames[0].Frame.W, height: doc.Frames[0].Frame.H, clips: make(map[string]*Clip)}
+	frames := make([]AnimFrame, len(doc.Frames))
+	for i, f := range doc.Frames {
+		rect := image.Rect(f.Frame.X, f.Frame.Y, f.Frame.X+f.Frame.W, f.Frame.Y+f.Frame.H)
+		if !rect.In(img.Bounds()) {
+			return nil, fmt.Errorf("frame %d %v is outside the image", i, rect)
+		}
+		// 毫秒换算为帧数，至少持续一帧
+		frames[i] = AnimFrame{rect: rect, duration: max(int(math.Round(float64(f.Duration)*60/1000)), 1)}
+	}
+	for _, tag := range doc.Meta.FrameTags {
+		if tag.From < 0 || tag.To >= len(frames) || tag.From > tag.To {
+			return nil, fmt.Errorf("tag %s: invalid frame range %d-%d", tag.Name, tag.From, tag.To)
+		}
+		clip := &Clip{Name: tag.Name, loop: tag.Repeat == "" || tag.Repeat == "0"}
+		if !clip.loop {
+			if _, err := strconv.Atoi(tag.Repeat); err != nil {
+				return nil, fmt.Errorf("tag %s: invalid repeat %q", tag.Name, tag.Repeat)
+			}
+		}
+		forward := frames[tag.From : tag.To+1]
+		switch tag.Direction {
+		case "", "forward":
+			clip.frames = append(clip.frames, forward...)
+		case "reverse":
+			for i := len(forward) - 1; i >= 0; i-- {
+				clip.frames = append(clip.frames, forward[i])
+			}
+		case "pingpong":
+			clip.frames = append(clip.frames, forward...)
+			for i := len(forward) - 2; i > 0; i-- {
+				clip.frames = append(clip.frames, forward[i])
+			}
+		default:
+			return nil, fmt.Errorf("tag %s: unknown direction %q", tag.Name, tag.Direction)
+		}
+		for _, f := range clip.frames {
+			clip.length += f.duration
+		}
+		s.clips[tag.Name] = clip
+	}
+	if _, ok := s.clips[ClipIdle]; !ok {
+		return nil, fmt.Errorf("missing %s clip", ClipIdle)
+	}
+	return s, nil
+}
+
+// Clip 查找 facing 朝向的动画以及是否需要水平翻转。查找顺序为：该朝向专门的动画、
+// 左右朝向时翻转另一侧的动画、不区分朝向的动画（原图朝右，faceLeft 时翻转），找不到时使用 idle
+func (s *SpriteSheet) Clip(name string, facing int, faceLeft bool) (*Clip, bool) {
+	if clip, ok := s.clips[name+"_"+facingNames[facing]]; ok {
+		return clip, false
+	}
+	if facing == 0 || facing == 2 {
+		if clip, ok := s.clips[name+"_"+facingNames[2-facing]]; ok {
+			return clip, true
+		}
+	}
+	if clip, ok := s.clips[name]; ok {
+		return clip, faceLeft
+	}
+	if name != ClipIdle {
+		return s.Clip(ClipIdle, facing, faceLeft)
+	}
+	return s.clips[ClipIdle], faceLeft
+}
+
+// Frame 动画开始后第 tick 帧显示的图片
+func (c *Clip) Frame(s *SpriteSheet, tick int) *ebiten.Image {
+	if c.loop {
+		tick %= c.length
+	}
+	for _, f := range c.frames {
+		if tick < f.duration {
+			return s.image.Sub(f.rect)
+		}
+		tick -= f.duration
+	}
+	return s.image.Sub(c.frames[len(c.frames)-1].rect)
+}
+
+// Draw 绘制 facing 朝向的动画在第 tick 帧的图片，需要翻转时在原地水平翻转
+func (s *SpriteSheet) Draw(dst ImageDrawer, name string, facing int, faceLeft bool, tick int, op *ebiten.DrawImageOptions) {
+	clip, flip := s.Clip(name, facing, faceLeft)
+	if flip {
+		var geoM ebiten.GeoM
+		geoM.Scale(-1, 1)
+		geoM.Translate(float64(s.width), 0)
+		geoM.Concat(op.GeoM)
+		op.GeoM = geoM
+	}
+	dst.DrawImage(clip.Frame(s, tick), op)
+}
+
+// Animator 人物当前播放的动画，随游戏状态一起复制
+type Animator struct {
+	clip         string  // 当前动画的名称
+	tick         int     // 当前动画开始后经过的帧数
+	faceLeft     bool    // 最近一次水平朝向是否朝左，上下移动时保持
+	hurt         int     // 受伤动画剩余的帧数
+	prevX, prevY float64 // 上一帧的位置，用于判断是否在移动
+}
+
+// Hurt 播放受伤动画
+func (a *Animator) Hurt() {
+	a.hurt = hurtFrames
+}
+
+// animate 按人物的状态切换动画并推进一帧
+func (p *Player) animate() {
+	a := &p.anim
+	clip := ClipIdle
+	switch {
+	case p.downed || p.health <= 0:
+		clip = ClipDie
+	case a.hurt > 0:
+		clip = ClipHurt
+	case p.attacking():
+		clip = ClipAttack
+	case p.x != a.prevX || p.y != a.prevY:
+		clip = ClipRun
+	}
+	if clip != a.clip {
+		a.clip = clip
+		a.tick = 0
+	} else {
+		a.tick++
+	}
+	a.hurt = max(a.hurt-1, 0)
+	switch p.directIdx {
+	case 0:
+		a.faceLeft = false
+	case 2:
+		a.faceLeft = true
+	}
+	a.prevX, a.prevY = p.x, p.y
+}
+
+// attacking 是否刚刚用远程武器开火
+func (p *Player) attacking() bool {
+	weapon, ok := p.weapon.(*RangedWeapon)
+	return ok && p.clock.Since(weapon.LastFireTime) < attackTime
+}
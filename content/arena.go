@@ -0,0 +1,246 @@
+package main
+
+import (
+	"avoid-the-enemies/content/config"
+	"image/color"
+	"math"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/inpututil"
+	"github.com/hajimehoshi/ebiten/v2/text/v2"
+	"github.com/hajimehoshi/ebiten/v2/vector"
+)
+
+// Team 人物所属的阵营，不同阵营的人物互相攻击
+type Team int
+
+// TeamNeutral 怪物的阵营，与所有人物敌对，包括其他怪物
+const TeamNeutral Team = 0
+
+// Hostile 两个阵营是否敌对
+func Hostile(a, b Team) bool {
+	return a == TeamNeutral || b == TeamNeutral || a != b
+}
+
+// ArenaRules 竞技场的规则，玩家之间互相攻击，最后存活的阵营赢得回合
+type ArenaRules struct {
+	RoundTime    time.Duration // 每回合的时长，时间结束时剩余生命值比例最高的阵营获胜
+	RoundsToWin  int           // 赢得比赛需要赢下的回合数
+	FriendlyFire bool          // 子弹是否可以击中同一阵营的玩家
+	Monsters     bool          // 是否刷新怪物，怪物作为中立的危险攻击所有玩家
+	Teams        int           // 阵营数量，为 0 时每个玩家各自为一个阵营
+}
+
+func DefaultArenaRules() *ArenaRules {
+	return &ArenaRules{
+		RoundTime:   config.ArenaRoundTime * time.Second,
+		RoundsToWin: config.ArenaRoundsToWin,
+	}
+}
+
+// arenaRoundTimes 标题界面可以切换的回合时长（秒）
+var arenaRoundTimes = []int{30, 60, 90, 120}
+
+// assignTeams 生存模式下所有玩家属于同一阵营，竞技场中按规则分配阵营
+func (g *Game) assignTeams() {
+	for i, p := range g.players {
+		p.team = 1
+		if g.arena == nil {
+			continue
+		}
+		if g.arena.Teams > 0 {
+			p.team = Team(i%g.arena.Teams + 1)
+		} else {
+			p.team = Team(i + 1)
+		}
+	}
+}
+
+// setupArena 回合开始时玩家围绕屏幕中心均匀分布，并获得释放技能的积分
+func (g *Game) setupArena() {
+	g.round = 1
+	g.roundWins = make(map[Team]int)
+	g.roundWinner = TeamNeutral
+	g.roundOverFrames = 0
+	for i, p := range g.players {
+		angle := 2*math.Pi*float64(i)/float64(len(g.players)) + math.Pi
+		p.x = config.ScreenWidth/2 - config.FrameWidth/2 + config.ArenaSpawnRadius*math.Cos(angle)
+		p.y = config.ScreenHeight/2 - config.FrameHeight/2 + config.ArenaSpawnRadius*math.Sin(angle)*0.6
+		p.score += config.ArenaStartScore
+	}
+}
+
+// resolveArenaOptions 在标题界面切换竞技场的设置
+func (g *Game) resolveArenaOptions() {
+	if inpututil.IsKeyJustPressed(ebiten.KeyT) {
+		seconds := int(g.arena.RoundTime.Seconds())
+		i := (slices.Index(arenaRoundTimes, seconds) + 1) % len(arenaRoundTimes)
+		g.arena.RoundTime = time.Duration(arenaRoundTimes[i]) * time.Second
+	}
+	if inpututil.IsKeyJustPressed(ebiten.KeyF) {
+		g.arena.FriendlyFire = !g.arena.FriendlyFire
+	}
+	if inpututil.IsKeyJustPressed(ebiten.KeyM) {
+		g.arena.Monsters = !g.arena.Monsters
+	}
+	// 在各自为战和两个阵营之间切换
+	if inpututil.IsKeyJustPressed(ebiten.KeyG) {
+		g.arena.Teams = 2 - g.arena.Teams
+		g.assignTeams()
+	}
+}
+
+// canHit 子弹是否可以击中人物，竞技场开启友军伤害时同一阵营的玩家也会被击中
+func (g *Game) canHit(s *Suspend, p *Player) bool {
+	if p.id == s.PlayerID {
+		return false
+	}
+	return Hostile(s.team, p.team) || (g.arena != nil && g.arena.FriendlyFire)
+}
+
+// aliveTeams 还有玩家存活的阵营
+func (g *Game) aliveTeams() map[Team]bool {
+	teams := make(map[Team]bool)
+	for _, p := range g.livingPlayers() {
+		teams[p.team] = true
+	}
+	return teams
+}
+
+// resolveRound 只剩一个阵营存活或者时间结束时回合结束，时间结束时剩余生命值比例最高的阵营获胜
+func (g *Game) resolveRound() {
+	teams := make(map[Team]bool)
+	for _, p := range g.players {
+		teams[p.team] = true
+	}
+	alive := g.aliveTeams()
+	timeUp := g.clock.Since(g.startTime) >= g.arena.RoundTime
+	if !timeUp && len(alive) > 0 && (len(alive) > 1 || len(teams) == 1) {
+		return
+	}
+
+	winner := TeamNeutral
+	if len(alive) == 1 {
+		for team := range alive {
+			winner = team
+		}
+	} else if len(alive) > 1 {
+		health := make(map[Team]float64)
+		for _, p := range g.livingPlayers() {
+			health[p.team] += p.health / p.maxHealth
+		}
+		best := 0.0
+		for _, team := range sortedKeys(health) {
+			switch {
+			case health[team] > best:
+				winner, best = team, health[team]
+			case health[team] == best:
+				winner = TeamNeutral
+			}
+		}
+	}
+	g.roundWinner = winner
+	if winner != TeamNeutral {
+		g.roundWins[winner]++
+	}
+	g.roundOverFrames = 0
+	g.mode = config.ModeRoundOver
+}
+
+// resolveModeRoundOver 展示回合结果，等待一段时间后开始下一回合，某个阵营赢下足够的回合时比赛结束
+func (g *Game) resolveModeRoundOver() {
+	g.roundOverFrames++
+	if g.roundOverFrames < config.ArenaRoundOverFrames {
+		return
+	}
+	if g.roundWinner != TeamNeutral && g.roundWins[g.roundWinner] >= g.arena.RoundsToWin {
+		g.mode = config.ModeGameOver
+		return
+	}
+	g.startRound()
+}
+
+// startRound 重新开始一局并保留比分，随机数种子取自当前的随机数生成器，回滚对战时双方保持一致
+func (g *Game) startRound() {
+	round, wins, seed := g.round, g.roundWins, g.seed
+	g.seed = int64(g.rng.Uint64()) | 1
+	g.init()
+	g.seed = seed
+	g.round, g.roundWins = round+1, wins
+	g.mode = config.ModeGame
+}
+
+// teamLabel 阵营的名称，每个玩家各自为一个阵营时为玩家编号
+func (g *Game) teamLabel(team Team) string {
+	if g.arena != nil && g.arena.Teams > 0 {
+		return T("arena.team", int(team))
+	}
+	for i, p := range g.players {
+		if p.team == team {
+			return "P" + strconv.Itoa(i+1)
+		}
+	}
+	return ""
+}
+
+// teamColor 阵营的颜色，与阵营中第一个玩家的颜色相同
+func (g *Game) teamColor(team Team) color.Color {
+	for i, p := range g.players {
+		if p.team == team {
+			return playerColors[i%len(playerColors)]
+		}
+	}
+	return color.White
+}
+
+// arenaOptions 标题界面显示的竞技场设置
+func (g *Game) arenaOptions() string {
+	onOff := func(on bool) string {
+		if on {
+			return T("common.on")
+		}
+		return T("common.off")
+	}
+	teams := T("arena.ffa")
+	if g.arena.Teams > 0 {
+		teams = T("arena.teams", g.arena.Teams)
+	}
+	return T("arena.options", int(g.arena.RoundTime.Seconds()), onOff(g.arena.FriendlyFire), onOff(g.arena.Monsters), teams)
+}
+
+// DrawArenaHUD 在屏幕上方绘制回合数以及回合剩余时间
+func DrawArenaHUD(screen *ebiten.Image, g *Game) {
+	remaining := max(g.arena.RoundTime-g.clock.Since(g.startTime), 0)
+	label := &Label{Text: T("arena.round", g.round, int(math.Ceil(remaining.Seconds()))), FontSize: config.FontSize, Color: color.RGBA{0xFF, 0xFF, 0xFF, 0xFF}}
+	hud.Place(screen, AnchorTop, 0, 3, label)
+}
+
+// DrawRoundOver 绘制回合结果以及各阵营赢下的回合数
+func DrawRoundOver(screen *ebiten.Image, g *Game) {
+	vector.DrawFilledRect(screen, 0, 0, config.ScreenWidth, config.ScreenHeight, color.RGBA{0x00, 0x00, 0x00, 0x80}, false)
+
+	result, resultColor := T("arena.draw"), color.Color(color.White)
+	if g.roundWinner != TeamNeutral {
+		result, resultColor = T("arena.wins", g.teamLabel(g.roundWinner)), g.teamColor(g.roundWinner)
+	}
+	op := &text.DrawOptions{}
+	op.GeoM.Translate(config.ScreenWidth/2, 4*config.TitleFontSize)
+	op.ColorScale.ScaleWithColor(resultColor)
+	op.PrimaryAlign = text.AlignCenter
+	text.Draw(screen, T("arena.round_result", g.round, result), arcadeFace(config.TitleFontSize), op)
+
+	teams := make(map[Team]bool)
+	for _, p := range g.players {
+		teams[p.team] = true
+	}
+	for i, team := range sortedKeys(teams) {
+		op := &text.DrawOptions{}
+		op.GeoM.Translate(config.ScreenWidth/2, float64(6*config.TitleFontSize+i*2*config.FontSize))
+		op.ColorScale.ScaleWithColor(g.teamColor(team))
+		op.PrimaryAlign = text.AlignCenter
+		text.Draw(screen, g.teamLabel(team)+": "+strconv.Itoa(g.roundWins[team])+"/"+strconv.Itoa(g.arena.RoundsToWin), arcadeFace(config.FontSize), op)
+	}
+}
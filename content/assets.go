@@ -0,0 +1,218 @@
+package main
+
+import (
+	"archive/zip"
+	"avoid-the-enemies/resources/audio"
+	"avoid-the-enemies/resources/data"
+	"avoid-the-enemies/resources/fonts"
+	"avoid-the-enemies/resources/images"
+	"avoid-the-enemies/resources/locales"
+	"avoid-the-enemies/resources/shaders"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path"
+	"slices"
+	"strings"
+	"time"
+)
+
+// assetPollInterval 开发模式下检查资源文件是否改动的间隔
+const assetPollInterval = 500 * time.Millisecond
+
+// embeddedAssets 嵌入的默认资源，按 resources 下的目录名查找
+var embeddedAssets = map[string]fs.FS{
+	"images":  images.FS,
+	"audio":   audio.FS,
+	"fonts":   fonts.FS,
+	"data":    data.FS,
+	"locales": locales.FS,
+	"shaders": shaders.FS,
+}
+
+var (
+	assetPackPath string          // 资源包的路径，为空时只使用嵌入的资源
+	assetPack     fs.FS           // 资源包的内容，目录结构与 resources 相同
+	assetZip      *zip.ReadCloser // 资源包是 zip 文件时打开的文件
+	assetChanges  chan string     // 开发模式下改动的资源文件，为空字符串时整个 zip 资源包都改动了
+)
+
+// InitAssets 使用 path 指向的目录或者 zip 文件覆盖嵌入的资源，资源包中没有的文件仍使用嵌入的资源
+func InitAssets(path string) {
+	if path == "" {
+		return
+	}
+	assetPackPath = path
+	if err := openAssetPack(); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// openAssetPack 打开资源包，zip 文件改动后需要重新打开
+func openAssetPack() error {
+	info, err := os.Stat(assetPackPath)
+	if err != nil {
+		return err
+	}
+	if info.IsDir() {
+		assetPack = os.DirFS(assetPackPath)
+		return nil
+	}
+	z, err := zip.OpenReader(assetPackPath)
+	if err != nil {
+		return fmt.Errorf("asset pack %s: %w", assetPackPath, err)
+	}
+	if assetZip != nil {
+		assetZip.Close()
+	}
+	assetZip = z
+	assetPack = z
+	return nil
+}
+
+// ReadAsset 读取资源文件，name 是相对于 resources 的路径，例如 images/atlas.png，资源包中的文件优先
+func ReadAsset(name string) ([]byte, error) {
+	if assetPack != nil {
+		b, err := fs.ReadFile(assetPack, name)
+		if err == nil || !errors.Is(err, fs.ErrNotExist) {
+			return b, err
+		}
+	}
+	dir, file, _ := strings.Cut(name, "/")
+	embedded, ok := embeddedAssets[dir]
+	if !ok {
+		return nil, fmt.Errorf("asset %s: %w", name, fs.ErrNotExist)
+	}
+	return fs.ReadFile(embedded, file)
+}
+
+// AssetNames 列出目录 dir 中扩展名为 ext 的资源文件，包括嵌入的和资源包中的，按名称排序
+func AssetNames(dir, ext string) []string {
+	names := OverrideAssetNames(dir, ext)
+	if embedded, ok := embeddedAssets[dir]; ok {
+		files, _ := fs.Glob(embedded, "*"+ext)
+		for _, file := range files {
+			names = append(names, dir+"/"+file)
+		}
+	}
+	slices.Sort(names)
+	return slices.Compact(names)
+}
+
+// OverrideAssetNames 列出资源包的目录 dir 中扩展名为 ext 的文件，按名称排序
+func OverrideAssetNames(dir, ext string) []string {
+	if assetPack == nil {
+		return nil
+	}
+	names, _ := fs.Glob(assetPack, dir+"/*"+ext)
+	slices.Sort(names)
+	return names
+}
+
+// WatchAssets 在后台轮询资源包，把改动的文件发送给 reloadAssets，资源在游戏的主循环中重新加载
+func WatchAssets() {
+	if assetPackPath == "" {
+		return
+	}
+	assetChanges = make(chan string, 64)
+	go func() {
+		last, _ := scanAssetPack()
+		for range time.Tick(assetPollInterval) {
+			files, err := scanAssetPack()
+			if err != nil {
+				continue // 保存文件的过程中可能暂时读不到
+			}
+			for name, mod := range files {
+				if !last[name].Equal(mod) {
+					assetChanges <- name
+				}
+			}
+			last = files
+		}
+	}()
+}
+
+// scanAssetPack 资源包中每个文件的修改时间，zip 资源包只记录 zip 文件本身
+func scanAssetPack() (map[string]time.Time, error) {
+	files := make(map[string]time.Time)
+	if assetZip != nil {
+		info, err := os.Stat(assetPackPath)
+		if err != nil {
+			return nil, err
+		}
+		files[""] = info.ModTime()
+		return files, nil
+	}
+	err := fs.WalkDir(os.DirFS(assetPackPath), ".", func(name string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		files[name] = info.ModTime()
+		return nil
+	})
+	return files, err
+}
+
+// assetKinds 按加载顺序排列的资源种类，图片改动后依赖它的精灵图也要重新加载
+var assetKinds = []struct {
+	name   string
+	load   func() error
+	change func(file string) bool
+}{
+	{"images", loadImages, func(file string) bool {
+		return path.Dir(file) == "images" && (path.Ext(file) == ".png" || path.Base(file) == "atlas.json")
+	}},
+	{"sprites", loadSpriteSheets, func(file string) bool {
+		return path.Dir(file) == "images"
+	}},
+	{"fonts", loadFonts, func(file string) bool {
+		return path.Dir(file) == "fonts"
+	}},
+	{"locales", loadLocales, func(file string) bool {
+		return path.Dir(file) == "locales"
+	}},
+	{"shaders", loadShaders, func(file string) bool {
+		return path.Dir(file) == "shaders"
+	}},
+	{"sounds", loadSounds, func(file string) bool {
+		return slices.ContainsFunc(sounds, func(s *Sound) bool { return s.file == file })
+	}},
+	{"music", loadMusic, musicChanged},
+	{"data", loadCharacters, func(file string) bool {
+		return file == "data/characters.json"
+	}},
+}
+
+// reloadAssets 重新加载开发模式下改动的资源，加载失败时保留原来的资源并打印错误。
+// 只能在主循环中调用，资源不会被其他协程访问
+func reloadAssets() {
+	var changed []string
+	for len(assetChanges) > 0 {
+		changed = append(changed, <-assetChanges)
+	}
+	if len(changed) == 0 {
+		return
+	}
+	if slices.Contains(changed, "") {
+		if err := openAssetPack(); err != nil {
+			log.Printf("reload assets: %v", err)
+			return
+		}
+	}
+	for _, kind := range assetKinds {
+		if !slices.ContainsFunc(changed, func(file string) bool { return file == "" || kind.change(file) }) {
+			continue
+		}
+		if err := kind.load(); err != nil {
+			log.Printf("reload %s: %v", kind.name, err)
+			continue
+		}
+		log.Printf("reloaded %s", kind.name)
+	}
+}
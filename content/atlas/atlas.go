@@ -0,0 +1,187 @@
+// Package atlas 将多张图片打包成一张图集。tools/atlas 在构建时生成默认的图集，
+// 游戏在资源包覆盖了部分图片时用同样的方法重新打包
+package atlas
+
+import (
+	"fmt"
+	"image"
+	"image/draw"
+	"sort"
+)
+
+// Rect 矩形区域
+type Rect struct {
+	X int `json:"x"`
+	Y int `json:"y"`
+	W int `json:"w"`
+	H int `json:"h"`
+}
+
+func (r Rect) Rectangle() image.Rectangle {
+	return image.Rect(r.X, r.Y, r.X+r.W, r.Y+r.H)
+}
+
+// Piece 原图中的一段区域以及它在图集中的位置
+type Piece struct {
+	Src Rect `json:"src"`
+	X   int  `json:"x"`
+	Y   int  `json:"y"`
+}
+
+// Sprite 一张原图的尺寸以及组成它的各段，Split 为切开横条动画时使用的帧宽度
+type Sprite struct {
+	W      int     `json:"w"`
+	H      int     `json:"h"`
+	Split  int     `json:"split,omitempty"`
+	Pieces []Piece `json:"pieces"`
+}
+
+// Atlas 图集的描述文件，按原图的文件名（不含扩展名）查找
+type Atlas struct {
+	Image   string             `json:"image"`
+	Width   int                `json:"width"`
+	Height  int                `json:"height"`
+	Sprites map[string]*Sprite `json:"sprites"`
+}
+
+// Source 需要打包的一张原图，Split 为宽度超过图集时按多宽的帧切开，为 0 时不能切开
+type Source struct {
+	Name  string
+	Image image.Image
+	Split int
+}
+
+// Options 打包的参数
+type Options struct {
+	Padding  int // 每一段周围留出的透明像素
+	MaxWidth int // 图集的最大宽度
+}
+
+var DefaultOptions = Options{Padding: 1, MaxWidth: 1024}
+
+// piece 等待摆放的一段图片，同一张图片的各段是连续的
+type piece struct {
+	source *Source
+	src    image.Rectangle
+}
+
+// Pack 打包所有原图，在不超过最大宽度的 2 的幂中选择面积最小的图集宽度
+func Pack(sources []Source, opts Options) (*image.NRGBA, *Atlas, error) {
+	if len(sources) == 0 {
+		return nil, nil, fmt.Errorf("no images")
+	}
+	var pieces []piece
+	limit := opts.MaxWidth - 2*opts.Padding
+	for i := range sources {
+		s := &sources[i]
+		b := s.Image.Bounds()
+		if b.Dx() <= limit {
+			pieces = append(pieces, piece{source: s, src: b})
+			continue
+		}
+		if s.Split <= 0 || s.Split > limit {
+			return nil, nil, fmt.Errorf("%s is %d pixels wide, give the width of its frames to split it", s.Name, b.Dx())
+		}
+		// 每一段包含整数个帧，动画的一帧不会被切开
+		step := limit / s.Split * s.Split
+		for x := b.Min.X; x < b.Max.X; x += step {
+			pieces = append(pieces, piece{source: s, src: image.Rect(x, b.Min.Y, min(x+step, b.Max.X), b.Max.Y)})
+		}
+	}
+
+	widest := 0
+	for _, p := range pieces {
+		widest = max(widest, p.src.Dx())
+	}
+	var best *Atlas
+	for width := nextPowerOfTwo(widest + 2*opts.Padding); width <= opts.MaxWidth; width *= 2 {
+		a := pack(pieces, width, opts.Padding)
+		if best == nil || a.Width*a.Height < best.Width*best.Height {
+			best = a
+		}
+	}
+	if best == nil {
+		return nil, nil, fmt.Errorf("the widest piece does not fit in %d pixels", opts.MaxWidth)
+	}
+	for i := range sources {
+		s := &sources[i]
+		sprite := best.Sprites[s.Name]
+		sprite.W, sprite.H = s.Image.Bounds().Dx(), s.Image.Bounds().Dy()
+		if len(sprite.Pieces) > 1 {
+			sprite.Split = s.Split
+		}
+	}
+
+	dst := image.NewNRGBA(image.Rect(0, 0, best.Width, best.Height))
+	seen := make(map[string]int)
+	for _, p := range pieces {
+		placed := best.Sprites[p.source.Name].Pieces[seen[p.source.Name]]
+		seen[p.source.Name]++
+		draw.Draw(dst, image.Rect(placed.X, placed.Y, placed.X+placed.Src.W, placed.Y+placed.Src.H), p.source.Image, p.src.Min, draw.Src)
+	}
+	return dst, best, nil
+}
+
+// pack 按高度从高到低逐行摆放，一行放不下时换到下一行
+func pack(pieces []piece, width, padding int) *Atlas {
+	a := &Atlas{Width: width, Sprites: make(map[string]*Sprite)}
+	index := make([]int, len(pieces)) // 每一段是所在图片的第几段
+	for i, p := range pieces {
+		sprite := a.Sprites[p.source.Name]
+		if sprite == nil {
+			sprite = &Sprite{}
+			a.Sprites[p.source.Name] = sprite
+		}
+		index[i] = len(sprite.Pieces)
+		sprite.Pieces = append(sprite.Pieces, Piece{})
+	}
+	order := make([]int, len(pieces))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(i, j int) bool {
+		return pieces[order[i]].src.Dy() > pieces[order[j]].src.Dy()
+	})
+	x, y, rowHeight := padding, padding, 0
+	for _, i := range order {
+		p := pieces[i]
+		w, h := p.src.Dx(), p.src.Dy()
+		if x+w+padding > width {
+			x, y = padding, y+rowHeight+2*padding
+			rowHeight = 0
+		}
+		a.Sprites[p.source.Name].Pieces[index[i]] = Piece{
+			Src: Rect{X: p.src.Min.X, Y: p.src.Min.Y, W: w, H: h},
+			X:   x,
+			Y:   y,
+		}
+		x += w + 2*padding
+		rowHeight = max(rowHeight, h)
+	}
+	a.Height = y + rowHeight + padding
+	return a
+}
+
+// Extract 从图集中还原每一张原图，用于替换其中几张后重新打包
+func (a *Atlas) Extract(img image.Image) []Source {
+	var sources []Source
+	for name, sprite := range a.Sprites {
+		dst := image.NewNRGBA(image.Rect(0, 0, sprite.W, sprite.H))
+		for _, p := range sprite.Pieces {
+			draw.Draw(dst, p.Src.Rectangle(), img, image.Pt(p.X, p.Y), draw.Src)
+		}
+		sources = append(sources, Source{Name: name, Image: dst, Split: sprite.Split})
+	}
+	sort.Slice(sources, func(i, j int) bool {
+		return sources[i].Name < sources[j].Name
+	})
+	return sources
+}
+
+func nextPowerOfTwo(n int) int {
+	p := 1
+	for p < n {
+		p *= 2
+	}
+	return p
+}
@@ -0,0 +1,129 @@
+package atlas
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+// testImage 每个像素的颜色由名称和坐标决定，打包后可以检查像素是否来自正确的位置
+func testImage(id uint8, w, h int) *image.NRGBA {
+	img := image.NewNRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			img.SetNRGBA(x, y, color.NRGBA{R: id, G: uint8(x), B: uint8(y), A: 255})
+		}
+	}
+	return img
+}
+
+func testSources() []Source {
+	return []Source{
+		{Name: "bullet", Image: testImage(1, 8, 8)},
+		{Name: "runner", Image: testImage(2, 96, 64)},
+		{Name: "skill", Image: testImage(3, 480, 48), Split: 48}, // 比图集宽，需要切开
+		{Name: "sword", Image: testImage(4, 32, 32)},
+	}
+}
+
+func samePixels(t *testing.T, name string, got, want image.Image) {
+	t.Helper()
+	if got.Bounds().Size() != want.Bounds().Size() {
+		t.Fatalf("%s is %v, want %v", name, got.Bounds().Size(), want.Bounds().Size())
+	}
+	for y := 0; y < want.Bounds().Dy(); y++ {
+		for x := 0; x < want.Bounds().Dx(); x++ {
+			g := got.At(got.Bounds().Min.X+x, got.Bounds().Min.Y+y)
+			w := want.At(want.Bounds().Min.X+x, want.Bounds().Min.Y+y)
+			if color.NRGBAModel.Convert(g) != color.NRGBAModel.Convert(w) {
+				t.Fatalf("%s pixel (%d, %d) = %v, want %v", name, x, y, g, w)
+			}
+		}
+	}
+}
+
+func TestPackExtract(t *testing.T) {
+	sources := testSources()
+	opts := Options{Padding: 1, MaxWidth: 256}
+	img, a, err := Pack(sources, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a.Width > opts.MaxWidth || a.Width&(a.Width-1) != 0 {
+		t.Errorf("atlas width %d is not a power of two up to %d", a.Width, opts.MaxWidth)
+	}
+	if img.Bounds() != image.Rect(0, 0, a.Width, a.Height) {
+		t.Errorf("atlas image is %v, atlas says %dx%d", img.Bounds(), a.Width, a.Height)
+	}
+
+	skill := a.Sprites["skill"]
+	if len(skill.Pieces) < 2 || skill.Split != 48 {
+		t.Errorf("skill has %d pieces split at %d, want it split at 48", len(skill.Pieces), skill.Split)
+	}
+	for _, p := range skill.Pieces {
+		if p.Src.W%48 != 0 {
+			t.Errorf("skill piece %+v cuts a frame", p)
+		}
+	}
+
+	// 各段加上留白后互不重叠，并且都在图集之内
+	var placed []image.Rectangle
+	for name, sprite := range a.Sprites {
+		for _, p := range sprite.Pieces {
+			r := image.Rect(p.X, p.Y, p.X+p.Src.W, p.Y+p.Src.H)
+			if !r.Inset(-opts.Padding).In(img.Bounds()) {
+				t.Errorf("%s piece %v with padding is outside the atlas", name, r)
+			}
+			for _, q := range placed {
+				if r.Inset(-opts.Padding).Overlaps(q) {
+					t.Errorf("%s piece %v overlaps %v", name, r, q)
+				}
+			}
+			placed = append(placed, r)
+		}
+	}
+
+	// 每一段的像素来自原图中对应的区域
+	for _, s := range sources {
+		for _, p := range a.Sprites[s.Name].Pieces {
+			samePixels(t, s.Name, img.SubImage(image.Rect(p.X, p.Y, p.X+p.Src.W, p.Y+p.Src.H)), s.Image.(*image.NRGBA).SubImage(p.Src.Rectangle()))
+		}
+	}
+
+	extracted := a.Extract(img)
+	if len(extracted) != len(sources) {
+		t.Fatalf("extracted %d images, want %d", len(extracted), len(sources))
+	}
+	for i, s := range sources {
+		e := extracted[i]
+		if e.Name != s.Name || e.Split != a.Sprites[s.Name].Split {
+			t.Errorf("extracted %s split at %d, want %s split at %d", e.Name, e.Split, s.Name, a.Sprites[s.Name].Split)
+		}
+		samePixels(t, s.Name, e.Image, s.Image)
+	}
+
+	// 还原的原图重新打包得到同样的图集
+	repacked, b, err := Pack(extracted, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b.Width != a.Width || b.Height != a.Height {
+		t.Errorf("repacked atlas is %dx%d, want %dx%d", b.Width, b.Height, a.Width, a.Height)
+	}
+	samePixels(t, "repacked atlas", repacked, img)
+}
+
+func TestPackErrors(t *testing.T) {
+	opts := Options{Padding: 1, MaxWidth: 256}
+	if _, _, err := Pack(nil, opts); err == nil {
+		t.Error("Pack accepted no images")
+	}
+	wide := []Source{{Name: "wide", Image: testImage(1, 300, 10)}}
+	if _, _, err := Pack(wide, opts); err == nil {
+		t.Error("Pack accepted a wide image without a frame width")
+	}
+	wide[0].Split = 300
+	if _, _, err := Pack(wide, opts); err == nil {
+		t.Error("Pack accepted frames wider than the atlas")
+	}
+}
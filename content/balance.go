@@ -0,0 +1,127 @@
+package main
+
+import (
+	"math"
+)
+
+// Balance 可以调整的平衡参数，sim 命令按参数矩阵比较不同取值的效果，默认值与正常游戏一致
+type Balance struct {
+	MonsterSpeed float64                `json:"monster_speed"` // 怪物移动速度的倍率
+	SpawnBase    int                    `json:"spawn_base"`    // 怪物数量上限的基础值
+	SpawnDivisor int                    `json:"spawn_divisor"` // 每分配多少个 id 怪物数量上限加一
+	SkillCost    float64                `json:"skill_cost"`    // 技能积分消耗的倍率
+	Weapons      map[string]WeaponStats `json:"weapons,omitempty"`
+}
+
+// WeaponStats 按武器类型覆盖的武器属性，为 0 的属性保持不变
+type WeaponStats struct {
+	Spin     float64 `json:"spin,omitempty"`     // 近战武器每帧转动的角度（弧度）
+	Damage   float64 `json:"damage,omitempty"`   // 子弹的伤害值
+	Speed    float64 `json:"speed,omitempty"`    // 子弹的速度
+	Distance float64 `json:"distance,omitempty"` // 子弹的射程
+}
+
+// monsterBaseSpeed 怪物的基础移动速度
+const monsterBaseSpeed = 1.0 / 180
+
+var defaultBalance = Balance{
+	MonsterSpeed: 1,
+	SpawnBase:    3,
+	SpawnDivisor: 10,
+	SkillCost:    1,
+}
+
+func DefaultBalance() *Balance {
+	b := defaultBalance
+	return &b
+}
+
+// Balance 本局游戏使用的平衡参数
+func (g *Game) Balance() *Balance {
+	if g.balance == nil {
+		return &defaultBalance
+	}
+	return g.balance
+}
+
+// monsterCap 场上怪物数量的上限，随着分配的 id 增加
+func (g *Game) monsterCap() int {
+	b := g.Balance()
+	return g.uniqueId/max(b.SpawnDivisor, 1) + b.SpawnBase
+}
+
+// wave 当前的波次，怪物数量上限每增加一次波次加一，从 1 开始
+func (g *Game) wave() int {
+	return g.uniqueId/max(g.Balance().SpawnDivisor, 1) + 1
+}
+
+// skillCost 按平衡参数调整后的技能积分消耗
+func (g *Game) skillCost(skill Skill) int {
+	return int(math.Round(float64(skill.Cost()) * g.Balance().SkillCost))
+}
+
+// tuneWeapon 按平衡参数覆盖武器的属性
+func (g *Game) tuneWeapon(weapon Weapon) {
+	if weapon == nil {
+		return
+	}
+	stats, ok := g.Balance().Weapons[weapon.GetType()]
+	if !ok {
+		return
+	}
+	switch weapon := weapon.(type) {
+	case *MeleeWeapon:
+		if stats.Spin != 0 {
+			weapon.spin = stats.Spin
+		}
+	case *RangedWeapon:
+		if stats.Damage != 0 {
+			weapon.damage = stats.Damage
+		}
+		if stats.Speed != 0 {
+			weapon.speed = stats.Speed
+		}
+		if stats.Distance != 0 {
+			weapon.distance = stats.Distance
+		}
+	}
+}
+
+// 玩家受到伤害的来源，武器造成的伤害以武器类型为来源
+const (
+	DamageContact = "contact" // 与怪物接触
+	DamageStatus  = "status"  // 中毒、灼烧等持续伤害
+)
+
+// RunStats 一局游戏的统计数据，只在 sim 等统计场景中记录
+type RunStats struct {
+	Kills    map[string]int     `json:"kills"`    // 按击杀时玩家持有的武器类型统计的击杀数，空手为 "none"，持续伤害等为 "other"
+	Damage   map[string]float64 `json:"damage"`   // 玩家按伤害来源统计损失的生命值
+	Monsters []int              `json:"monsters"` // 每秒的怪物数量
+}
+
+func NewRunStats() *RunStats {
+	return &RunStats{
+		Kills:  make(map[string]int),
+		Damage: make(map[string]float64),
+	}
+}
+
+// recordKill 记录一次击杀，killer 为 nil 时击杀不是由玩家直接造成的
+func (s *RunStats) recordKill(killer *Player) {
+	switch {
+	case killer == nil:
+		s.Kills["other"]++
+	case killer.weapon == nil:
+		s.Kills["none"]++
+	default:
+		s.Kills[killer.weapon.GetType()]++
+	}
+}
+
+// recordMonsters 每经过一秒记录一次怪物数量
+func (s *RunStats) recordMonsters(g *Game) {
+	if second := int(g.clock.Since(g.startTime).Seconds()); second >= len(s.Monsters) {
+		s.Monsters = append(s.Monsters, len(g.monsters))
+	}
+}
@@ -0,0 +1,209 @@
+package main
+
+import (
+	"avoid-the-enemies/content/config"
+	"flag"
+	"fmt"
+	"image"
+	"image/color"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/text/v2"
+)
+
+// 基准测试中的绘制方式
+const (
+	benchSubImage = "subimage" // 每次绘制都调用 SubImage 并创建字体，与使用图集之前的做法相同
+	benchCached   = "cached"   // 使用缓存的子图片和字体，每个实体调用一次 DrawImage
+	benchBatch    = "batch"    // 使用缓存的子图片和字体，所有实体收集到 SpriteBatch 中一起绘制
+)
+
+// benchCase 一组基准测试：绘制方式以及实体数量
+type benchCase struct {
+	mode     string
+	entities int
+}
+
+type benchResult struct {
+	benchCase
+	draw time.Duration // 平均每帧 Draw 花费的 CPU 时间
+	fps  float64       // 平均帧率，包括 GPU 的绘制时间
+}
+
+// benchEntity 在屏幕中反弹的一张图片
+type benchEntity struct {
+	x, y, vx, vy float64
+	region       *Region
+	rect         image.Rectangle
+}
+
+// benchGame 依次运行每组基准测试，每组先预热再计时，全部结束后退出
+type benchGame struct {
+	cases   []benchCase
+	frames  int
+	warmup  int
+	labels  int
+	current int
+	frame   int
+	start   time.Time
+	draw    time.Duration
+	results []benchResult
+
+	rng      Rand
+	entities []benchEntity
+	batch    SpriteBatch
+}
+
+// RunBench 绘制大量实体，比较不同绘制方式的耗时，
+// 用法：avoid-the-enemies bench [-entities 1000,5000,10000] [-modes subimage,cached,batch] [-frames 300] [-labels 100]
+func RunBench(args []string) {
+	fs := flag.NewFlagSet("bench", flag.ExitOnError)
+	entities := fs.String("entities", "1000,5000,10000", "comma separated entity counts")
+	modes := fs.String("modes", strings.Join([]string{benchSubImage, benchCached, benchBatch}, ","), "comma separated draw modes: subimage, cached, batch")
+	frames := fs.Int("frames", 300, "measured frames of each case")
+	warmup := fs.Int("warmup", 60, "frames before measuring each case")
+	labels := fs.Int("labels", 100, "text labels drawn every frame")
+	fs.Parse(args)
+	if *frames < 1 || *warmup < 0 || *labels < 0 {
+		log.Fatal("frames must be positive, warmup and labels must not be negative")
+	}
+	b := &benchGame{frames: *frames, warmup: *warmup, labels: *labels}
+	for _, mode := range strings.Split(*modes, ",") {
+		if mode != benchSubImage && mode != benchCached && mode != benchBatch {
+			log.Fatalf("unknown mode %q", mode)
+		}
+		for _, s := range strings.Split(*entities, ",") {
+			n, err := strconv.Atoi(s)
+			if err != nil || n < 1 {
+				log.Fatalf("invalid entity count %q", s)
+			}
+			b.cases = append(b.cases, benchCase{mode: mode, entities: n})
+		}
+	}
+
+	Init()
+	b.rng.Seed(1)
+	b.reset()
+	ebiten.SetWindowSize(config.ScreenWidth*3, config.ScreenHeight*3)
+	ebiten.SetWindowTitle("Avoid the Enemies - bench")
+	ebiten.SetVsyncEnabled(false)
+	ebiten.SetTPS(ebiten.SyncWithFPS)
+	if err := ebiten.RunGame(b); err != nil && err != ebiten.Termination {
+		log.Fatal(err)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(w, "mode\tentities\tdraw(ms)\tfps\t")
+	for _, r := range b.results {
+		fmt.Fprintf(w, "%s\t%d\t%.3f\t%.1f\t\n", r.mode, r.entities, float64(r.draw.Microseconds())/1000, r.fps)
+	}
+	w.Flush()
+}
+
+// reset 为当前的一组测试生成实体：怪物、子弹、武器和火焰
+func (b *benchGame) reset() {
+	c := b.cases[b.current]
+	b.entities = b.entities[:0]
+	for i := 0; i < c.entities; i++ {
+		e := benchEntity{
+			x:  b.rng.Float64() * config.ScreenWidth,
+			y:  b.rng.Float64() * config.ScreenHeight,
+			vx: b.rng.Float64()*2 - 1,
+			vy: b.rng.Float64()*2 - 1,
+		}
+		switch i % 4 {
+		case 0:
+			e.region, e.rect = runnerImage, image.Rect(b.rng.Intn(8)*32, 32, b.rng.Intn(8)*32+32, 64)
+		case 1:
+			e.region, e.rect = bulletImage, bulletImage.Bounds()
+		case 2:
+			e.region, e.rect = []*Region{akImage, sickleImage, swordImage}[b.rng.Intn(3)], frameRect
+		case 3:
+			sx := b.rng.Intn(4) * 64
+			e.region, e.rect = fireImage, image.Rect(sx, 0, sx+64, 64)
+		}
+		b.entities = append(b.entities, e)
+	}
+	b.frame = 0
+	b.draw = 0
+}
+
+func (b *benchGame) Update() error {
+	for i := range b.entities {
+		e := &b.entities[i]
+		e.x += e.vx
+		e.y += e.vy
+		if e.x < 0 || e.x > config.ScreenWidth {
+			e.vx = -e.vx
+		}
+		if e.y < 0 || e.y > config.ScreenHeight {
+			e.vy = -e.vy
+		}
+	}
+	b.frame++
+	if b.frame == b.warmup {
+		b.start = time.Now()
+	}
+	if b.frame < b.warmup+b.frames {
+		return nil
+	}
+	b.results = append(b.results, benchResult{
+		benchCase: b.cases[b.current],
+		draw:      b.draw / time.Duration(b.frames),
+		fps:       float64(b.frames) / time.Since(b.start).Seconds(),
+	})
+	b.current++
+	if b.current == len(b.cases) {
+		return ebiten.Termination
+	}
+	b.reset()
+	return nil
+}
+
+func (b *benchGame) Draw(screen *ebiten.Image) {
+	start := time.Now()
+	mode := b.cases[b.current].mode
+	if mode == benchBatch {
+		b.batch.Begin(screen)
+	}
+	for i := range b.entities {
+		e := &b.entities[i]
+		op := &ebiten.DrawImageOptions{}
+		op.GeoM.Translate(e.x, e.y)
+		switch mode {
+		case benchSubImage:
+			screen.DrawImage(atlasImage.SubImage(e.region.atlasRect(e.rect)).(*ebiten.Image), op)
+		case benchCached:
+			screen.DrawImage(e.region.Sub(e.rect), op)
+		case benchBatch:
+			b.batch.DrawImage(e.region.Sub(e.rect), op)
+		}
+	}
+	if mode == benchBatch {
+		b.batch.End()
+	}
+	for i := 0; i < b.labels && i < len(b.entities); i++ {
+		e := &b.entities[i]
+		op := &text.DrawOptions{}
+		op.GeoM.Translate(e.x, e.y)
+		op.ColorScale.ScaleWithColor(color.White)
+		face := arcadeFace(config.FontSize)
+		if mode == benchSubImage {
+			face = &text.GoTextFace{Source: arcadeFaceSource, Size: config.FontSize}
+		}
+		text.Draw(screen, "MONSTER "+strconv.Itoa(i), face, op)
+	}
+	if b.frame >= b.warmup {
+		b.draw += time.Since(start)
+	}
+}
+
+func (b *benchGame) Layout(outsideWidth, outsideHeight int) (int, int) {
+	return config.ScreenWidth, config.ScreenHeight
+}
@@ -0,0 +1,443 @@
+package main
+
+import (
+	"avoid-the-enemies/content/config"
+	"flag"
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"runtime"
+	"slices"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"avoid-the-enemies/content/utils"
+)
+
+// BotProfile 电脑玩家的难度，决定反应速度、感知范围、瞄准精度以及是否躲避子弹和释放技能
+type BotProfile struct {
+	Name         string
+	Reaction     int     // 重新计算移动方向的间隔帧数
+	Awareness    float64 // 感知怪物和子弹的半径
+	MoveNoise    float64 // 移动方向的随机扰动
+	AimTolerance float64 // 怪物与枪口的偏差小于该值时开火
+	FireInterval int     // 两次开火之间的最小帧数
+	Dodge        bool    // 子弹即将命中时冲刺躲避
+	UseSkills    bool    // 被怪物包围时释放技能
+}
+
+var botProfiles = []*BotProfile{
+	{Name: "easy", Reaction: 12, Awareness: 60, MoveNoise: 0.6, AimTolerance: 4, FireInterval: 30},
+	{Name: "normal", Reaction: 6, Awareness: 90, MoveNoise: 0.3, AimTolerance: 8, FireInterval: 15, Dodge: true},
+	{Name: "hard", Reaction: 2, Awareness: 140, MoveNoise: 0.1, AimTolerance: 12, FireInterval: 8, Dodge: true, UseSkills: true},
+}
+
+// BotProfileByName 根据名称查找难度，不存在时返回 nil
+func BotProfileByName(name string) *BotProfile {
+	for _, profile := range botProfiles {
+		if profile.Name == name {
+			return profile
+		}
+	}
+	return nil
+}
+
+// nextBotProfile 标题界面切换的下一个难度，最难的难度之后关闭电脑玩家
+func nextBotProfile(profile *BotProfile) *BotProfile {
+	i := slices.Index(botProfiles, profile) + 1
+	if i == len(botProfiles) {
+		return nil
+	}
+	return botProfiles[i]
+}
+
+// 势场的权重
+const (
+	botMonsterWeight = 400.0 // 怪物的斥力
+	botBulletWeight  = 900.0 // 子弹的斥力
+	botHazardWeight  = 3.0   // 危险区域的斥力
+	botEdgeMargin    = 30.0  // 距离屏幕边缘小于该值时受到斥力
+	botSeekWeight    = 1.0   // 武器和道具的引力
+	botMeleeReach    = 40.0  // 持有近战武器时靠近怪物到这个距离
+	botDodgeDistance = 24.0  // 子弹距离小于该值时冲刺躲避
+	botCrowded       = 3     // 附近的怪物达到该数量时释放技能
+)
+
+// Bot 电脑玩家，与键盘一样作为玩家的操作来源，根据游戏状态计算每一帧的操作
+// 移动方向由势场决定：怪物、子弹、危险区域和屏幕边缘产生斥力，武器和道具产生引力
+type Bot struct {
+	game     *Game
+	id       int // 控制的玩家 id
+	profile  *BotProfile
+	rng      Rand // 电脑玩家自己的随机数，不影响游戏的随机数
+	frame    int
+	lastFire int
+	upgrade  int // 升级时要选择的强化，为 -1 时还没有决定
+	moveX    float64
+	moveY    float64
+}
+
+func NewBot(g *Game, id int, profile *BotProfile, seed int64) *Bot {
+	b := &Bot{game: g, id: id, profile: profile, upgrade: -1}
+	b.rng.Seed(seed)
+	return b
+}
+
+func (b *Bot) SkillLabel(i int) string {
+	return "AI"
+}
+
+func (b *Bot) Read() Input {
+	g := b.game
+	p := g.playerByID(b.id)
+	if p == nil || p.downed {
+		return Input{}
+	}
+	b.frame++
+	// 升级时随机决定一个强化，移动光标到该强化后确认
+	if g.mode == config.ModeLevelUp {
+		if b.upgrade < 0 {
+			b.upgrade = b.rng.Intn(len(g.upgradeChoices))
+		}
+		if g.upgradeCursor != b.upgrade {
+			return Input{Right: true}
+		}
+		b.upgrade = -1
+		return Input{Fire: true}
+	}
+
+	if (b.frame-1)%max(b.profile.Reaction, 1) == 0 {
+		b.moveX, b.moveY = b.steer(p)
+	}
+	in := Input{X: b.moveX, Y: b.moveY}
+	b.aim(p, &in)
+	if b.profile.Dodge && b.bulletIncoming(p) {
+		in.Dash = true
+	}
+	if b.profile.UseSkills {
+		b.useSkill(p, &in)
+	}
+	return in
+}
+
+// steer 按势场计算移动方向
+func (b *Bot) steer(p *Player) (float64, float64) {
+	g := b.game
+	cx, cy := p.x+config.FrameWidth/2, p.y+config.FrameHeight/2
+	var fx, fy float64
+	push := func(x, y, weight float64) {
+		dx, dy := cx-x, cy-y
+		d := math.Max(math.Hypot(dx, dy), 1)
+		fx += dx / d * weight / (d * d)
+		fy += dy / d * weight / (d * d)
+	}
+	pull := func(x, y, weight float64) {
+		dx, dy := x-cx, y-cy
+		d := math.Max(math.Hypot(dx, dy), 1)
+		fx += dx / d * weight
+		fy += dy / d * weight
+	}
+
+	_, melee := p.weapon.(*MeleeWeapon)
+	var nearest *Player
+	nearestDistance := math.Inf(1)
+	for _, id := range sortedKeys(g.monsters) {
+		m := g.monsters[id]
+		mx, my := m.x+config.FrameWidth/2, m.y+config.FrameHeight/2
+		d := utils.GetDistance(cx, cy, mx, my)
+		if d < nearestDistance {
+			nearest, nearestDistance = m, d
+		}
+		if d < b.profile.Awareness {
+			push(mx, my, botMonsterWeight)
+		}
+	}
+	// 持有近战武器并且生命值充足时主动靠近最近的怪物
+	if melee && nearest != nil && p.health > p.maxHealth/2 && nearestDistance > botMeleeReach {
+		pull(nearest.x+config.FrameWidth/2, nearest.y+config.FrameHeight/2, botSeekWeight/2)
+	}
+	for _, id := range sortedKeys(g.suspends) {
+		s := g.suspends[id]
+		if !g.canHit(s, p) || utils.GetDistance(cx, cy, s.pos[0], s.pos[1]) > b.profile.Awareness {
+			continue
+		}
+		nx, ny := b.bulletNext(s)
+		push(s.pos[0], s.pos[1], botBulletWeight)
+		push(nx, ny, botBulletWeight)
+	}
+	for _, id := range sortedKeys(g.hazards) {
+		h := g.hazards[id]
+		hx, hy := h.Center()
+		if utils.GetDistance(cx, cy, hx, hy) < h.radius+config.FrameWidth {
+			push(hx, hy, botHazardWeight*h.radius*h.radius)
+		}
+	}
+	// 屏幕边缘的斥力
+	if cx < botEdgeMargin {
+		fx += (botEdgeMargin - cx) / botEdgeMargin
+	}
+	if cx > config.ScreenWidth-botEdgeMargin {
+		fx -= (cx - config.ScreenWidth + botEdgeMargin) / botEdgeMargin
+	}
+	if cy < botEdgeMargin {
+		fy += (botEdgeMargin - cy) / botEdgeMargin
+	}
+	if cy > config.ScreenHeight-botEdgeMargin {
+		fy -= (cy - config.ScreenHeight + botEdgeMargin) / botEdgeMargin
+	}
+	// 没有武器时前往最近的武器，否则前往感知范围内最近的道具
+	if target, ok := b.nearest(cx, cy, p.weapon == nil); ok {
+		pull(target[0], target[1], botSeekWeight)
+	}
+
+	angle := b.rng.Float64() * 2 * math.Pi
+	fx += math.Cos(angle) * b.profile.MoveNoise
+	fy += math.Sin(angle) * b.profile.MoveNoise
+	if d := math.Hypot(fx, fy); d > 0.05 {
+		return fx / d, fy / d
+	}
+	return 0, 0
+}
+
+// nearest 距离最近的武器或者道具的中心位置
+func (b *Bot) nearest(cx, cy float64, weapons bool) ([2]float64, bool) {
+	g := b.game
+	var best [2]float64
+	bestDistance := math.Inf(1)
+	consider := func(x, y float64) {
+		if d := utils.GetDistance(cx, cy, x, y); d < bestDistance {
+			best, bestDistance = [2]float64{x, y}, d
+		}
+	}
+	if weapons {
+		for _, id := range sortedKeys(g.weaponPosition) {
+			pos := g.weaponPosition[id]
+			consider(pos[0]+config.FrameWidth/2, pos[1]+config.FrameHeight/2)
+		}
+		return best, !math.IsInf(bestDistance, 1)
+	}
+	for _, id := range sortedKeys(g.pickups) {
+		pos := g.pickups[id].pos
+		consider(pos[0]+config.FrameWidth/2, pos[1]+config.FrameHeight/2)
+	}
+	return best, bestDistance < b.profile.Awareness
+}
+
+// bulletNext 子弹下一帧的位置
+func (b *Bot) bulletNext(s *Suspend) (float64, float64) {
+	dx, dy := directions[s.directIndex].dx, directions[s.directIndex].dy
+	if s.direction != nil {
+		dx, dy = s.direction.x, s.direction.y
+	}
+	step := s.rangeWeapon.speed * float64(s.time+1)
+	return s.pos[0] + dx*step, s.pos[1] + dy*step
+}
+
+// bulletIncoming 是否有子弹即将命中
+func (b *Bot) bulletIncoming(p *Player) bool {
+	g := b.game
+	cx, cy := p.x+config.FrameWidth/2, p.y+config.FrameHeight/2
+	for _, id := range sortedKeys(g.suspends) {
+		s := g.suspends[id]
+		if !g.canHit(s, p) {
+			continue
+		}
+		nx, ny := b.bulletNext(s)
+		if utils.GetDistance(cx, cy, nx, ny) < botDodgeDistance && utils.GetDistance(cx, cy, nx, ny) < utils.GetDistance(cx, cy, s.pos[0], s.pos[1]) {
+			return true
+		}
+	}
+	return false
+}
+
+// aim 持有远程武器时，有怪物与枪口在同一水平或者竖直线上就转向该方向开火
+func (b *Bot) aim(p *Player, in *Input) {
+	weapon, ok := p.weapon.(*RangedWeapon)
+	if !ok || b.frame-b.lastFire < b.profile.FireInterval {
+		return
+	}
+	g := b.game
+	x, y := p.x+p.weaponX, p.y+p.weaponY
+	for _, id := range sortedKeys(g.monsters) {
+		m := g.monsters[id]
+		dx, dy := m.x+config.FrameWidth/2-x, m.y+config.FrameHeight/2-y
+		// 人物的朝向由移动方向决定，竖直方向优先，朝水平方向开火时不能有竖直方向的移动
+		switch {
+		case math.Abs(dy) < b.profile.AimTolerance && math.Abs(dx) < weapon.distance:
+			in.X, in.Y = math.Copysign(math.Max(math.Abs(in.X), 0.1), dx), 0
+		case math.Abs(dx) < b.profile.AimTolerance && math.Abs(dy) < weapon.distance:
+			in.Y = math.Copysign(math.Max(math.Abs(in.Y), 0.1), dy)
+		default:
+			continue
+		}
+		in.Fire = true
+		b.lastFire = b.frame
+		return
+	}
+}
+
+// useSkill 附近的怪物太多时释放第一个可以释放的技能
+func (b *Bot) useSkill(p *Player, in *Input) {
+	g := b.game
+	crowd := 0
+	for _, m := range g.monsters {
+		if utils.GetDistance(p.x, p.y, m.x, m.y) < b.profile.Awareness/2 {
+			crowd++
+		}
+	}
+	if crowd < botCrowded {
+		return
+	}
+	for i, slot := range p.skills {
+		if !slot.active && slot.Ready(p) && p.score >= b.game.skillCost(slot.skill) {
+			in.Skills[i] = true
+			return
+		}
+	}
+}
+
+// BotResult 一局电脑玩家游戏的结果
+type BotResult struct {
+	Seed     int64     `json:"seed"`
+	Survival float64   `json:"survival"` // 存活时间（秒）
+	Score    int       `json:"score"`
+	Kills    int       `json:"kills"`
+	Level    int       `json:"level"`
+	Damage   float64   `json:"damage"` // 损失的生命值
+	Died     bool      `json:"died"`   // 为 false 时达到了时间上限
+	Stats    *RunStats `json:"-"`
+}
+
+// RunBotGame 无界面运行一局由电脑玩家控制的单人游戏，maxFrames 为 0 时不限制帧数，balance 为 nil 时使用默认的平衡参数
+func RunBotGame(seed int64, profile *BotProfile, character *Character, maxFrames int, balance *Balance) BotResult {
+	g := &Game{profile: NewProfile(""), seed: seed, balance: balance, stats: NewRunStats()}
+	g.seats = []Seat{{input: NewBot(g, 1, profile, seed), character: character}}
+	g.init()
+	g.mode = config.ModeGame
+	for frame := 0; g.mode != config.ModeGameOver && (maxFrames == 0 || frame < maxFrames); frame++ {
+		if err := g.Update(); err != nil {
+			log.Println("bot game:", err)
+		}
+	}
+	p := g.players[0]
+	return BotResult{
+		Seed:     seed,
+		Survival: g.clock.Since(g.startTime).Seconds(),
+		Score:    p.score,
+		Kills:    p.kills,
+		Level:    p.level,
+		Damage:   p.damageTaken,
+		Died:     g.mode == config.ModeGameOver,
+		Stats:    g.stats,
+	}
+}
+
+// RunBots 无界面并行运行多局电脑玩家游戏，输出存活时间和积分的分布，
+// 用法：avoid-the-enemies bot [-games 100] [-seed 1] [-difficulty normal] [-character id] [-max-time 10m] [-v]
+func RunBots(args []string) {
+	fs := flag.NewFlagSet("bot", flag.ExitOnError)
+	games := fs.Int("games", 100, "number of games")
+	seed := fs.Int64("seed", 1, "seed of the first game, game i uses seed+i")
+	difficulty := fs.String("difficulty", "normal", "bot difficulty: easy, normal or hard")
+	characterID := fs.String("character", "", "character id, the first character when empty")
+	maxTime := fs.Duration("max-time", 10*time.Minute, "game time limit of each game, 0 for no limit")
+	workers := fs.Int("workers", runtime.NumCPU(), "games simulated in parallel")
+	verbose := fs.Bool("v", false, "print the result of every game")
+	fs.Parse(args)
+	profile := BotProfileByName(*difficulty)
+	if profile == nil {
+		log.Fatalf("unknown difficulty %q", *difficulty)
+	}
+	if *games < 1 || *workers < 1 {
+		log.Fatal("games and workers must be positive")
+	}
+
+	headless = true
+	Init()
+	character := CharacterByID(*characterID)
+	results := make([]BotResult, *games)
+	maxFrames := int(maxTime.Seconds() * 60)
+	parallel(*games, *workers, func(i int) {
+		results[i] = RunBotGame(*seed+int64(i), profile, character, maxFrames, nil)
+	})
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', tabwriter.AlignRight)
+	if *verbose {
+		fmt.Fprintln(w, "seed\tsurvival\tscore\tkills\tlevel\tdied\t")
+		for _, r := range results {
+			fmt.Fprintf(w, "%d\t%.1f\t%d\t%d\t%d\t%t\t\n", r.Seed, r.Survival, r.Score, r.Kills, r.Level+1, r.Died)
+		}
+		fmt.Fprintln(w)
+	}
+	fmt.Fprintf(w, "%d games\t%s\t%s\t\t\t\t\t\n", *games, profile.Name, character.ID)
+	fmt.Fprintln(w, "\tmean\tmin\tp10\tp50\tp90\tmax\t")
+	stat := func(name string, value func(r BotResult) float64) {
+		values := make([]float64, len(results))
+		for i, r := range results {
+			values[i] = value(r)
+		}
+		d := NewDistribution(values)
+		fmt.Fprintf(w, "%s\t%.1f\t%.1f\t%.1f\t%.1f\t%.1f\t%.1f\t\n", name, d.Mean, d.Min, d.P10, d.P50, d.P90, d.Max)
+	}
+	stat("survival(s)", func(r BotResult) float64 { return r.Survival })
+	stat("score", func(r BotResult) float64 { return float64(r.Score) })
+	stat("kills", func(r BotResult) float64 { return float64(r.Kills) })
+	stat("level", func(r BotResult) float64 { return float64(r.Level + 1) })
+	w.Flush()
+}
+
+// parallel 用 workers 个 goroutine 执行 f(0) 到 f(n-1)
+func parallel(n, workers int, f func(i int)) {
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < min(workers, n); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				f(i)
+			}
+		}()
+	}
+	for i := 0; i < n; i++ {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+}
+
+// Distribution 一组数值的均值和分位数
+type Distribution struct {
+	Mean float64 `json:"mean"`
+	Min  float64 `json:"min"`
+	P10  float64 `json:"p10"`
+	P50  float64 `json:"p50"`
+	P90  float64 `json:"p90"`
+	Max  float64 `json:"max"`
+}
+
+func NewDistribution(values []float64) Distribution {
+	if len(values) == 0 {
+		return Distribution{}
+	}
+	sorted := slices.Clone(values)
+	slices.Sort(sorted)
+	// 按最近秩法取分位数
+	quantile := func(q float64) float64 {
+		return sorted[max(int(math.Ceil(q*float64(len(sorted))))-1, 0)]
+	}
+	sum := 0.0
+	for _, v := range sorted {
+		sum += v
+	}
+	return Distribution{
+		Mean: sum / float64(len(sorted)),
+		Min:  sorted[0],
+		P10:  quantile(0.1),
+		P50:  quantile(0.5),
+		P90:  quantile(0.9),
+		Max:  sorted[len(sorted)-1],
+	}
+}
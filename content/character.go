@@ -0,0 +1,232 @@
+package main
+
+import (
+	"avoid-the-enemies/content/config"
+	"encoding/json"
+	"fmt"
+	"image/color"
+	"log"
+	"strconv"
+	"strings"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/inpututil"
+	"github.com/hajimehoshi/ebiten/v2/text/v2"
+)
+
+var (
+	characters    []*Character // 按数据文件顺序排列的角色
+	monsterSprite *SpriteSheet // 怪物使用的精灵图
+)
+
+// Character 可以选择的角色，由 resources/data/characters.json 定义
+type Character struct {
+	ID           string     `json:"id"`
+	Name         string     `json:"name"`
+	Sprite       string     `json:"sprite"`       // 精灵图名称，对应 spriteSheets 中的键
+	Tint         string     `json:"tint"`         // 绘制角色时的颜色，格式为 #rrggbb
+	Speed        float64    `json:"speed"`        // 移动速度
+	Health       float64    `json:"health"`       // 生命值上限
+	WeaponOffset [2]float64 `json:"weaponOffset"` // 武器中心相对于角色左上角的偏移
+	Weapon       string     `json:"weapon"`       // 开局携带的武器，为空时空手开局
+	Skill        string     `json:"skill"`        // 专属技能，不需要解锁
+	Price        int        `json:"price"`        // 在商店中解锁的价格，为 0 时默认解锁
+
+	tint   color.RGBA
+	sprite *SpriteSheet
+}
+
+func InitCharacter() {
+	monsterSprite = spriteSheets["runner"]
+	if err := loadCharacters(); err != nil {
+		log.Fatal(err)
+	}
+	// 需要解锁的角色在商店中出售
+	for _, c := range characters {
+		if c.Price > 0 {
+			shopItems = append(shopItems, &ShopItem{
+				id:     "character:" + c.ID,
+				kind:   ShopCharacter,
+				price:  c.Price,
+				target: c.ID,
+			})
+		}
+	}
+}
+
+// loadCharacters 加载并检查角色的数据文件。重新加载时原地更新同一 ID 的角色，
+// 新的属性在下一局游戏开始时生效，商店中出售的角色不会改变
+func loadCharacters() error {
+	raw, err := ReadAsset("data/characters.json")
+	if err != nil {
+		return err
+	}
+	var loaded []*Character
+	if err := json.Unmarshal(raw, &loaded); err != nil {
+		return fmt.Errorf("characters.json: %w", err)
+	}
+	if len(loaded) == 0 {
+		return fmt.Errorf("characters.json: no characters")
+	}
+	for _, c := range loaded {
+		tint, err := parseColor(c.Tint)
+		if err != nil {
+			return fmt.Errorf("character %s: %w", c.ID, err)
+		}
+		c.tint = tint
+		c.sprite = spriteSheets[c.Sprite]
+		if c.sprite == nil {
+			return fmt.Errorf("character %s: unknown sprite %q", c.ID, c.Sprite)
+		}
+		if c.Weapon != "" && NewWeapon(c.Weapon) == nil {
+			return fmt.Errorf("character %s: unknown weapon %q", c.ID, c.Weapon)
+		}
+		if NewSkill(c.Skill) == nil {
+			return fmt.Errorf("character %s: unknown skill %q", c.ID, c.Skill)
+		}
+	}
+	for i, c := range loaded {
+		for _, old := range characters {
+			if old.ID == c.ID {
+				*old = *c
+				loaded[i] = old
+				break
+			}
+		}
+	}
+	characters = loaded
+	return nil
+}
+
+// parseColor 解析 #rrggbb 格式的颜色
+func parseColor(s string) (color.RGBA, error) {
+	v, err := strconv.ParseUint(strings.TrimPrefix(s, "#"), 16, 32)
+	if err != nil || len(s) != 7 {
+		return color.RGBA{}, fmt.Errorf("invalid color %q", s)
+	}
+	return color.RGBA{uint8(v >> 16), uint8(v >> 8), uint8(v), 0xFF}, nil
+}
+
+// CharacterByID 根据 ID 查找角色，找不到时返回第一个角色
+func CharacterByID(id string) *Character {
+	for _, c := range characters {
+		if c.ID == id {
+			return c
+		}
+	}
+	return characters[0]
+}
+
+// DisplayName 界面上显示的角色名称，当前语言中没有这个角色时使用数据文件中的名称
+func (c *Character) DisplayName() string {
+	if s, ok := Lookup("character." + c.ID); ok {
+		return s
+	}
+	return c.Name
+}
+
+// CharacterUnlocked 角色是否已经解锁
+func (p *Profile) CharacterUnlocked(c *Character) bool {
+	return c.Price == 0 || p.Unlocked["character:"+c.ID]
+}
+
+// NewCharacterPlayer 按角色的基础属性创建玩家，角色的专属技能装备在 signatureSkillKey 上
+func NewCharacterPlayer(c *Character, id int, clock *Clock) *Player {
+	p := &Player{
+		character:         c,
+		clock:             clock,
+		x:                 config.ScreenWidth/2 - config.FrameWidth/2,
+		y:                 config.ScreenHeight/2 - config.FrameHeight/2,
+		speed:             c.Speed,
+		weaponX:           c.WeaponOffset[0],
+		weaponY:           c.WeaponOffset[1],
+		health:            c.Health,
+		maxHealth:         c.Health,
+		lastCollisionTime: clock.Now(),
+		id:                id,
+		dashCharges:       config.DashMaxCharges,
+	}
+	if c.Weapon != "" {
+		p.weapon = NewWeapon(c.Weapon)
+	}
+	return p
+}
+
+// DrawCharacter 按角色的精灵图和颜色绘制人物当前的动画
+func DrawCharacter(screen *ebiten.Image, p *Player, op *ebiten.DrawImageOptions) {
+	op.ColorScale.ScaleWithColor(p.character.tint)
+	p.character.sprite.Draw(screen, p.anim.clip, p.directIdx, p.anim.faceLeft, p.anim.tick, op)
+}
+
+// resolveModeCharacterSelect 左右键切换角色，空格键确认并开始游戏，Esc 键返回标题界面
+func (g *Game) resolveModeCharacterSelect() {
+	if inpututil.IsKeyJustPressed(ebiten.KeyEscape) || inpututil.IsKeyJustPressed(ebiten.KeyBackspace) {
+		g.mode = config.ModeTitle
+		return
+	}
+	if inpututil.IsKeyJustPressed(ebiten.KeyArrowLeft) {
+		g.characterCursor = (g.characterCursor + len(characters) - 1) % len(characters)
+	}
+	if inpututil.IsKeyJustPressed(ebiten.KeyArrowRight) {
+		g.characterCursor = (g.characterCursor + 1) % len(characters)
+	}
+	c := characters[g.characterCursor]
+	if !inpututil.IsKeyJustPressed(ebiten.KeySpace) || !g.profile.CharacterUnlocked(c) {
+		return
+	}
+	g.profile.Character = c.ID
+	if err := g.profile.Save(); err != nil {
+		log.Println("save profile:", err)
+	}
+	g.init()
+	g.mode = config.ModeGame
+}
+
+// DrawCharacterSelect 绘制角色选择界面
+func DrawCharacterSelect(screen *ebiten.Image, g *Game) {
+	c := characters[g.characterCursor]
+	unlocked := g.profile.CharacterUnlocked(c)
+	face := arcadeFace(config.FontSize)
+
+	op := &text.DrawOptions{}
+	op.GeoM.Translate(config.ScreenWidth/2, 2*config.TitleFontSize)
+	op.ColorScale.ScaleWithColor(color.White)
+	op.PrimaryAlign = text.AlignCenter
+	text.Draw(screen, "< "+c.DisplayName()+" >", arcadeFace(config.TitleFontSize), op)
+
+	// 放大绘制角色，未解锁的角色绘制为剪影
+	imgOp := &ebiten.DrawImageOptions{}
+	imgOp.GeoM.Scale(2, 2)
+	imgOp.GeoM.Translate(config.ScreenWidth/2-float64(c.sprite.width), 56)
+	if unlocked {
+		imgOp.ColorScale.ScaleWithColor(c.tint)
+	} else {
+		imgOp.ColorScale.Scale(0, 0, 0, 1)
+	}
+	c.sprite.Draw(screen, ClipRun, 0, false, g.characterFrame, imgOp)
+	g.characterFrame++
+
+	weapon := T("common.none")
+	if c.Weapon != "" {
+		weapon = T("weapon." + c.Weapon)
+	}
+	stats := T("character.stats", int(c.Health), c.Speed, weapon, T("skill."+c.Skill))
+	op = &text.DrawOptions{}
+	op.GeoM.Translate(config.ScreenWidth/2, 136)
+	op.ColorScale.ScaleWithColor(color.Gray{0xC0})
+	op.LineSpacing = config.FontSize * 1.5
+	op.PrimaryAlign = text.AlignCenter
+	text.Draw(screen, stats, face, op)
+
+	hint := T("character.hint")
+	hintColor := color.Color(color.Gray{0x80})
+	if !unlocked {
+		hint = T("character.locked", c.Price)
+		hintColor = color.RGBA{0xFF, 0x40, 0x40, 0xFF}
+	}
+	op = &text.DrawOptions{}
+	op.GeoM.Translate(config.ScreenWidth/2, config.ScreenHeight-3*config.FontSize)
+	op.ColorScale.ScaleWithColor(hintColor)
+	op.PrimaryAlign = text.AlignCenter
+	text.Draw(screen, hint, face, op)
+}
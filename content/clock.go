@@ -0,0 +1,33 @@
+package main
+
+import "time"
+
+// Clock 游戏内的时钟，只在游戏进行时前进，升级选择等暂停期间所有计时都会停止
+// 每局游戏有自己的时钟，人物通过 clock 字段共享所在游戏的时钟，回滚时随游戏状态一起保存和恢复
+type Clock struct {
+	now time.Time
+}
+
+func NewClock() *Clock {
+	return &Clock{now: time.Unix(0, 0)}
+}
+
+// Tick 游戏时钟前进一帧
+func (c *Clock) Tick() {
+	c.now = c.now.Add(time.Second / 60)
+}
+
+// Now 当前的游戏时间
+func (c *Clock) Now() time.Time {
+	return c.now
+}
+
+// Since 从 t 到当前游戏时间经过的时长
+func (c *Clock) Since(t time.Time) time.Duration {
+	return c.now.Sub(t)
+}
+
+// Until 从当前游戏时间到 t 的时长
+func (c *Clock) Until(t time.Time) time.Duration {
+	return t.Sub(c.now)
+}
@@ -2,8 +2,11 @@ package main
 
 import (
 	"avoid-the-enemies/content/config"
+	"cmp"
 	"github.com/hajimehoshi/ebiten/v2/audio"
+	"image"
 	"math"
+	"slices"
 )
 
 var (
@@ -16,6 +19,7 @@ var (
 		{-1, 0, math.Pi},         // 左
 		{0, -1, math.Pi / 2 * 3}, // 上
 	}
+	frameRect    = image.Rect(0, 0, config.FrameWidth, config.FrameHeight) // 人物和武器图片中一帧的区域
 	rotateAdjust = []struct {
 		dx, dy float64
 	}{
@@ -29,3 +33,14 @@ var (
 func IsTouch(x1, y1, x2, y2 float64) bool {
 	return math.Abs(x1-x2) < config.FrameWidth/2 && math.Abs(y1-y2) < config.FrameHeight/2
 }
+
+// sortedKeys 按从小到大的顺序返回 map 的键
+// 模拟中消耗随机数、分配 id 或者累加浮点数的循环按固定的顺序遍历实体，保证同样的操作总是得到同样的结果
+func sortedKeys[K cmp.Ordered, V any](m map[K]V) []K {
+	keys := make([]K, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+	return keys
+}
@@ -6,16 +6,19 @@ const (
 	ModeTitle Mode = iota
 	ModeGame
 	ModeGameOver
+	ModeLevelUp
+	ModeShop
+	ModeCharacterSelect
+	ModeRoundOver
+	ModeSettings
+	ModePause
 )
 
 const (
 	ScreenWidth   = 320
 	ScreenHeight  = 240
-	FrameOX       = 0
-	FrameOY       = 32
 	FrameWidth    = 32
 	FrameHeight   = 32
-	FrameCount    = 8
 	TitleFontSize = FontSize * 1.5
 	FontSize      = 8
 )
@@ -23,3 +26,31 @@ const (
 const (
 	MonsterMinDistance = 10 // 怪物之间的最小距离，当两个怪物的距离大于此值，它们将趋于分离
 )
+
+const (
+	DashSpeed          = 6.0 // 冲刺时每帧移动的距离
+	DashFrames         = 10  // 冲刺持续的帧数
+	DashInvincible     = 200 // 冲刺的无敌时间（毫秒）
+	DashCooldown       = 300 // 两次冲刺之间的最小间隔（毫秒）
+	DashMaxCharges     = 2   // 冲刺次数上限
+	DashRechargeFrames = 90  // 恢复一次冲刺所需的帧数
+)
+
+const (
+	MaxPlayers       = 4    // 本地多人游戏的玩家数量上限
+	ReviveFrames     = 120  // 队友持续接触倒地玩家多少帧后救起
+	ReviveHealth     = 0.5  // 救起时恢复的生命值比例
+	ReviveInvincible = 2000 // 救起后的无敌时间（毫秒）
+)
+
+const (
+	AutosaveInterval = 30 // 游戏进行中自动存档的间隔（秒）
+)
+
+const (
+	ArenaRoundTime       = 60  // 竞技场每回合的时长（秒）
+	ArenaRoundsToWin     = 3   // 赢得比赛需要赢下的回合数
+	ArenaStartScore      = 50  // 每回合开始时的积分，用于释放技能
+	ArenaRoundOverFrames = 180 // 回合结束后进入下一回合前等待的帧数
+	ArenaSpawnRadius     = 90  // 回合开始时玩家围绕屏幕中心分布的半径
+)
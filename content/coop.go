@@ -0,0 +1,177 @@
+package main
+
+import (
+	"avoid-the-enemies/content/config"
+	"image/color"
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/ebitenutil"
+	"github.com/hajimehoshi/ebiten/v2/text/v2"
+	"golang.org/x/image/math/f64"
+
+	"avoid-the-enemies/content/utils"
+)
+
+// playerColors 多人游戏时区分玩家的颜色
+var playerColors = []color.RGBA{
+	{0xFF, 0x60, 0x60, 0xFF},
+	{0x60, 0xA0, 0xFF, 0xFF},
+	{0x60, 0xFF, 0x60, 0xFF},
+	{0xFF, 0xE0, 0x30, 0xFF},
+}
+
+// monsterTargetWeakestChance 怪物优先追逐生命值最低的玩家的概率，其余怪物追逐最近的玩家
+const monsterTargetWeakestChance = 0.3
+
+// Seat 一名玩家的操作来源以及选择的角色
+type Seat struct {
+	input     InputSource
+	character *Character
+}
+
+// playerByID 根据 id 查找玩家，id 不属于玩家时返回 nil
+func (g *Game) playerByID(id int) *Player {
+	for _, p := range g.players {
+		if p.id == id {
+			return p
+		}
+	}
+	return nil
+}
+
+// playerIndex 玩家在 g.players 中的序号
+func (g *Game) playerIndex(p *Player) int {
+	for i, player := range g.players {
+		if player == p {
+			return i
+		}
+	}
+	return -1
+}
+
+// livingPlayers 没有倒地的玩家
+func (g *Game) livingPlayers() []*Player {
+	var players []*Player
+	for _, p := range g.players {
+		if !p.downed {
+			players = append(players, p)
+		}
+	}
+	return players
+}
+
+// nearestPlayer 距离 (x, y) 最近的没有倒地的玩家，所有玩家都倒地时返回 nil
+func (g *Game) nearestPlayer(x, y float64) *Player {
+	var nearest *Player
+	nearDistance := math.Inf(1)
+	for _, p := range g.livingPlayers() {
+		if distance := utils.GetDistance(x, y, p.x, p.y); distance < nearDistance {
+			nearest, nearDistance = p, distance
+		}
+	}
+	return nearest
+}
+
+// selectTarget 为怪物选择追逐的玩家，最近的玩家或者生命值比例最低的玩家
+func (g *Game) selectTarget(monster *Player) *Player {
+	if !monster.targetWeakest {
+		return g.nearestPlayer(monster.x, monster.y)
+	}
+	var weakest *Player
+	for _, p := range g.livingPlayers() {
+		if weakest == nil || p.health/p.maxHealth < weakest.health/weakest.maxHealth {
+			weakest = p
+		}
+	}
+	return weakest
+}
+
+// chaseTarget 怪物追逐的目标位置，存在诱饵时以诱饵为目标，追逐的玩家倒地时重新选择目标
+func (g *Game) chaseTarget(monster *Player) f64.Vec2 {
+	if g.decoy != nil {
+		return *g.decoy
+	}
+	if monster.target == nil || monster.target.downed {
+		monster.target = g.selectTarget(monster)
+	}
+	if monster.target == nil {
+		return f64.Vec2{monster.x, monster.y}
+	}
+	return f64.Vec2{monster.target.x, monster.target.y}
+}
+
+// downPlayer 玩家倒地，所有玩家都倒地时游戏结束
+func (g *Game) downPlayer(p *Player) {
+	p.health = 0
+	p.downed = true
+	p.reviveProgress = 0
+	p.dashFrame = 0
+	clear(p.statuses)
+	// 竞技场中由回合结算决定胜负
+	if g.arena == nil && len(g.livingPlayers()) == 0 {
+		g.gameOver()
+	}
+}
+
+// resolveRevive 同一阵营的队友持续接触倒地的玩家一段时间后将其救起，离开时救起进度清零
+func (g *Game) resolveRevive() {
+	for _, p := range g.players {
+		if !p.downed {
+			continue
+		}
+		touched := false
+		for _, ally := range g.livingPlayers() {
+			if ally.team == p.team && IsTouch(p.x, p.y, ally.x, ally.y) {
+				touched = true
+				break
+			}
+		}
+		if !touched {
+			p.reviveProgress = 0
+			continue
+		}
+		p.reviveProgress++
+		if p.reviveProgress >= config.ReviveFrames {
+			p.downed = false
+			p.health = p.maxHealth * config.ReviveHealth
+			p.invincibleUntil = g.clock.Now().Add(config.ReviveInvincible * time.Millisecond)
+		}
+	}
+}
+
+// totalScore 所有玩家的积分之和
+func (g *Game) totalScore() int {
+	score := 0
+	for _, p := range g.players {
+		score += p.score
+	}
+	return score
+}
+
+// playerLabel 多人游戏时玩家的编号，单人游戏时为空
+func (g *Game) playerLabel(i int) string {
+	if len(g.players) == 1 {
+		return ""
+	}
+	return "P" + strconv.Itoa(i+1) + " "
+}
+
+// DrawPlayerTag 多人游戏时在血条上方绘制玩家编号，倒地的玩家在血条位置绘制救起进度
+func DrawPlayerTag(screen *ebiten.Image, g *Game, i int) {
+	p := g.players[i]
+	if p.downed {
+		progress := float64(p.reviveProgress) / config.ReviveFrames
+		ebitenutil.DrawRect(screen, p.x, p.y-5, config.FrameWidth*progress, 5, color.RGBA{0x40, 0xFF, 0x40, 0xFF})
+	}
+	if len(g.players) == 1 {
+		return
+	}
+	op := &text.DrawOptions{}
+	op.GeoM.Translate(p.x+config.FrameWidth/2, p.y-5-8)
+	op.ColorScale.ScaleWithColor(playerColors[i%len(playerColors)])
+	op.PrimaryAlign = text.AlignCenter
+	text.Draw(screen, "P"+strconv.Itoa(i+1), arcadeFace(6), op)
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"avoid-the-enemies/content/config"
+	"image/color"
+	"math"
+	"time"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/ebitenutil"
+
+	"avoid-the-enemies/content/utils"
+)
+
+// Dash 向 (dx, dy) 方向冲刺，方向为零时沿人物朝向冲刺，冲刺次数不足或者冷却中时冲刺失败
+func (p *Player) Dash(dx, dy float64) bool {
+	if p.Dashing() || p.dashCharges < 1 || p.clock.Since(p.dashTime) < config.DashCooldown*time.Millisecond {
+		return false
+	}
+	if dx == 0 && dy == 0 {
+		dx, dy = directions[p.directIdx].dx, directions[p.directIdx].dy
+	}
+	p.dashX, p.dashY = utils.Normal(dx, dy)
+	p.dashFrame = config.DashFrames
+	p.dashCharges--
+	p.dashTime = p.clock.Now()
+	return true
+}
+
+// Dashing 是否正在冲刺
+func (p *Player) Dashing() bool {
+	return p.dashFrame > 0
+}
+
+// UpdateDash 推进冲刺并恢复冲刺次数，冲刺撞到屏幕边缘时提前结束
+func (p *Player) UpdateDash() {
+	if p.dashCharges < config.DashMaxCharges {
+		p.dashCharges = math.Min(p.dashCharges+(1+p.mods.dashRecharge)/config.DashRechargeFrames, config.DashMaxCharges)
+	}
+	if !p.Dashing() {
+		return
+	}
+	p.dashFrame--
+	if p.Move(p.dashX*config.DashSpeed, p.dashY*config.DashSpeed) {
+		p.dashFrame = 0
+	}
+}
+
+// DrawDashBar 在血条下方绘制冲刺次数，未恢复的一格按恢复进度绘制
+func DrawDashBar(screen *ebiten.Image, p *Player) {
+	const height = 2
+	width := float64(config.FrameWidth) / config.DashMaxCharges
+	y := p.y
+	for i := 0; i < config.DashMaxCharges; i++ {
+		x := p.x + float64(i)*width
+		ebitenutil.DrawRect(screen, x, y, width-1, height, color.Gray{0x40})
+		fill := math.Min(math.Max(p.dashCharges-float64(i), 0), 1)
+		c := color.RGBA{0x40, 0xC0, 0xFF, 0xFF}
+		if fill < 1 {
+			c = color.RGBA{0x20, 0x60, 0x80, 0xFF}
+		}
+		ebitenutil.DrawRect(screen, x, y, (width-1)*fill, height, c)
+	}
+}
@@ -0,0 +1,197 @@
+package main
+
+import (
+	"avoid-the-enemies/content/config"
+	"fmt"
+	"log"
+	"math"
+
+	"github.com/hajimehoshi/ebiten/v2"
+)
+
+const (
+	flashDecay      = 0.85     // 受伤闪烁的强度每帧衰减的比例
+	traumaDecay     = 1.0 / 40 // 震动程度每帧下降的幅度
+	shakeMaxOffset  = 6        // 震动程度为 1 时画面的最大偏移（像素）
+	hitStopFrames   = 4        // 玩家击杀怪物后游戏停顿的帧数
+	chromaFrames    = 30       // 色差脉冲持续的帧数
+	chromaMaxOffset = 4        // 色差脉冲开始时画面边缘的偏移（像素）
+	lowHealth       = 0.35     // 生命值低于上限的这个比例时出现暗角，越低越明显
+	heartbeatFrames = 50       // 低血量暗角随心跳明暗变化的周期（帧）
+)
+
+// screenShader 游戏画面的后期处理着色器，由 resources/shaders/screen.kage 定义
+var screenShader *ebiten.Shader
+
+// EffectToggles 各个画面效果的开关，由设置决定，关闭后减少画面的闪烁和晃动
+type EffectToggles struct {
+	Flash    bool // 受伤时画面闪烁红色
+	Vignette bool // 低血量时画面边缘变暗
+	Shake    bool // 受伤时画面震动
+	HitStop  bool // 击杀怪物时游戏短暂停顿
+	Chroma   bool // 释放无敌技能时画面出现色差
+}
+
+var effectToggles = EffectToggles{Flash: true, Vignette: true, Shake: true, HitStop: true, Chroma: true}
+
+// Effects 受伤闪烁、画面震动、顿帧以及色差等画面效果的状态，不属于游戏的模拟状态，
+// 无界面运行时为 nil，回滚重新模拟时不更新
+type Effects struct {
+	flash   float64 // 受伤闪烁的强度，取值为 0 到 1
+	trauma  float64 // 震动程度，取值为 0 到 1，画面的偏移与它的平方成正比
+	hitStop int     // 剩余的停顿帧数
+	chroma  int     // 色差脉冲剩余的帧数
+	frames  int     // 经过的帧数，用于低血量暗角的心跳
+	rng     Rand    // 画面震动使用自己的随机数，不影响游戏的随机数
+	canvas  *ebiten.Image
+}
+
+func NewEffects() *Effects {
+	e := &Effects{}
+	e.rng.Seed(1)
+	return e
+}
+
+// Clear 开始新的一局时清除所有效果
+func (e *Effects) Clear() {
+	e.flash, e.trauma, e.hitStop, e.chroma = 0, 0, 0, 0
+}
+
+func InitShader() {
+	if err := loadShaders(); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// loadShaders 编译后期处理着色器，无界面运行时不需要着色器
+func loadShaders() error {
+	if headless {
+		return nil
+	}
+	src, err := ReadAsset("shaders/screen.kage")
+	if err != nil {
+		return err
+	}
+	s, err := ebiten.NewShader(src)
+	if err != nil {
+		return fmt.Errorf("screen.kage: %w", err)
+	}
+	if screenShader != nil {
+		screenShader.Deallocate()
+	}
+	screenShader = s
+	return nil
+}
+
+// hurtEffect 玩家受到伤害时画面闪烁并震动，伤害占生命值上限的比例越大效果越强
+func (g *Game) hurtEffect(p *Player, damage float64) {
+	if g.effects == nil || g.resimulating || p.team == TeamNeutral {
+		return
+	}
+	ratio := damage / p.maxHealth
+	g.effects.flash = min(g.effects.flash+3*ratio, 1)
+	g.effects.trauma = min(g.effects.trauma+2*ratio, 1)
+}
+
+// hitStopEffect 玩家击杀怪物时游戏停顿几帧。停顿会改变游戏的进程，联网游戏中不停顿
+func (g *Game) hitStopEffect() {
+	if g.effects == nil || g.resimulating || g.seats != nil || !effectToggles.HitStop {
+		return
+	}
+	g.effects.hitStop = hitStopFrames
+}
+
+// chromaEffect 无敌技能生效时画面出现一次逐渐减弱的色差
+func (g *Game) chromaEffect() {
+	if g.effects == nil || g.resimulating {
+		return
+	}
+	g.effects.chroma = chromaFrames
+}
+
+// hitStopped 是否正处于击杀后的停顿中
+func (g *Game) hitStopped() bool {
+	return g.effects != nil && g.effects.hitStop > 0
+}
+
+// updateEffects 每帧推进所有效果的衰减
+func (g *Game) updateEffects() {
+	e := g.effects
+	if e == nil || g.resimulating {
+		return
+	}
+	e.frames++
+	e.flash *= flashDecay
+	e.trauma = max(e.trauma-traumaDecay, 0)
+	e.hitStop = max(e.hitStop-1, 0)
+	e.chroma = max(e.chroma-1, 0)
+}
+
+// vignette 低血量暗角的强度，按生命值比例最低的未倒地玩家计算，随心跳明暗变化
+func (g *Game) vignette() float64 {
+	if g.mode != config.ModeGame && g.mode != config.ModeLevelUp && g.mode != config.ModeRoundOver && g.mode != config.ModePause {
+		return 0
+	}
+	ratio := 1.0
+	for _, p := range g.players {
+		if !p.downed {
+			ratio = min(ratio, p.health/p.maxHealth)
+		}
+	}
+	if ratio >= lowHealth {
+		return 0
+	}
+	beat := 0.5 + 0.5*math.Sin(2*math.Pi*float64(g.effects.frames)/heartbeatFrames)
+	return min((lowHealth-ratio)/lowHealth, 1) * (0.7 + 0.3*beat)
+}
+
+// effectParams 按设置中的开关计算这一帧各个效果的强度，所有效果都关闭或者已经消失时 active 为 false
+func (g *Game) effectParams() (flash, vignette, chroma, dx, dy float64, active bool) {
+	e := g.effects
+	if e == nil || screenShader == nil {
+		return 0, 0, 0, 0, 0, false
+	}
+	if effectToggles.Flash && e.flash > 0.01 {
+		flash = e.flash
+	}
+	if effectToggles.Vignette {
+		vignette = g.vignette()
+	}
+	if effectToggles.Chroma && e.chroma > 0 {
+		chroma = chromaMaxOffset * float64(e.chroma) / chromaFrames
+	}
+	if effectToggles.Shake && e.trauma > 0 {
+		shake := shakeMaxOffset * e.trauma * e.trauma
+		dx, dy = shake*(2*e.rng.Float64()-1), shake*(2*e.rng.Float64()-1)
+	}
+	return flash, vignette, chroma, dx, dy, flash > 0 || vignette > 0 || chroma > 0 || dx != 0 || dy != 0
+}
+
+// Draw 每次绘制都会调用这个函数。有画面效果时先把画面绘制到离屏图片上，再经过后期处理着色器偏移后绘制到屏幕上
+func (g *Game) Draw(screen *ebiten.Image) {
+	flash, vignette, chroma, dx, dy, active := g.effectParams()
+	if !active {
+		g.drawScene(screen)
+		return
+	}
+	e := g.effects
+	bounds := screen.Bounds()
+	if e.canvas == nil || e.canvas.Bounds() != bounds {
+		if e.canvas != nil {
+			e.canvas.Deallocate()
+		}
+		e.canvas = ebiten.NewImage(bounds.Dx(), bounds.Dy())
+	}
+	e.canvas.Clear()
+	g.drawScene(e.canvas)
+
+	op := &ebiten.DrawRectShaderOptions{}
+	op.Images[0] = e.canvas
+	op.Uniforms = map[string]any{
+		"Flash":    float32(flash),
+		"Vignette": float32(vignette),
+		"Chroma":   float32(chroma),
+	}
+	op.GeoM.Translate(math.Round(dx), math.Round(dy))
+	screen.DrawRectShader(bounds.Dx(), bounds.Dy(), screenShader, op)
+}
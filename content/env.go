@@ -0,0 +1,386 @@
+package main
+
+import (
+	"avoid-the-enemies/content/config"
+	"cmp"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"slices"
+	"sync"
+)
+
+// 强化学习环境：无界面运行单人生存模式，每一步执行一个动作并返回观测、奖励以及游戏是否结束
+// 接口与 Gymnasium 一致，Reset 开始新的一局，Step 返回 observation、reward、terminated、truncated 和 info
+
+// 观测的编码方式
+const (
+	ObservationEntities = "entities" // 按距离排序的实体列表
+	ObservationGrid     = "grid"     // 降采样的多通道网格
+)
+
+// ObsKind 观测中实体的种类
+type ObsKind int
+
+const (
+	ObsMonster ObsKind = iota + 1
+	ObsBullet          // 可以击中玩家的子弹
+	ObsWeapon          // 地图上的武器
+	ObsPickup
+	ObsHazard
+)
+
+// 网格观测的通道：每种实体一个通道，最后一个通道为玩家
+const gridChannels = int(ObsHazard) + 1
+
+// RewardWeights 奖励的组成：存活每秒的奖励、消灭每个怪物的奖励、每点生命值损失的惩罚以及死亡的惩罚
+type RewardWeights struct {
+	Survival float64 `json:"survival"`
+	Kill     float64 `json:"kill"`
+	Damage   float64 `json:"damage"`
+	Death    float64 `json:"death"`
+}
+
+// EnvConfig 环境的设置
+type EnvConfig struct {
+	Observation string        `json:"observation"`  // ObservationEntities 或 ObservationGrid
+	MaxEntities int           `json:"max_entities"` // 实体列表最多包含的实体数量，距离玩家最近的优先
+	GridWidth   int           `json:"grid_width"`   // 网格的列数
+	GridHeight  int           `json:"grid_height"`  // 网格的行数
+	FrameSkip   int           `json:"frame_skip"`   // 每一步重复执行动作的帧数
+	MaxSteps    int           `json:"max_steps"`    // 每局的最大步数，为 0 时不限制
+	Character   string        `json:"character"`    // 使用的角色
+	Reward      RewardWeights `json:"reward"`
+}
+
+func DefaultEnvConfig() EnvConfig {
+	return EnvConfig{
+		Observation: ObservationEntities,
+		MaxEntities: 32,
+		GridWidth:   32,
+		GridHeight:  24,
+		FrameSkip:   4,
+		Reward:      RewardWeights{Survival: 1, Kill: 1, Damage: 0.05, Death: 10},
+	}
+}
+
+// Action 一步的动作，按键类的动作只在这一步的第一帧按下
+type Action struct {
+	X       float64             `json:"x"` // 移动方向，每个分量在 -1 到 1 之间
+	Y       float64             `json:"y"`
+	Fire    bool                `json:"fire"`
+	Dash    bool                `json:"dash"`
+	Skills  [maxSkillSlots]bool `json:"skills"`
+	Upgrade int                 `json:"upgrade"` // 升级时选择的强化序号
+}
+
+func (a Action) input() Input {
+	return Input{
+		X:      clamp(a.X, -1, 1),
+		Y:      clamp(a.Y, -1, 1),
+		Fire:   a.Fire,
+		Dash:   a.Dash,
+		Skills: a.Skills,
+	}
+}
+
+// EntityObs 实体列表中的一个实体，位置为相对玩家中心的偏移，按屏幕尺寸归一化
+type EntityObs struct {
+	Kind  ObsKind `json:"kind"`
+	X     float64 `json:"x"`
+	Y     float64 `json:"y"`
+	Value float64 `json:"value"` // 怪物为生命值比例，道具为道具种类，危险区域为半径，其余为 0
+}
+
+// PlayerObs 玩家自身的状态
+type PlayerObs struct {
+	X           float64             `json:"x"` // 按屏幕尺寸归一化的位置
+	Y           float64             `json:"y"`
+	Health      float64             `json:"health"` // 生命值比例
+	Shield      float64             `json:"shield"`
+	DashCharges float64             `json:"dash_charges"`
+	Score       int                 `json:"score"`
+	Level       int                 `json:"level"`
+	Weapon      string              `json:"weapon"`
+	Stunned     bool                `json:"stunned"`
+	SkillsReady [maxSkillSlots]bool `json:"skills_ready"`       // 技能已经冷却完毕并且积分足够
+	Upgrades    []string            `json:"upgrades,omitempty"` // 升级时可以选择的强化，不为空时下一步的 Upgrade 生效
+}
+
+// Observation 一步之后的观测，按设置只包含实体列表或者网格中的一种
+type Observation struct {
+	Player   PlayerObs   `json:"player"`
+	Entities []EntityObs `json:"entities,omitempty"`
+	Grid     []float32   `json:"grid,omitempty"` // 按通道、行、列排列
+}
+
+// StepInfo 一步之后的附加信息
+type StepInfo struct {
+	Time             float64      `json:"time"` // 本局的存活时间（秒）
+	Score            int          `json:"score"`
+	Kills            int          `json:"kills"`
+	DamageTaken      float64      `json:"damage_taken"`
+	Level            int          `json:"level"`
+	Monsters         int          `json:"monsters"`
+	FinalObservation *Observation `json:"final_observation,omitempty"` // 并行环境自动重新开始时，结束时的观测
+}
+
+// StepResult 一步的结果
+type StepResult struct {
+	Observation Observation `json:"observation"`
+	Reward      float64     `json:"reward"`
+	Terminated  bool        `json:"terminated"` // 玩家死亡，游戏结束
+	Truncated   bool        `json:"truncated"`  // 达到最大步数
+	Info        StepInfo    `json:"info"`
+}
+
+// Env 无界面运行的单人游戏环境
+type Env struct {
+	config EnvConfig
+	game   *Game
+	input  *frameInput
+	steps  int
+	kills  int     // 上一步结束时的击杀数
+	damage float64 // 上一步结束时累计损失的生命值
+}
+
+func NewEnv(cfg EnvConfig) *Env {
+	e := &Env{
+		config: cfg,
+		game:   &Game{profile: NewProfile("")},
+		input:  &frameInput{},
+	}
+	e.game.seats = []Seat{{input: e.input, character: CharacterByID(cfg.Character)}}
+	return e
+}
+
+// Reset 以 seed 开始新的一局，seed 为 0 时使用当前时间
+func (e *Env) Reset(seed int64) Observation {
+	g := e.game
+	g.seed = seed
+	g.init()
+	g.mode = config.ModeGame
+	e.steps, e.kills, e.damage = 0, 0, 0
+	return e.observe()
+}
+
+// Started 是否已经调用过 Reset，之前不能调用 Step
+func (e *Env) Started() bool {
+	return e.game.players != nil
+}
+
+// Step 执行动作 FrameSkip 帧，游戏结束后提前返回，必须先调用 Reset
+func (e *Env) Step(a Action) StepResult {
+	g := e.game
+	p := g.players[0]
+	start := g.clock.Now()
+	in := a.input()
+	for i := 0; i < max(e.config.FrameSkip, 1) && g.mode != config.ModeGameOver; i++ {
+		e.input.in = in
+		// 升级时直接选择动作指定的强化
+		if g.mode == config.ModeLevelUp {
+			g.upgradeCursor = min(max(a.Upgrade, 0), len(g.upgradeChoices)-1)
+			e.input.in = Input{Fire: true}
+		}
+		if err := g.Update(); err != nil {
+			log.Println("env update:", err)
+		}
+		in.Fire, in.Dash, in.Skills = false, false, [maxSkillSlots]bool{}
+	}
+	e.steps++
+
+	w := e.config.Reward
+	r := StepResult{Terminated: g.mode == config.ModeGameOver}
+	r.Truncated = !r.Terminated && e.config.MaxSteps > 0 && e.steps >= e.config.MaxSteps
+	r.Reward = w.Survival*g.clock.Since(start).Seconds() + w.Kill*float64(p.kills-e.kills) - w.Damage*(p.damageTaken-e.damage)
+	if r.Terminated {
+		r.Reward -= w.Death
+	}
+	e.kills, e.damage = p.kills, p.damageTaken
+	r.Observation = e.observe()
+	r.Info = StepInfo{
+		Time:        g.clock.Since(g.startTime).Seconds(),
+		Score:       p.score,
+		Kills:       p.kills,
+		DamageTaken: p.damageTaken,
+		Level:       p.level,
+		Monsters:    len(g.monsters),
+	}
+	return r
+}
+
+// observe 按设置的编码方式生成观测
+func (e *Env) observe() Observation {
+	g := e.game
+	p := g.players[0]
+	obs := Observation{Player: PlayerObs{
+		X:           p.x / config.ScreenWidth,
+		Y:           p.y / config.ScreenHeight,
+		Health:      p.health / p.maxHealth,
+		Shield:      p.Shield(),
+		DashCharges: p.dashCharges,
+		Score:       p.score,
+		Level:       p.level,
+		Stunned:     p.Stunned(),
+	}}
+	if p.weapon != nil {
+		obs.Player.Weapon = p.weapon.GetType()
+	}
+	for i, slot := range p.skills {
+		obs.Player.SkillsReady[i] = slot.Ready(p) && p.score >= e.game.skillCost(slot.skill)
+	}
+	if g.mode == config.ModeLevelUp {
+		for _, upgrade := range g.upgradeChoices {
+			obs.Player.Upgrades = append(obs.Player.Upgrades, upgrade.name)
+		}
+	}
+
+	entities := e.entities()
+	switch e.config.Observation {
+	case ObservationGrid:
+		obs.Grid = e.grid(entities)
+	default:
+		// 实体位置转换为相对玩家中心的偏移，保留距离最近的实体
+		cx, cy := p.x+config.FrameWidth/2, p.y+config.FrameHeight/2
+		for i := range entities {
+			entities[i].X = (entities[i].X - cx) / config.ScreenWidth
+			entities[i].Y = (entities[i].Y - cy) / config.ScreenHeight
+		}
+		slices.SortStableFunc(entities, func(a, b EntityObs) int {
+			return cmp.Compare(math.Hypot(a.X, a.Y), math.Hypot(b.X, b.Y))
+		})
+		obs.Entities = entities[:min(len(entities), e.config.MaxEntities)]
+	}
+	return obs
+}
+
+// entities 地图上所有实体的中心位置（屏幕坐标）
+func (e *Env) entities() []EntityObs {
+	g := e.game
+	p := g.players[0]
+	var entities []EntityObs
+	for _, id := range sortedKeys(g.monsters) {
+		m := g.monsters[id]
+		entities = append(entities, EntityObs{Kind: ObsMonster, X: m.x + config.FrameWidth/2, Y: m.y + config.FrameHeight/2, Value: m.health / m.maxHealth})
+	}
+	for _, id := range sortedKeys(g.suspends) {
+		s := g.suspends[id]
+		if g.canHit(s, p) {
+			entities = append(entities, EntityObs{Kind: ObsBullet, X: s.pos[0], Y: s.pos[1]})
+		}
+	}
+	for _, id := range sortedKeys(g.weaponPosition) {
+		pos := g.weaponPosition[id]
+		entities = append(entities, EntityObs{Kind: ObsWeapon, X: pos[0] + config.FrameWidth/2, Y: pos[1] + config.FrameHeight/2})
+	}
+	for _, id := range sortedKeys(g.pickups) {
+		pickup := g.pickups[id]
+		entities = append(entities, EntityObs{Kind: ObsPickup, X: pickup.pos[0] + config.FrameWidth/2, Y: pickup.pos[1] + config.FrameHeight/2, Value: float64(pickup.kind)})
+	}
+	for _, id := range sortedKeys(g.hazards) {
+		h := g.hazards[id]
+		x, y := h.Center()
+		entities = append(entities, EntityObs{Kind: ObsHazard, X: x, Y: y, Value: h.radius / config.ScreenWidth})
+	}
+	return entities
+}
+
+// grid 将实体按所在的格子累加到对应的通道，最后一个通道标记玩家所在的格子
+func (e *Env) grid(entities []EntityObs) []float32 {
+	w, h := e.config.GridWidth, e.config.GridHeight
+	grid := make([]float32, gridChannels*w*h)
+	add := func(channel int, x, y float64) {
+		col := int(clamp(x/config.ScreenWidth*float64(w), 0, float64(w-1)))
+		row := int(clamp(y/config.ScreenHeight*float64(h), 0, float64(h-1)))
+		grid[(channel*h+row)*w+col]++
+	}
+	for _, entity := range entities {
+		add(int(entity.Kind)-1, entity.X, entity.Y)
+	}
+	p := e.game.players[0]
+	add(gridChannels-1, p.x+config.FrameWidth/2, p.y+config.FrameHeight/2)
+	return grid
+}
+
+// VecEnv 并行运行的多个环境，每个环境在自己的 goroutine 中执行一步
+type VecEnv struct {
+	envs []*Env
+}
+
+func NewVecEnv(n int, cfg EnvConfig) *VecEnv {
+	v := &VecEnv{}
+	for i := 0; i < n; i++ {
+		v.envs = append(v.envs, NewEnv(cfg))
+	}
+	return v
+}
+
+func (v *VecEnv) Len() int {
+	return len(v.envs)
+}
+
+// each 在每个环境自己的 goroutine 中执行 f，全部完成后返回。
+// 某个环境 panic 时恢复并作为错误返回，不会使整个服务器崩溃
+func (v *VecEnv) each(f func(i int, e *Env)) error {
+	var wg sync.WaitGroup
+	errs := make([]error, len(v.envs))
+	for i, e := range v.envs {
+		wg.Add(1)
+		go func(i int, e *Env) {
+			defer wg.Done()
+			defer func() {
+				if r := recover(); r != nil {
+					errs[i] = fmt.Errorf("env %d: %v", i, r)
+				}
+			}()
+			f(i, e)
+		}(i, e)
+	}
+	wg.Wait()
+	return errors.Join(errs...)
+}
+
+// Reset 以对应的种子重新开始所有环境，seeds 为空时都使用当前时间
+func (v *VecEnv) Reset(seeds []int64) ([]Observation, error) {
+	if len(seeds) != 0 && len(seeds) != len(v.envs) {
+		return nil, fmt.Errorf("got %d seeds for %d envs", len(seeds), len(v.envs))
+	}
+	obs := make([]Observation, len(v.envs))
+	err := v.each(func(i int, e *Env) {
+		var seed int64
+		if len(seeds) != 0 {
+			seed = seeds[i]
+		}
+		obs[i] = e.Reset(seed)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return obs, nil
+}
+
+// Step 每个环境执行对应的动作，结束的环境以取自其随机数生成器的种子自动重新开始，
+// 结束时的观测保存在 Info.FinalObservation 中
+func (v *VecEnv) Step(actions []Action) ([]StepResult, error) {
+	if len(actions) != len(v.envs) {
+		return nil, fmt.Errorf("got %d actions for %d envs", len(actions), len(v.envs))
+	}
+	if slices.ContainsFunc(v.envs, func(e *Env) bool { return !e.Started() }) {
+		return nil, errors.New("reset first")
+	}
+	results := make([]StepResult, len(v.envs))
+	err := v.each(func(i int, e *Env) {
+		r := e.Step(actions[i])
+		if r.Terminated || r.Truncated {
+			final := r.Observation
+			r.Info.FinalObservation = &final
+			r.Observation = e.Reset(int64(e.game.rng.Uint64()) | 1)
+		}
+		results[i] = r
+	})
+	if err != nil {
+		return nil, err
+	}
+	return results, nil
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"flag"
+	"log"
+	"net"
+)
+
+// 训练程序通过 TCP 连接驱动环境，每行一个 JSON 请求，服务器对每个请求回复一行 JSON：
+//   {"cmd": "spec"}                        -> {"spec": {...}}
+//   {"cmd": "reset", "seeds": [1, 2]}      -> {"observations": [...]}
+//   {"cmd": "step", "actions": [{...}]}    -> {"results": [...]}
+// 出错时回复 {"error": "..."}，每个连接有自己的一组并行环境，reset 之前的 step 回复 {"error": "reset first"}
+
+// envRequest 训练程序发送的请求
+type envRequest struct {
+	Cmd     string   `json:"cmd"`
+	Seeds   []int64  `json:"seeds,omitempty"`
+	Actions []Action `json:"actions,omitempty"`
+}
+
+// EnvSpec 环境的设置以及观测的形状，训练程序据此创建观测空间和动作空间
+type EnvSpec struct {
+	Envs        int       `json:"envs"`
+	Config      EnvConfig `json:"config"`
+	GridShape   []int     `json:"grid_shape,omitempty"` // 网格观测的形状：通道、行、列
+	EntityKinds int       `json:"entity_kinds"`         // 实体种类的数量，种类从 1 开始
+	SkillSlots  int       `json:"skill_slots"`
+}
+
+type envResponse struct {
+	Spec         *EnvSpec      `json:"spec,omitempty"`
+	Observations []Observation `json:"observations,omitempty"`
+	Results      []StepResult  `json:"results,omitempty"`
+	Error        string        `json:"error,omitempty"`
+}
+
+// RunEnv 运行强化学习环境服务器，用法：avoid-the-enemies env [-addr 127.0.0.1:7878] [-envs 8] [-obs entities|grid] [flags]
+func RunEnv(args []string) {
+	cfg := DefaultEnvConfig()
+	fs := flag.NewFlagSet("env", flag.ExitOnError)
+	addr := fs.String("addr", "127.0.0.1:7878", "TCP address to listen on")
+	envs := fs.Int("envs", 1, "number of parallel envs per connection")
+	fs.StringVar(&cfg.Observation, "obs", cfg.Observation, "observation encoding, entities or grid")
+	fs.IntVar(&cfg.MaxEntities, "max-entities", cfg.MaxEntities, "max entities in the entity list observation")
+	fs.IntVar(&cfg.GridWidth, "grid-width", cfg.GridWidth, "columns of the grid observation")
+	fs.IntVar(&cfg.GridHeight, "grid-height", cfg.GridHeight, "rows of the grid observation")
+	fs.IntVar(&cfg.FrameSkip, "frame-skip", cfg.FrameSkip, "frames each action is repeated")
+	fs.IntVar(&cfg.MaxSteps, "max-steps", cfg.MaxSteps, "steps before an episode is truncated, 0 for no limit")
+	fs.StringVar(&cfg.Character, "character", cfg.Character, "character id")
+	fs.Float64Var(&cfg.Reward.Survival, "reward-survival", cfg.Reward.Survival, "reward per second survived")
+	fs.Float64Var(&cfg.Reward.Kill, "reward-kill", cfg.Reward.Kill, "reward per monster killed")
+	fs.Float64Var(&cfg.Reward.Damage, "reward-damage", cfg.Reward.Damage, "penalty per point of health lost")
+	fs.Float64Var(&cfg.Reward.Death, "reward-death", cfg.Reward.Death, "penalty for dying")
+	fs.Parse(args)
+	if cfg.Observation != ObservationEntities && cfg.Observation != ObservationGrid {
+		log.Fatalf("unknown observation encoding %q", cfg.Observation)
+	}
+	if *envs < 1 || cfg.MaxEntities < 1 || cfg.GridWidth < 1 || cfg.GridHeight < 1 || cfg.FrameSkip < 1 {
+		log.Fatal("envs, max-entities, grid size and frame-skip must be positive")
+	}
+
+	headless = true
+	Init()
+	ln, err := net.Listen("tcp", *addr)
+	if err != nil {
+		log.Fatal(err)
+	}
+	log.Println("env server listening on", ln.Addr())
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			log.Fatal(err)
+		}
+		go serveEnv(conn, NewVecEnv(*envs, cfg), cfg)
+	}
+}
+
+// serveEnv 处理一个训练程序的请求，直到连接关闭
+func serveEnv(conn net.Conn, v *VecEnv, cfg EnvConfig) {
+	defer conn.Close()
+	// 一个训练程序的错误请求只断开它自己的连接，不影响其他连接
+	defer func() {
+		if r := recover(); r != nil {
+			log.Printf("trainer %s: %v", conn.RemoteAddr(), r)
+		}
+	}()
+	log.Println("trainer connected from", conn.RemoteAddr())
+	scanner := bufio.NewScanner(conn)
+	scanner.Buffer(nil, 1<<24)
+	w := bufio.NewWriter(conn)
+	encoder := json.NewEncoder(w)
+	for scanner.Scan() {
+		var req envRequest
+		var resp envResponse
+		if err := json.Unmarshal(scanner.Bytes(), &req); err != nil {
+			resp.Error = err.Error()
+		} else {
+			switch req.Cmd {
+			case "spec":
+				resp.Spec = &EnvSpec{Envs: v.Len(), Config: cfg, EntityKinds: int(ObsHazard), SkillSlots: maxSkillSlots}
+				if cfg.Observation == ObservationGrid {
+					resp.Spec.GridShape = []int{gridChannels, cfg.GridHeight, cfg.GridWidth}
+				}
+			case "reset":
+				resp.Observations, err = v.Reset(req.Seeds)
+			case "step":
+				resp.Results, err = v.Step(req.Actions)
+			default:
+				resp.Error = "unknown cmd " + req.Cmd
+			}
+			if err != nil {
+				resp.Error = err.Error()
+			}
+		}
+		if err := encoder.Encode(&resp); err != nil {
+			break
+		}
+		if err := w.Flush(); err != nil {
+			break
+		}
+	}
+	log.Println("trainer disconnected from", conn.RemoteAddr())
+}
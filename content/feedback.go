@@ -0,0 +1,169 @@
+package main
+
+import (
+	"avoid-the-enemies/content/config"
+	"image/color"
+	"math"
+	"strconv"
+
+	"github.com/hajimehoshi/ebiten/v2"
+)
+
+const (
+	damageNumberFrames = 45  // 伤害数字显示的帧数
+	damageNumberFade   = 15  // 伤害数字在最后这么多帧中淡出
+	damageNumberMerge  = 12  // 同一人物在这么多帧内受到的伤害合并到同一个数字中，持续伤害不会刷屏
+	damageNumberRise   = 0.4 // 伤害数字每帧上升的距离
+	comboWindow        = 120 // 两次击杀的间隔不超过这么多帧时连击数增加
+	comboMin           = 2   // 连击数达到这个值时才显示
+	wavePulseFrames    = 60  // 波次增加后波次数字放大显示的帧数
+)
+
+// Feedback 伤害数字、连击数以及波次提示等只用于画面表现的状态，不属于游戏的模拟状态，
+// 无界面运行时为 nil，回滚重新模拟时不更新
+type Feedback struct {
+	numbers    []*damageNumber
+	combos     map[int]*combo // 按玩家 id 记录的连击
+	wave       int            // 上一帧的波次
+	waveFrames int            // 波次增加后经过的帧数
+}
+
+// damageNumber 人物头顶飘起的伤害数字
+type damageNumber struct {
+	target int     // 受到伤害的人物的 id
+	x, y   float64 // 数字底部中心的位置
+	value  float64
+	color  color.RGBA
+	frames int // 显示了的帧数
+}
+
+// combo 一名玩家的连续击杀
+type combo struct {
+	count  int
+	frames int // 距离上次击杀的帧数
+}
+
+func NewFeedback() *Feedback {
+	return &Feedback{combos: make(map[int]*combo)}
+}
+
+// Clear 开始新的一局时清空所有状态
+func (f *Feedback) Clear() {
+	f.numbers = f.numbers[:0]
+	clear(f.combos)
+	f.wave, f.waveFrames = 0, 0
+}
+
+// showDamage 在人物头顶显示受到的伤害，玩家受到的伤害为红色
+func (g *Game) showDamage(p *Player, damage float64) {
+	if g.feedback == nil || g.resimulating {
+		return
+	}
+	x, y := p.x+config.FrameWidth/2, p.y-6
+	for _, n := range g.feedback.numbers {
+		if n.target == p.id && n.frames < damageNumberMerge {
+			n.value += damage
+			n.x, n.y, n.frames = x, y, 0
+			return
+		}
+	}
+	c := color.RGBA{0xFF, 0xFF, 0xFF, 0xFF}
+	if p.team != TeamNeutral {
+		c = color.RGBA{0xFF, 0x40, 0x40, 0xFF}
+	}
+	g.feedback.numbers = append(g.feedback.numbers, &damageNumber{target: p.id, x: x, y: y, value: damage, color: c})
+}
+
+// countKill 记录玩家的一次击杀，间隔足够短时连击数增加
+func (g *Game) countKill(killer *Player) {
+	if g.feedback == nil || g.resimulating {
+		return
+	}
+	c, ok := g.feedback.combos[killer.id]
+	if !ok || c.frames > comboWindow {
+		c = &combo{}
+		g.feedback.combos[killer.id] = c
+	}
+	c.count++
+	c.frames = 0
+}
+
+// updateFeedback 推进伤害数字和连击计时，记录波次的变化
+func (g *Game) updateFeedback() {
+	f := g.feedback
+	if f == nil || g.resimulating {
+		return
+	}
+	numbers := f.numbers[:0]
+	for _, n := range f.numbers {
+		n.frames++
+		n.y -= damageNumberRise
+		if n.frames < damageNumberFrames {
+			numbers = append(numbers, n)
+		}
+	}
+	f.numbers = numbers
+	for id, c := range f.combos {
+		c.frames++
+		if c.frames > comboWindow {
+			delete(f.combos, id)
+		}
+	}
+	f.waveFrames++
+	if w := g.wave(); w != f.wave {
+		f.wave, f.waveFrames = w, 0
+	}
+}
+
+// DrawDamageNumbers 在人物头顶绘制伤害数字，不足 1 点的伤害不显示
+func DrawDamageNumbers(screen *ebiten.Image, g *Game) {
+	if g.feedback == nil {
+		return
+	}
+	for _, n := range g.feedback.numbers {
+		value := int(math.Round(n.value))
+		if value < 1 {
+			continue
+		}
+		alpha := float64(damageNumberFrames-n.frames) / damageNumberFade
+		label := &Label{Text: strconv.Itoa(value), FontSize: 6, Color: fade(n.color, alpha)}
+		hud.DrawAt(screen, AnchorBottom, n.x, n.y, label)
+	}
+}
+
+// DrawComboHUD 在屏幕右上角绘制每名玩家的连击数，下方的进度条为连击剩余的时间
+func DrawComboHUD(screen *ebiten.Image, g *Game) {
+	if g.feedback == nil {
+		return
+	}
+	y := 14.0 // 联网对战时右上角第一行是延迟信息
+	for i, p := range g.players {
+		c, ok := g.feedback.combos[p.id]
+		if !ok || c.count < comboMin {
+			continue
+		}
+		clr := color.RGBA{0xFF, 0xE0, 0x30, 0xFF}
+		if len(g.players) > 1 {
+			clr = playerColors[i%len(playerColors)]
+		}
+		label := &Label{Text: T("hud.combo", c.count), FontSize: config.FontSize, Color: clr}
+		hud.Place(screen, AnchorTopRight, -3, y, label)
+		_, h := label.Size()
+		bar := &Bar{Width: 48, Height: 2, Value: 1 - float64(c.frames)/comboWindow, Color: clr, Back: color.RGBA{0x40, 0x40, 0x40, 0xFF}}
+		hud.Place(screen, AnchorTopRight, -3, y+h+2, bar)
+		y += h + 8
+	}
+}
+
+// DrawWaveHUD 在存活时间下方绘制当前的波次，波次增加时放大并闪烁
+func DrawWaveHUD(screen *ebiten.Image, g *Game) {
+	size, clr := float64(config.FontSize), color.RGBA{0xFF, 0xFF, 0xFF, 0xFF}
+	if g.feedback != nil && g.feedback.waveFrames < wavePulseFrames {
+		t := 1 - float64(g.feedback.waveFrames)/wavePulseFrames
+		size = config.FontSize + math.Round(8*t)/2 // 按半个字号取整，避免缓存过多字号的字体
+		if g.feedback.waveFrames/8%2 == 0 {
+			clr = color.RGBA{0xFF, 0xE0, 0x30, 0xFF}
+		}
+	}
+	hud.Place(screen, AnchorTop, 0, 3+config.FontSize+3, &Label{Text: T("hud.wave", g.wave()), FontSize: size, Color: clr})
+}
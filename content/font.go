@@ -2,20 +2,162 @@ package main
 
 import (
 	"bytes"
-	"github.com/hajimehoshi/ebiten/v2/examples/resources/fonts"
+	"errors"
+	"fmt"
 	"github.com/hajimehoshi/ebiten/v2/text/v2"
+	"golang.org/x/image/font/sfnt"
+	"io/fs"
 	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"unicode"
 )
 
 var (
 	arcadeFaceSource *text.GoTextFaceSource
+	arcadeGlyphs     *sfnt.Font                    // 像素字体的字形表，用于检查当前语言的字符是否都有字形
+	fallbackSources  []*text.GoTextFaceSource      // 当前语言的后备字体，像素字体中没有的字符依次从中查找
+	arcadeFaces      = make(map[float64]text.Face) // 按字号缓存的字体，只在绘制时使用
 )
 
 func InitFont() {
 	// 加载字体
-	s, err := text.NewGoTextFaceSource(bytes.NewReader(fonts.PressStart2P_ttf))
-	if err != nil {
+	if err := loadFonts(); err != nil {
 		log.Fatal(err)
 	}
-	arcadeFaceSource = s
+}
+
+// loadFonts 加载像素字体以及当前语言的后备字体，重新加载时清空按字号缓存的字体
+func loadFonts() error {
+	raw, err := ReadAsset("fonts/pressstart2p.ttf")
+	if err != nil {
+		return err
+	}
+	s, err := text.NewGoTextFaceSource(bytes.NewReader(raw))
+	if err != nil {
+		return fmt.Errorf("pressstart2p.ttf: %w", err)
+	}
+	glyphs, err := sfnt.Parse(raw)
+	if err != nil {
+		return fmt.Errorf("pressstart2p.ttf: %w", err)
+	}
+	arcadeFaceSource, arcadeGlyphs = s, glyphs
+	loadFallbackFonts()
+	return nil
+}
+
+// loadFallbackFonts 按顺序从当前语言的后备字体中选择字体，只使用包含前面的字体中没有的字符的字体，
+// 跳过不存在或者无法解析的字体。所有字体中都没有的字符会打印出来，提示需要在资源包中提供包含这些字符的字体
+func loadFallbackFonts() {
+	clear(arcadeFaces)
+	fallbackSources = nil
+	if currentLocale == nil || arcadeGlyphs == nil {
+		return
+	}
+	glyphs := []*sfnt.Font{arcadeGlyphs}
+	missing := missingGlyphs(currentLocale, glyphs)
+	for _, file := range currentLocale.Fonts {
+		if len(missing) == 0 {
+			break
+		}
+		s, f, err := loadFallbackFont(file)
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			log.Printf("locale %s: %s: %v", currentLocale.ID, file, err)
+			continue
+		}
+		rest := missingGlyphs(currentLocale, append(glyphs, f))
+		if len(rest) == len(missing) {
+			continue
+		}
+		fallbackSources = append(fallbackSources, s)
+		glyphs = append(glyphs, f)
+		missing = rest
+	}
+	if len(missing) > 0 {
+		log.Printf("locale %s: no font has %d characters: %s", currentLocale.ID, len(missing), string(missing))
+	}
+}
+
+// loadFallbackFont 加载一个后备字体，绝对路径为系统中的字体，否则为相对于 resources 的路径。
+// 字体集合（.ttc）只使用其中的第一个字体
+func loadFallbackFont(file string) (*text.GoTextFaceSource, *sfnt.Font, error) {
+	var raw []byte
+	var err error
+	if filepath.IsAbs(file) {
+		raw, err = os.ReadFile(file)
+	} else {
+		raw, err = ReadAsset(file)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	if path.Ext(file) != ".ttc" {
+		s, err := text.NewGoTextFaceSource(bytes.NewReader(raw))
+		if err != nil {
+			return nil, nil, err
+		}
+		f, err := sfnt.Parse(raw)
+		return s, f, err
+	}
+	sources, err := text.NewGoTextFaceSourcesFromCollection(bytes.NewReader(raw))
+	if err != nil {
+		return nil, nil, err
+	}
+	c, err := sfnt.ParseCollection(raw)
+	if err != nil {
+		return nil, nil, err
+	}
+	f, err := c.Font(0)
+	return sources[0], f, err
+}
+
+// missingGlyphs 语言 l 的字符串中所有字体都没有字形的字符，按码位排序
+func missingGlyphs(l *Locale, fonts []*sfnt.Font) []rune {
+	var buf sfnt.Buffer
+	seen := make(map[rune]bool)
+	var missing []rune
+	for _, s := range l.Strings {
+		for _, r := range s {
+			if seen[r] || unicode.IsSpace(r) {
+				continue
+			}
+			seen[r] = true
+			if !slices.ContainsFunc(fonts, func(f *sfnt.Font) bool {
+				i, err := f.GlyphIndex(&buf, r)
+				return err == nil && i != 0
+			}) {
+				missing = append(missing, r)
+			}
+		}
+	}
+	slices.Sort(missing)
+	return missing
+}
+
+// arcadeFace 返回指定字号的像素字体，同一字号只创建一次。
+// 当前语言有后备字体时，像素字体中没有的字符使用按语言的比例放大的后备字体绘制
+func arcadeFace(size float64) text.Face {
+	face, ok := arcadeFaces[size]
+	if !ok {
+		face = &text.GoTextFace{Source: arcadeFaceSource, Size: size}
+		if len(fallbackSources) > 0 {
+			faces := []text.Face{face}
+			for _, s := range fallbackSources {
+				faces = append(faces, &text.GoTextFace{Source: s, Size: size * currentLocale.FontScale})
+			}
+			multi, err := text.NewMultiFace(faces...)
+			if err != nil {
+				log.Println("fallback font:", err)
+			} else {
+				face = multi
+			}
+		}
+		arcadeFaces[size] = face
+	}
+	return face
 }
@@ -0,0 +1,29 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+
+	"golang.org/x/image/font/sfnt"
+)
+
+// TestLocaleGlyphs 每种语言只用内置的字体就能显示所有的字符，不依赖系统中的字体
+func TestLocaleGlyphs(t *testing.T) {
+	for _, l := range localeList {
+		glyphs := []*sfnt.Font{arcadeGlyphs}
+		for _, file := range l.Fonts {
+			if filepath.IsAbs(file) {
+				t.Errorf("locale %s: %s is a system font", l.ID, file)
+				continue
+			}
+			_, f, err := loadFallbackFont(file)
+			if err != nil {
+				t.Fatalf("locale %s: %s: %v", l.ID, file, err)
+			}
+			glyphs = append(glyphs, f)
+		}
+		if missing := missingGlyphs(l, glyphs); len(missing) > 0 {
+			t.Errorf("locale %s: no font has %d characters: %s", l.ID, len(missing), string(missing))
+		}
+	}
+}
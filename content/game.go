@@ -2,21 +2,17 @@ package main
 
 import (
 	"avoid-the-enemies/content/config"
-	"bytes"
-	"image"
 	"image/color"
 	"log"
 	"math"
-	"strconv"
+	"os"
 	"time"
 
 	"github.com/hajimehoshi/ebiten/v2"
-	"github.com/hajimehoshi/ebiten/v2/audio"
-	"github.com/hajimehoshi/ebiten/v2/audio/wav"
 	"github.com/hajimehoshi/ebiten/v2/ebitenutil"
-	raudio "github.com/hajimehoshi/ebiten/v2/examples/resources/audio"
 	"github.com/hajimehoshi/ebiten/v2/inpututil"
 	"github.com/hajimehoshi/ebiten/v2/text/v2"
+	"github.com/hajimehoshi/ebiten/v2/vector"
 	"golang.org/x/image/math/f64"
 
 	"avoid-the-enemies/content/utils"
@@ -24,7 +20,14 @@ import (
 
 type Game struct {
 	mode                     config.Mode
-	player                   *Player
+	players                  []*Player // 本地的所有玩家，单人游戏时只有一个
+	playerCount              int       // 标题界面选择的玩家数量
+	seats                    []Seat    // 联网游戏时由服务器指定的玩家，为空时按 playerCount 分配本地的操作来源
+	startTime                time.Time // 游戏开始的时间
+	clock                    *Clock    // 本局游戏的时钟
+	rng                      Rand      // 本局游戏的随机数生成器
+	seed                     int64     // 随机数种子，为 0 时每局使用当前时间
+	resimulating             bool      // 回滚后正在重新模拟，不播放音效
 	uniqueId                 int
 	monsters                 map[int]*Player
 	monsterTarget            map[int]f64.Vec2 // 记录每个怪物的目标位置
@@ -34,89 +37,347 @@ type Game struct {
 	weaponPositionBeenPicked map[int]bool     // 某个武器位置是否已经被某个怪物标记为了目标
 	weapons                  map[int]Weapon
 	suspends                 map[int]*Suspend
-	hitPlayer                *audio.Player
+	hazardTimer              time.Time // 危险区域刷新时间
+	hazards                  map[int]*Hazard
+	pickupTimer              time.Time // 道具刷新时间
+	pickups                  map[int]*Pickup
+	timeScale                float64      // 怪物与怪物子弹的时间流速，1 为正常速度
+	decoy                    *f64.Vec2    // 诱饵的位置，存在诱饵时怪物以诱饵为目标
+	equippedSkills           []string     // 玩家装备的技能，与 skillKeys 一一对应
+	upgradeChoices           []*Upgrade   // 升级时提供的强化选择
+	levelUpPlayer            *Player      // 正在选择强化的玩家
+	upgradeCursor            int          // 当前选中的强化
+	profile                  *Profile     // 跨局保存的玩家档案
+	coinsEarned              int          // 本局获得的金币
+	shopCursor               int          // 商店中选中的商品
+	shopMessage              string       // 商店中购买失败的提示的字符串键
+	settingsCursor           int          // 设置界面中选中的设置项
+	settingsReturn           config.Mode  // 设置界面返回的界面
+	characterCursor          int          // 角色选择界面中选中的角色
+	characterFrame           int          // 角色选择界面的动画帧数
+	arena                    *ArenaRules  // 竞技场的规则，为 nil 时为生存模式
+	round                    int          // 竞技场当前的回合，从 1 开始
+	roundWins                map[Team]int // 竞技场中每个阵营赢下的回合数
+	roundWinner              Team         // 上一回合获胜的阵营，平局时为 TeamNeutral
+	roundOverFrames          int          // 回合结束后经过的帧数
+	autosaveTimer            time.Time    // 上次存档的时间
+	canContinue              bool         // 是否有可以继续的存档
+	bot                      *BotProfile  // 本地游戏中由电脑控制 1P 时的难度，为 nil 时由玩家控制
+	balance                  *Balance     // 平衡参数，为 nil 时使用默认值
+	stats                    *RunStats    // 统计数据，为 nil 时不记录
+	particles                *Particles   // 粒子效果，无界面运行时为 nil
+	feedback                 *Feedback    // 伤害数字和连击数，无界面运行时为 nil
+	effects                  *Effects     // 受伤闪烁、画面震动等画面效果，无界面运行时为 nil
+	batch                    SpriteBatch  // 绘制大量图集中的图片时复用的批次
 }
 
 func (g *Game) init() {
 	g.mode = config.ModeTitle
-	g.player = &Player{
-		x:                 config.ScreenWidth/2 - config.FrameWidth/2,
-		y:                 config.ScreenHeight/2 - config.FrameHeight/2,
-		speed:             2.0, // 您可以根据需要调整这个值
-		weaponX:           config.FrameWidth / 2,
-		weaponY:           config.FrameHeight / 2,
-		health:            100,
-		lastCollisionTime: time.Now(),
-		directIdx:         0,
-		id:                1,
-		score:             0,
-		isSkill:           false,
-		skillFrame:        0,
-		startTime:         time.Now(),
+	character := CharacterByID(g.profile.Character)
+	if g.equippedSkills == nil {
+		g.equippedSkills = []string{"invincible", "shockwave"}
+	}
+	if g.playerCount == 0 {
+		g.playerCount = 1
+	}
+	// 每个玩家分配一个操作来源，玩家在屏幕中央横向排开
+	seats := g.seats
+	if seats == nil {
+		for _, source := range InputSources(g.playerCount) {
+			seats = append(seats, Seat{input: source, character: character})
+		}
+		if g.bot != nil {
+			seats[0].input = NewBot(g, 1, g.bot, time.Now().UnixNano())
+		}
+	}
+	// 每局游戏使用新的时钟和随机数种子，相同的种子和操作得到相同的结果
+	g.clock = NewClock()
+	seed := g.seed
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+	g.rng.Seed(seed)
+	if g.particles == nil && !headless {
+		g.particles = NewParticles()
+	}
+	if g.particles != nil {
+		g.particles.Clear()
+	}
+	if g.feedback == nil && !headless {
+		g.feedback = NewFeedback()
+	}
+	if g.feedback != nil {
+		g.feedback.Clear()
+	}
+	if g.effects == nil && !headless {
+		g.effects = NewEffects()
+	}
+	if g.effects != nil {
+		g.effects.Clear()
+	}
+	g.players = nil
+	for i, seat := range seats {
+		player := NewCharacterPlayer(seat.character, i+1, g.clock)
+		player.x += (float64(i) - float64(len(seats)-1)/2) * config.FrameWidth
+		player.input = seat.input
+		g.equipSkills(player)
+		g.profile.ApplyProfile(player)
+		g.tuneWeapon(player.weapon)
+		g.players = append(g.players, player)
 	}
 	g.monsters = make(map[int]*Player)
 	g.monsterTarget = make(map[int]f64.Vec2)
 	g.monsterTimer = make(map[int]int)
-	g.weaponTimer = time.Now()
+	g.weaponTimer = g.clock.Now()
 	g.weaponPosition = make(map[int]f64.Vec2)
 	g.weaponPositionBeenPicked = make(map[int]bool)
 	g.weapons = make(map[int]Weapon)
 	g.suspends = make(map[int]*Suspend)
-	g.uniqueId = 1
-
-	if audioContext == nil {
-		audioContext = audio.NewContext(48000)
+	g.hazardTimer = g.clock.Now()
+	g.hazards = make(map[int]*Hazard)
+	g.pickupTimer = g.clock.Now()
+	g.pickups = make(map[int]*Pickup)
+	g.assignTeams()
+	if g.arena != nil {
+		g.setupArena()
 	}
-	jabD, err := wav.DecodeWithoutResampling(bytes.NewReader(raudio.Jab_wav))
-	if err != nil {
-		log.Fatal(err)
-	}
-	g.hitPlayer, err = audioContext.NewPlayer(jabD)
-	if err != nil {
-		log.Fatal(err)
+	g.uniqueId = len(g.players)
+	g.startTime = g.clock.Now()
+	g.timeScale = 1
+	g.decoy = nil
+	g.autosaveTimer = g.clock.Now()
+	if g.canSaveRun() {
+		_, err := os.Stat(g.profile.RunSavePath())
+		g.canContinue = err == nil
 	}
+
 }
 
 func (g *Game) Update() error {
+	// 开发模式下重新加载改动的资源，角色数量可能改变
+	reloadAssets()
+	g.characterCursor = min(g.characterCursor, len(characters)-1)
 	switch g.mode {
 	case config.ModeTitle:
-		if ebiten.IsKeyPressed(ebiten.KeySpace) {
-			g.mode = config.ModeGame
-			g.player.startTime = time.Now()
+		// 在标题界面按技能键切换该技能槽装备的技能
+		for i, key := range skillKeys {
+			if inpututil.IsKeyJustPressed(key) {
+				g.equippedSkills[i] = NextSkillName(g.equippedSkills[i], func(name string) bool { return g.skillEquippable(i, name) })
+				for _, player := range g.players {
+					g.equipSkills(player)
+				}
+			}
+		}
+		// 按 p 键切换玩家数量，超过可用的操作来源数量时回到单人游戏
+		if inpututil.IsKeyJustPressed(ebiten.KeyP) {
+			g.playerCount = g.playerCount%min(config.MaxPlayers, len(InputSources(config.MaxPlayers))) + 1
+			g.init()
+		}
+		// 按 b 键切换由电脑控制 1P 的难度
+		if inpututil.IsKeyJustPressed(ebiten.KeyB) {
+			g.bot = nextBotProfile(g.bot)
+			g.init()
+		}
+		// 按 v 键切换生存模式和竞技场，竞技场中按 t、f、m、g 键切换回合时长、友军伤害、怪物和阵营数量
+		if inpututil.IsKeyJustPressed(ebiten.KeyV) {
+			if g.arena == nil {
+				g.arena = DefaultArenaRules()
+			} else {
+				g.arena = nil
+			}
+			g.init()
+		}
+		if g.arena != nil {
+			g.resolveArenaOptions()
+		}
+		// 按 c 键继续上次没有结束的游戏
+		if g.canContinue && inpututil.IsKeyJustPressed(ebiten.KeyC) {
+			g.continueRun()
+			return nil
+		}
+		// 按 s 键进入商店
+		if inpututil.IsKeyJustPressed(ebiten.KeyS) {
+			g.mode = config.ModeShop
+			g.shopCursor = 0
+		}
+		// 按 o 键进入设置界面
+		if inpututil.IsKeyJustPressed(ebiten.KeyO) {
+			g.openSettings()
+		}
+		// 按空格键进入角色选择界面
+		if inpututil.IsKeyJustPressed(ebiten.KeySpace) {
+			g.mode = config.ModeCharacterSelect
+			g.characterCursor = 0
+			for i, c := range characters {
+				if c == g.players[0].character {
+					g.characterCursor = i
+				}
+			}
 		}
 	case config.ModeGame:
+		// 按 F5 键快速存档，按 Esc 键暂停，只对本地游戏生效
+		if g.seats == nil && inpututil.IsKeyJustPressed(ebiten.KeyF5) {
+			g.saveRun()
+		}
+		if g.seats == nil && inpututil.IsKeyJustPressed(ebiten.KeyEscape) {
+			g.mode = config.ModePause
+			return nil
+		}
+		// 击杀怪物后的停顿期间游戏不前进
+		if g.hitStopped() {
+			break
+		}
 		if err := g.resolveModeGame(); err != nil {
 			return err
 		}
+		g.updateParticles()
+		g.updateFeedback()
+	case config.ModeLevelUp:
+		g.resolveModeLevelUp()
+	case config.ModeRoundOver:
+		g.resolveModeRoundOver()
+	case config.ModeShop:
+		g.resolveModeShop()
+	case config.ModeCharacterSelect:
+		g.resolveModeCharacterSelect()
+	case config.ModeSettings:
+		g.resolveModeSettings()
+	case config.ModePause:
+		g.resolveModePause()
 	case config.ModeGameOver:
-		if ebiten.IsKeyPressed(ebiten.KeySpace) {
+		if inpututil.IsKeyJustPressed(ebiten.KeySpace) {
 			g.init()
 			g.mode = config.ModeTitle
 		}
 	}
+	g.updateEffects()
 
+	// 回滚重新模拟时同一帧会多次调用 Update，音乐只在正常的帧中更新
+	if !g.resimulating {
+		music.Update(g.musicTrack(), g.mode == config.ModePause || g.mode == config.ModeLevelUp, g.musicMood())
+	}
 	return nil
 }
 
+// resolveModePause Esc 键或者空格键继续游戏，o 键进入设置界面，Backspace 键放弃本局回到标题界面
+func (g *Game) resolveModePause() {
+	switch {
+	case inpututil.IsKeyJustPressed(ebiten.KeyEscape) || inpututil.IsKeyJustPressed(ebiten.KeySpace):
+		g.mode = config.ModeGame
+	case inpututil.IsKeyJustPressed(ebiten.KeyO):
+		g.openSettings()
+	case inpututil.IsKeyJustPressed(ebiten.KeyBackspace):
+		g.init()
+	}
+}
+
+// musicTrack 当前界面播放的音乐，游戏结束时音乐淡出
+func (g *Game) musicTrack() string {
+	switch g.mode {
+	case config.ModeGameOver:
+		return ""
+	case config.ModeGame, config.ModeLevelUp, config.ModeRoundOver, config.ModePause:
+		return "game"
+	case config.ModeSettings:
+		if g.settingsReturn == config.ModePause {
+			return "game"
+		}
+	}
+	return "title"
+}
+
+// musicMood 计算音乐紧张程度所需的游戏状态，不在游戏中时为零
+func (g *Game) musicMood() MusicMood {
+	var mood MusicMood
+	if g.musicTrack() != "game" {
+		return mood
+	}
+	lx, ly := g.listener()
+	for _, monster := range g.monsters {
+		if utils.GetDistance(lx, ly, monster.x+config.FrameWidth/2, monster.y+config.FrameHeight/2) < intensityCrowdRadius {
+			mood.Nearby++
+		}
+	}
+	for _, p := range g.players {
+		mood.DamageTaken += p.damageTaken
+	}
+	mood.Elapsed = g.clock.Since(g.startTime).Seconds()
+	return mood
+}
+
+// DrawPause 在暂停的游戏画面上绘制暂停提示
+func DrawPause(screen *ebiten.Image) {
+	vector.DrawFilledRect(screen, 0, 0, config.ScreenWidth, config.ScreenHeight, color.RGBA{0x00, 0x00, 0x00, 0x80}, false)
+
+	op := &text.DrawOptions{}
+	op.GeoM.Translate(config.ScreenWidth/2, 5*config.TitleFontSize)
+	op.ColorScale.ScaleWithColor(color.White)
+	op.PrimaryAlign = text.AlignCenter
+	text.Draw(screen, T("pause.title"), arcadeFace(config.TitleFontSize), op)
+
+	op = &text.DrawOptions{}
+	op.GeoM.Translate(config.ScreenWidth/2, 7*config.TitleFontSize)
+	op.ColorScale.ScaleWithColor(color.White)
+	op.LineSpacing = 2 * config.FontSize
+	op.PrimaryAlign = text.AlignCenter
+	text.Draw(screen, T("pause.hint"), arcadeFace(config.FontSize), op)
+}
+
 func (g *Game) resolveModeGame() error {
-	g.player.count++
+	// 游戏时钟只在游戏进行时前进
+	g.clock.Tick()
+
+	for _, player := range g.players {
+		player.count++
 
-	g.resolveKeyPressed()
+		// 更新生效中的技能，持续时间结束后关闭技能
+		player.UpdateSkills(g)
 
-	// 如果 GIF 正在播放且播放完成，则停止播放
-	if g.player.isSkill && time.Since(g.player.skillTime) > time.Second*3 {
-		g.player.isSkill = false
+		// 倒地的玩家不响应操作
+		if player.downed {
+			continue
+		}
+
+		g.resolveInput(player, player.input.Read())
+
+		// 推进冲刺并恢复冲刺次数
+		player.UpdateDash()
 	}
 
+	// 队友接触倒地的玩家将其救起
+	g.resolveRevive()
+
+	if g.stats != nil {
+		g.stats.recordMonsters(g)
+	}
+
+	// 推进玩家与怪物身上的状态效果
+	g.updateStatuses()
+
 	// 更新所有远程武器的发射产物位置
 	SuspendMove(g)
 
-	// 生成怪物
-	GenerateMonster(g)
+	// 危险区域在地图上随机位置刷新，并对区域内的人物施加状态效果
+	GenerateHazard(g)
+	HazardMove(g)
+
+	// 生成怪物，竞技场中怪物是可选的
+	if g.arena == nil || g.arena.Monsters {
+		GenerateMonster(g)
+	}
 
 	// 武器在地图上随机位置刷新
 	GenerateWeapon(g)
 
+	// 道具在地图上随机位置刷新，玩家触碰道具时获得道具效果
+	GeneratePickup(g)
+	PickupMove(g)
+
+	// 经验足够时升级，暂停游戏选择强化，竞技场中不会升级
+	if g.arena == nil {
+		g.resolveLevelUp()
+	}
+
 	g.resolvePickWeapon()
 
 	g.resolvePlayerWeapon()
@@ -125,51 +386,75 @@ func (g *Game) resolveModeGame() error {
 		return err
 	}
 
+	// 按人物的状态切换动画
+	for _, player := range g.players {
+		player.animate()
+	}
+	for _, monster := range g.monsters {
+		monster.animate()
+	}
+
+	// 竞技场中只剩一个阵营或者时间结束时回合结束
+	if g.arena != nil && g.mode == config.ModeGame {
+		g.resolveRound()
+	}
+
+	// 定时自动存档，游戏崩溃或者关闭后可以继续
+	if g.mode == config.ModeGame {
+		g.resolveAutosave()
+	}
+
 	return nil
 }
 
-func (g *Game) resolveKeyPressed() {
-	// 检查键盘输入，人物移动
-	if ebiten.IsKeyPressed(ebiten.KeyArrowLeft) {
-		g.player.Move(-g.player.speed, 0)
-		g.player.directIdx = 2
+// resolveInput 按玩家这一帧的操作移动人物、冲刺、释放技能以及开火
+func (g *Game) resolveInput(p *Player, in Input) {
+	// 眩晕时不响应任何操作
+	if p.Stunned() {
+		return
+	}
+
+	// 人物朝向移动的方向，冲刺过程中不响应移动
+	if in.X < 0 {
+		p.directIdx = 2
+	}
+
+	if in.X > 0 {
+		p.directIdx = 0
 	}
 
-	if ebiten.IsKeyPressed(ebiten.KeyArrowRight) {
-		g.player.Move(g.player.speed, 0)
-		g.player.directIdx = 0
+	if in.Y < 0 {
+		p.directIdx = 3
 	}
 
-	if ebiten.IsKeyPressed(ebiten.KeyArrowUp) {
-		g.player.Move(0, -g.player.speed)
-		g.player.directIdx = 3
+	if in.Y > 0 {
+		p.directIdx = 1
 	}
 
-	if ebiten.IsKeyPressed(ebiten.KeyArrowDown) {
-		g.player.Move(0, g.player.speed)
-		g.player.directIdx = 1
+	if !p.Dashing() {
+		p.Move(in.X*p.Speed(), 0)
+		p.Move(0, in.Y*p.Speed())
 	}
 
-	// 按下 q 键可以释放技能 && 距离上一次释放技能时间大于技能冷却时间
-	if inpututil.IsKeyJustPressed(ebiten.KeyQ) && time.Since(g.player.skillTime) > time.Second*5 {
-		if g.player.score >= 20 {
-			g.player.isSkill = true
-			g.player.skillTime = time.Now()
-			g.player.score -= 20
-			g.player.skillFrame = 0
+	// 沿移动方向冲刺，没有移动时沿人物朝向冲刺
+	if in.Dash && p.Dash(in.X, in.Y) {
+		if err := g.playSound(dashSound, p.x+config.FrameWidth/2, p.y+config.FrameHeight/2); err != nil {
+			log.Println("play dash sound:", err)
 		}
 	}
 
-	// 如果人物有武器，且是远程武器，按空格键开火
-	if g.player.weapon != nil {
-		// 检测空格键按下事件
-		if inpututil.IsKeyJustPressed(ebiten.KeySpace) {
-			// 如果上次未开火过，执行开火操作
-			switch g.player.weapon.(type) {
-			case *RangedWeapon:
-				weapon := g.player.weapon.(*RangedWeapon)
-				weapon.Fire(g, g.player)
-			}
+	// 释放对应技能槽的技能，积分不足或者正在冷却时无效
+	for i, slot := range p.skills {
+		if in.Skills[i] {
+			slot.Use(g, p)
+		}
+	}
+
+	// 如果人物有武器，且是远程武器，开火
+	if in.Fire {
+		switch weapon := p.weapon.(type) {
+		case *RangedWeapon:
+			weapon.Fire(g, p)
 		}
 	}
 }
@@ -179,16 +464,26 @@ func (g *Game) resolveImpact() {
 }
 
 func (g *Game) resolvePickWeapon() {
-	for id, weapon := range g.weapons {
+	for _, id := range sortedKeys(g.weapons) {
+		weapon := g.weapons[id]
 		// 玩家移动到武器位置可以获得武器
-		if IsTouch(g.player.x, g.player.y, g.weaponPosition[id][0], g.weaponPosition[id][1]) {
-			g.player.weapon = weapon
-			delete(g.weapons, id)
-			delete(g.weaponPosition, id)
+		picked := false
+		for _, player := range g.livingPlayers() {
+			if IsTouch(player.x, player.y, g.weaponPosition[id][0], g.weaponPosition[id][1]) {
+				player.weapon = weapon
+				player.ApplyModifiers()
+				delete(g.weapons, id)
+				delete(g.weaponPosition, id)
+				picked = true
+				break
+			}
+		}
+		if picked {
 			break
 		}
 		// 怪物移动到武器位置可以获得武器
-		for _, monster := range g.monsters {
+		for _, monsterID := range sortedKeys(g.monsters) {
+			monster := g.monsters[monsterID]
 			if IsTouch(monster.x, monster.y, g.weaponPosition[id][0], g.weaponPosition[id][1]) {
 				monster.weapon = weapon
 				delete(g.weapons, id)
@@ -200,41 +495,50 @@ func (g *Game) resolvePickWeapon() {
 }
 
 func (g *Game) resolvePlayerWeapon() error {
-	if g.player.weapon != nil {
-		switch g.player.weapon.(type) {
+	for _, player := range g.livingPlayers() {
+		switch player.weapon.(type) {
 		case *MeleeWeapon:
 			// 角色武器旋转
-			weapon := g.player.weapon.(*MeleeWeapon)
+			weapon := player.weapon.(*MeleeWeapon)
 			weapon.Spin()
-			// 武器碰撞到敌人可以消灭敌人
-			weaponCenterOffsetX := g.player.weaponX // 武器中心相对于角色中心的 X 坐标偏移
-			weaponCenterOffsetY := g.player.weaponY // 武器中心相对于角色中心的 Y 坐标偏移
+			// 武器碰撞到敌人可以消灭敌人，怪物直接死亡，武器的状态效果只作用于竞技场中敌对的玩家
+			weaponCenterOffsetX := player.weaponX // 武器中心相对于角色中心的 X 坐标偏移
+			weaponCenterOffsetY := player.weaponY // 武器中心相对于角色中心的 Y 坐标偏移
 			// 考虑武器的旋转角度，将偏移向量旋转到合适的位置
-			weaponCenterX := g.player.x + weaponCenterOffsetX + weaponCenterOffsetX*math.Cos(weapon.angle) - weaponCenterOffsetY*math.Sin(weapon.angle)
-			weaponCenterY := g.player.y + weaponCenterOffsetY + weaponCenterOffsetX*math.Sin(weapon.angle) + weaponCenterOffsetY*math.Cos(weapon.angle)
+			weaponCenterX := player.x + weaponCenterOffsetX + weaponCenterOffsetX*math.Cos(weapon.angle) - weaponCenterOffsetY*math.Sin(weapon.angle)
+			weaponCenterY := player.y + weaponCenterOffsetY + weaponCenterOffsetX*math.Sin(weapon.angle) + weaponCenterOffsetY*math.Cos(weapon.angle)
 			// 武器的轨迹
 			weapon.Trail = append(weapon.Trail, f64.Vec2{weaponCenterX, weaponCenterY})
 			if len(weapon.Trail) >= 20 {
 				weapon.Trail = weapon.Trail[1:]
 			}
-			for id, monster := range g.monsters {
+			for _, id := range sortedKeys(g.monsters) {
+				monster := g.monsters[id]
 				// 怪物的中心位置
 				monsterCenterX := monster.x + config.FrameWidth/2
 				monsterCenterY := monster.y + config.FrameHeight/2
 				if IsTouch(weaponCenterX, weaponCenterY, monsterCenterX, monsterCenterY) {
-					if err := g.hitPlayer.Rewind(); err != nil {
+					if err := g.playHit(weaponCenterX, weaponCenterY); err != nil {
 						return err
 					}
-					g.hitPlayer.Play()
-					g.player.score++
-					delete(g.monsters, id)
-					delete(g.monsterTarget, id)
-					delete(g.monsterTimer, id)
-
-					if monster.hasSteadyWeaponPosition {
-						delete(g.weaponPositionBeenPicked, monster.steadyWeaponId)
-						log.Println("delete picked weapon", monster.steadyWeaponId)
+					g.killMonster(id, player)
+				}
+			}
+			// 竞技场中近战武器可以伤害敌对阵营的玩家
+			if g.arena == nil {
+				continue
+			}
+			for _, other := range g.livingPlayers() {
+				if !Hostile(player.team, other.team) || other.Invincible() || g.clock.Since(other.lastCollisionTime) < time.Second {
+					continue
+				}
+				if IsTouch(weaponCenterX, weaponCenterY, other.x+config.FrameWidth/2, other.y+config.FrameHeight/2) {
+					if err := g.playHit(weaponCenterX, weaponCenterY); err != nil {
+						return err
 					}
+					other.lastCollisionTime = g.clock.Now()
+					other.ApplyStatus(weapon.effect)
+					g.damage(other, 25, weapon.Type)
 				}
 			}
 		case *RangedWeapon:
@@ -248,11 +552,18 @@ func (g *Game) resolvePlayerWeapon() error {
 func (g *Game) resolveMonsters() error {
 	var target f64.Vec2
 
+	// 每个怪物追逐的目标，存在诱饵时为诱饵的位置
+	chaseTargets := make(map[int]f64.Vec2)
+
 	// 正在追逐玩家的怪物
 	chasingMonsters := make(map[int]*Player)
 
 	// 怪物移动
-	for id, monster := range g.monsters {
+	for _, id := range sortedKeys(g.monsters) {
+		monster := g.monsters[id]
+		chaseTarget := g.chaseTarget(monster)
+		chaseTargets[id] = chaseTarget
+
 		if monster.hasSteadyWeaponPosition {
 			_, ok := g.weaponPosition[monster.steadyWeaponId]
 			if !ok {
@@ -276,7 +587,7 @@ func (g *Game) resolveMonsters() error {
 			target = monster.steadyWeaponPosition
 		} else if len(g.weaponPosition) == 0 {
 			// 当地图上没有武器时
-			monster.directIdx = utils.GetDirectionIdxByTargetPosition(g.player.x, g.player.y, monster.x, monster.y)
+			monster.directIdx = utils.GetDirectionIdxByTargetPosition(chaseTarget[0], chaseTarget[1], monster.x, monster.y)
 
 			target = g.monsterTarget[id]
 
@@ -294,7 +605,8 @@ func (g *Game) resolveMonsters() error {
 			nearWeaponId := 0
 			nearDistance := math.Inf(1)
 			var nearPosition f64.Vec2
-			for id, weapon := range g.weaponPosition {
+			for _, id := range sortedKeys(g.weaponPosition) {
+				weapon := g.weaponPosition[id]
 				// 如果一把武器已经被某个怪物标记过了，则不再前往
 				if ok := g.weaponPositionBeenPicked[id]; ok {
 					continue
@@ -324,7 +636,7 @@ func (g *Game) resolveMonsters() error {
 
 				target = monster.steadyWeaponPosition
 			} else {
-				monster.directIdx = utils.GetDirectionIdxByTargetPosition(g.player.x, g.player.y, monster.x, monster.y)
+				monster.directIdx = utils.GetDirectionIdxByTargetPosition(chaseTarget[0], chaseTarget[1], monster.x, monster.y)
 
 				target = g.monsterTarget[id]
 				switch monster.weapon.(type) {
@@ -345,8 +657,9 @@ func (g *Game) resolveMonsters() error {
 
 		// 每隔一定时间更新一次目标位置
 		if timer >= 60 {
-			// 以玩家为目标
-			g.monsterTarget[id] = f64.Vec2{g.player.x, g.player.y}
+			// 以最近或者生命值最低的玩家为目标
+			monster.target = g.selectTarget(monster)
+			g.monsterTarget[id] = g.chaseTarget(monster)
 			g.monsterTimer[id] = 0
 		}
 
@@ -359,7 +672,7 @@ func (g *Game) resolveMonsters() error {
 
 		if monster.weapon == nil {
 			// 在移动轨迹上进行插值
-			monster.Move(directionX*monster.speed, directionY*monster.speed)
+			monster.Move(directionX*monster.Speed()*g.timeScale, directionY*monster.Speed()*g.timeScale)
 		}
 	}
 
@@ -370,7 +683,8 @@ func (g *Game) resolveMonsters() error {
 	// 计算所有追逐主角的怪物的中心
 	centerX := 0.0
 	centerY := 0.0
-	for _, monster := range chasingMonsters {
+	for _, id := range sortedKeys(chasingMonsters) {
+		monster := chasingMonsters[id]
 		centerX += monster.x
 		centerY += monster.y
 	}
@@ -378,14 +692,16 @@ func (g *Game) resolveMonsters() error {
 	centerX /= float64(len(chasingMonsters))
 	centerY /= float64(len(chasingMonsters))
 
-	for id, monster := range chasingMonsters {
+	for _, id := range sortedKeys(chasingMonsters) {
+		monster := chasingMonsters[id]
 		target := g.monsterTarget[id]
+		chaseTarget := chaseTargets[id]
 
 		// 计算中心点在怪物和玩家之间的投影
-		projectionX, projectionY := utils.GetProjection(monster.x, monster.y, g.player.x, g.player.y, centerX, centerY)
+		projectionX, projectionY := utils.GetProjection(monster.x, monster.y, chaseTarget[0], chaseTarget[1], centerX, centerY)
 		distance2Monster := utils.GetDistance(projectionX, projectionY, monster.x, monster.y)
-		distance2Player := utils.GetDistance(projectionX, projectionY, g.player.x, g.player.y)
-		distance := utils.GetDistance(monster.x, monster.y, g.player.x, g.player.y)
+		distance2Player := utils.GetDistance(projectionX, projectionY, chaseTarget[0], chaseTarget[1])
+		distance := utils.GetDistance(monster.x, monster.y, chaseTarget[0], chaseTarget[1])
 
 		// 投影点在怪物之后
 		var correctX, correctY float64
@@ -397,11 +713,8 @@ func (g *Game) resolveMonsters() error {
 
 		// 计算当前位置到目标位置的方向向量
 		directionX, directionY := utils.Normalize(target[0]-monster.x, target[1]-monster.y)
-		log.Println("怪物移动量", id, directionX, directionY)
-
 		directionX += correctX
 		directionY += correctY
-		log.Println("怪物修正后移动量", id, directionX, directionY)
 
 		// Flocking
 		for otherId, otherMonster := range chasingMonsters {
@@ -420,22 +733,24 @@ func (g *Game) resolveMonsters() error {
 
 		if monster.weapon == nil {
 			// 在移动轨迹上进行插值
-			monster.Move(directionX*monster.speed, directionY*monster.speed)
+			monster.Move(directionX*monster.Speed()*g.timeScale, directionY*monster.Speed()*g.timeScale)
 		}
 	}
 
-	for id, monster := range g.monsters {
+	for _, id := range sortedKeys(g.monsters) {
+		monster := g.monsters[id]
 		target := g.monsterTarget[id]
 
 		// 计算当前位置到目标位置的方向向量
 		directionX, directionY := utils.Normalize(target[0]-monster.x, target[1]-monster.y)
 
-		if monster.weapon != nil {
+		// 眩晕的怪物无法移动和攻击
+		if monster.weapon != nil && !monster.Stunned() {
 			switch monster.weapon.(type) {
 			// 怪物武器旋转
 			case *MeleeWeapon, nil:
 				// 只有拿着非远程武器的怪物才会移动
-				monster.Move(directionX*monster.speed, directionY*monster.speed)
+				monster.Move(directionX*monster.Speed()*g.timeScale, directionY*monster.Speed()*g.timeScale)
 
 				weapon := monster.weapon.(*MeleeWeapon)
 				weapon.Spin()
@@ -454,48 +769,45 @@ func (g *Game) resolveMonsters() error {
 					weapon.Trail = weapon.Trail[1:]
 				}
 
-				// 角色的中心位置
-				playerCenterX := g.player.x + config.FrameWidth/2
-				playerCenterY := g.player.y + config.FrameHeight/2
-				// 并非无敌状态，且碰撞到角色，降低角色生命值
-				if !g.player.Invincible() && IsTouch(weaponCenterX, weaponCenterY, playerCenterX, playerCenterY) {
-					if time.Since(g.player.lastCollisionTime) < time.Second {
-						continue
-					}
-					if err := g.hitPlayer.Rewind(); err != nil {
-						return err
-					}
-					g.hitPlayer.Play()
-					g.player.health -= 25
-					g.player.lastCollisionTime = time.Now()
-					if g.player.health <= 0 {
-						g.mode = config.ModeGameOver
+				for _, player := range g.livingPlayers() {
+					// 角色的中心位置
+					playerCenterX := player.x + config.FrameWidth/2
+					playerCenterY := player.y + config.FrameHeight/2
+					// 并非无敌状态，且碰撞到角色，降低角色生命值
+					if !player.Invincible() && IsTouch(weaponCenterX, weaponCenterY, playerCenterX, playerCenterY) {
+						if g.clock.Since(player.lastCollisionTime) < time.Second {
+							continue
+						}
+						if err := g.playHit(weaponCenterX, weaponCenterY); err != nil {
+							return err
+						}
+						player.lastCollisionTime = g.clock.Now()
+						player.ApplyStatus(weapon.effect)
+						g.damage(player, 25, weapon.Type)
 					}
 				}
 			case *RangedWeapon:
 				weapon := monster.weapon.(*RangedWeapon)
 
-				// 每秒钟发射一颗子弹
-				if time.Since(weapon.LastFireTime) > time.Second {
-					weapon.LastFireTime = time.Now()
+				// 每秒钟发射一颗子弹，时间减缓时射速同样变慢
+				if g.clock.Since(weapon.LastFireTime) > time.Duration(float64(time.Second)/g.timeScale) {
+					weapon.LastFireTime = g.clock.Now()
 					weapon.Fire(g, monster, WithBulletDirection(directionX, directionY))
 				}
 			}
 		}
 
 		// 并非无敌状态，怪物碰撞到人物，降低生命值
-		if !g.player.Invincible() && IsTouch(g.player.x, g.player.y, monster.x, monster.y) {
-			if time.Since(g.player.lastCollisionTime) < time.Second {
-				continue
-			}
-			if err := g.hitPlayer.Rewind(); err != nil {
-				return err
-			}
-			g.hitPlayer.Play()
-			g.player.health -= 25
-			g.player.lastCollisionTime = time.Now()
-			if g.player.health <= 0 {
-				g.mode = config.ModeGameOver
+		for _, player := range g.livingPlayers() {
+			if !player.Invincible() && IsTouch(player.x, player.y, monster.x, monster.y) {
+				if g.clock.Since(player.lastCollisionTime) < time.Second {
+					continue
+				}
+				if err := g.playHit(monster.x+config.FrameWidth/2, monster.y+config.FrameHeight/2); err != nil {
+					return err
+				}
+				player.lastCollisionTime = g.clock.Now()
+				g.damage(player, 25, DamageContact)
 			}
 		}
 	}
@@ -503,17 +815,102 @@ func (g *Game) resolveMonsters() error {
 	return nil
 }
 
-// Draw 每次绘制都会调用这个函数，重新设置画面元素的内容
-func (g *Game) Draw(screen *ebiten.Image) {
+// playHit 在击中的位置 (x, y) 播放击中音效，无界面运行以及回滚重新模拟时没有音效
+func (g *Game) playHit(x, y float64) error {
+	return g.playSound(hitSound, x, y)
+}
+
+// damage 人物受到伤害，玩家生命值归零时倒地，怪物生命值归零时被消灭，source 为伤害的来源
+func (g *Game) damage(p *Player, damage float64, source string) {
+	if p.downed {
+		return
+	}
+	// 护盾优先抵挡伤害
+	if shield := p.Shield(); shield > 0 {
+		absorbed := math.Min(shield, damage)
+		p.shield -= absorbed
+		damage -= absorbed
+		g.emit(&shieldSparks, p.x+config.FrameWidth/2, p.y+config.FrameHeight/2, 0)
+	}
+	if damage > 0 && p.team != TeamNeutral {
+		g.emit(&bloodSplat, p.x+config.FrameWidth/2, p.y+config.FrameHeight/2, -math.Pi/2)
+	}
+	p.health -= damage
+	p.damageTaken += damage
+	if damage > 0 {
+		p.anim.Hurt()
+		g.showDamage(p, damage)
+		g.hurtEffect(p, damage)
+	}
+	if g.stats != nil && p.team != TeamNeutral {
+		g.stats.Damage[source] += damage
+	}
+	if p.health > 0 {
+		return
+	}
+	if p.team != TeamNeutral {
+		g.downPlayer(p)
+		return
+	}
+	g.killMonster(p.id, nil)
+}
+
+// gameOver 游戏结束，根据所有玩家的积分之和以及存活时间获得金币并保存档案
+func (g *Game) gameOver() {
+	g.mode = config.ModeGameOver
+	survival := int(g.clock.Since(g.startTime).Seconds())
+	score := g.totalScore()
+	g.coinsEarned = CoinsEarned(score, survival)
+	g.profile.Coins += g.coinsEarned
+	g.profile.BestTime = max(g.profile.BestTime, survival)
+	g.profile.BestScore = max(g.profile.BestScore, score)
+	if err := g.profile.Save(); err != nil {
+		log.Println("save profile:", err)
+	}
+	g.deleteRun()
+}
+
+// killMonster 消灭怪物，击杀的玩家获得积分，killer 为 nil 时积分归距离最近的玩家
+func (g *Game) killMonster(id int, killer *Player) {
+	monster, ok := g.monsters[id]
+	if !ok {
+		return
+	}
+	if g.stats != nil {
+		g.stats.recordKill(killer)
+	}
+	g.emit(&deathBurst, monster.x+config.FrameWidth/2, monster.y+config.FrameHeight/2, 0)
+	if killer == nil {
+		killer = g.nearestPlayer(monster.x, monster.y)
+	} else {
+		g.hitStopEffect() // 只有玩家亲手击杀时停顿，被危险区域等杀死时不停顿
+	}
+	if killer != nil {
+		killer.AddScore(1)
+		killer.kills++
+		g.countKill(killer)
+	}
+	DropPickup(g, monster)
+	delete(g.monsters, id)
+	delete(g.monsterTarget, id)
+	delete(g.monsterTimer, id)
+
+	if monster.hasSteadyWeaponPosition {
+		delete(g.weaponPositionBeenPicked, monster.steadyWeaponId)
+	}
+}
+
+// drawScene 绘制当前界面的画面，重新设置画面元素的内容
+func (g *Game) drawScene(screen *ebiten.Image) {
 	var titleTexts string
 	var texts string
 	switch g.mode {
 	case config.ModeTitle:
-		titleTexts = "Avoid the Enemies"
-		texts = "PRESS SPACE KEY TO START"
+		titleTexts = T("title.name")
+		texts = T("title.start")
 	case config.ModeGameOver:
-		titleTexts = "Game Over"
-		texts = "PRESS SPACE KEY TO RESTART"
+		titleTexts = T("gameover.title")
+		texts = T("gameover.restart")
 	}
 
 	// 绘制标题
@@ -522,97 +919,132 @@ func (g *Game) Draw(screen *ebiten.Image) {
 	op.ColorScale.ScaleWithColor(color.White)
 	op.LineSpacing = config.TitleFontSize
 	op.PrimaryAlign = text.AlignCenter
-	text.Draw(screen, titleTexts, &text.GoTextFace{
-		Source: arcadeFaceSource,
-		Size:   config.TitleFontSize,
-	}, op)
+	text.Draw(screen, titleTexts, arcadeFace(config.TitleFontSize), op)
 
 	op = &text.DrawOptions{}
 	op.GeoM.Translate(config.ScreenWidth/2, 7*config.TitleFontSize)
 	op.ColorScale.ScaleWithColor(color.White)
 	op.LineSpacing = config.FontSize
 	op.PrimaryAlign = text.AlignCenter
-	text.Draw(screen, texts, &text.GoTextFace{
-		Source: arcadeFaceSource,
-		Size:   config.FontSize,
-	}, op)
+	text.Draw(screen, texts, arcadeFace(config.FontSize), op)
+
+	if g.mode == config.ModeTitle {
+		// 有存档时绘制继续游戏的提示
+		if g.canContinue {
+			op = &text.DrawOptions{}
+			op.GeoM.Translate(config.ScreenWidth/2, 8*config.TitleFontSize)
+			op.ColorScale.ScaleWithColor(color.RGBA{0xFF, 0xE0, 0x30, 0xFF})
+			op.LineSpacing = config.FontSize
+			op.PrimaryAlign = text.AlignCenter
+			text.Draw(screen, T("title.continue"), arcadeFace(config.FontSize), op)
+		}
 
-	if g.mode == config.ModeGame {
-		// 绘制分数
+		// 绘制装备的技能，按技能键切换
+		for i, slot := range g.players[0].skills {
+			op = &text.DrawOptions{}
+			op.GeoM.Translate(config.ScreenWidth/2, float64(9*config.TitleFontSize+i*2*config.FontSize))
+			op.ColorScale.ScaleWithColor(color.White)
+			op.LineSpacing = config.FontSize
+			op.PrimaryAlign = text.AlignCenter
+			text.Draw(screen, soloKeyboard.SkillLabel(i)+": "+T("skill."+slot.skill.Name()), arcadeFace(config.FontSize), op)
+		}
+
+		// 绘制金币以及商店、设置界面的入口
 		op = &text.DrawOptions{}
-		op.GeoM.Translate(3, 3)
+		op.GeoM.Translate(config.ScreenWidth/2, float64(9*config.TitleFontSize+(len(g.players[0].skills)+1)*2*config.FontSize))
+		op.ColorScale.ScaleWithColor(color.RGBA{0xFF, 0xE0, 0x30, 0xFF})
+		op.LineSpacing = config.FontSize
+		op.PrimaryAlign = text.AlignCenter
+		text.Draw(screen, T("title.coins", g.profile.Coins), arcadeFace(config.FontSize), op)
+
+		// 绘制玩家数量以及电脑玩家的难度，按 p、b 键切换
+		op = &text.DrawOptions{}
+		op.GeoM.Translate(config.ScreenWidth/2, float64(9*config.TitleFontSize+(len(g.players[0].skills)+2)*2*config.FontSize))
 		op.ColorScale.ScaleWithColor(color.White)
 		op.LineSpacing = config.FontSize
-		text.Draw(screen, "Score: "+strconv.Itoa(g.player.score), &text.GoTextFace{
-			Source: arcadeFaceSource,
-			Size:   config.FontSize,
-		}, op)
+		op.PrimaryAlign = text.AlignCenter
+		bot := T("common.off")
+		if g.bot != nil {
+			bot = T("bot." + g.bot.Name)
+		}
+		text.Draw(screen, T("title.players", len(g.players), bot), arcadeFace(config.FontSize), op)
 
-		// 绘制游戏时间
+		// 绘制游戏模式以及竞技场的设置，按 v 键切换
+		mode := T("title.mode_survival")
+		if g.arena != nil {
+			mode = T("title.mode_arena")
+		}
 		op = &text.DrawOptions{}
-		op.GeoM.Translate(config.ScreenWidth/2, 3)
+		op.GeoM.Translate(config.ScreenWidth/2, float64(9*config.TitleFontSize+(len(g.players[0].skills)+3)*2*config.FontSize))
 		op.ColorScale.ScaleWithColor(color.White)
 		op.LineSpacing = config.FontSize
-		text.Draw(screen, "SurvivalTime: "+strconv.Itoa(int(time.Since(g.player.startTime).Seconds()))+"s", &text.GoTextFace{
-			Source: arcadeFaceSource,
-			Size:   config.FontSize,
-		}, op)
-
-		// 绘制技能效果
-		if g.player.isSkill {
-			g.player.skillFrame++
-			op := &ebiten.DrawImageOptions{}
-			// 位于血条上方，血条高度为 5
-			op.GeoM.Translate(g.player.x-16, g.player.y-5-16)
-			//op.GeoM.Translate(g.player.x-8, g.player.y-5-36)
-			i := (g.player.skillFrame / 5) % 4
-			//i := (g.player.skillFrame / 5) % 90
-			sx, sy := i*64, 0
-			//sx, sy := i*48, 0
-			screen.DrawImage(fireImage.SubImage(image.Rect(sx, sy, sx+64, sy+64)).(*ebiten.Image), op)
-			//screen.DrawImage(skillImage.SubImage(image.Rect(sx, sy, sx+48, sy+36)).(*ebiten.Image), op)
-		}
-
-		// 绘制角色
-		op := &ebiten.DrawImageOptions{}
-		op.GeoM.Translate(g.player.x, g.player.y)
-		i := (g.player.count / 5) % config.FrameCount
-		sx, sy := config.FrameOX+i*config.FrameWidth, config.FrameOY
-		screen.DrawImage(runnerImage.SubImage(image.Rect(sx, sy, sx+config.FrameWidth, sy+config.FrameHeight)).(*ebiten.Image), op)
-
-		// 绘制角色武器
-		if g.player.weapon != nil {
-			switch g.player.weapon.(type) {
-			case *MeleeWeapon:
-				weapon := g.player.weapon.(*MeleeWeapon)
-				op = &ebiten.DrawImageOptions{}
-				op.GeoM.Rotate(weapon.angle)
-				op.GeoM.Translate(g.player.x+g.player.weaponX, g.player.y+g.player.weaponY)
-				screen.DrawImage(weapon.Image.SubImage(image.Rect(0, 0, config.FrameWidth, config.FrameHeight)).(*ebiten.Image), op)
-				weapon.DrawTrail(screen)
-			case *RangedWeapon:
-				weapon := g.player.weapon.(*RangedWeapon)
-				op = &ebiten.DrawImageOptions{}
-				op.GeoM.Rotate(directions[g.player.directIdx].spin)
-				op.GeoM.Translate(rotateAdjust[g.player.directIdx].dx*config.FrameWidth, rotateAdjust[g.player.directIdx].dy*config.FrameHeight)
-				op.GeoM.Translate(g.player.x, g.player.y)
-				screen.DrawImage(weapon.Image.SubImage(image.Rect(0, 0, config.FrameWidth, config.FrameHeight)).(*ebiten.Image), op)
-			}
+		op.PrimaryAlign = text.AlignCenter
+		text.Draw(screen, mode, arcadeFace(config.FontSize), op)
+		if g.arena != nil {
+			op = &text.DrawOptions{}
+			op.GeoM.Translate(config.ScreenWidth/2, float64(9*config.TitleFontSize+(len(g.players[0].skills)+4)*2*config.FontSize))
+			op.ColorScale.ScaleWithColor(color.White)
+			op.LineSpacing = config.FontSize
+			op.PrimaryAlign = text.AlignCenter
+			text.Draw(screen, g.arenaOptions(), arcadeFace(config.FontSize), op)
+		}
+	}
+
+	if g.mode == config.ModeGameOver {
+		// 绘制本局获得的金币，竞技场中绘制赢得比赛的阵营
+		result, resultColor := T("gameover.coins", g.coinsEarned), color.Color(color.RGBA{0xFF, 0xE0, 0x30, 0xFF})
+		if g.arena != nil {
+			result, resultColor = T("gameover.match_winner", g.teamLabel(g.roundWinner)), g.teamColor(g.roundWinner)
+		}
+		op = &text.DrawOptions{}
+		op.GeoM.Translate(config.ScreenWidth/2, 9*config.TitleFontSize)
+		op.ColorScale.ScaleWithColor(resultColor)
+		op.LineSpacing = config.FontSize
+		op.PrimaryAlign = text.AlignCenter
+		text.Draw(screen, result, arcadeFace(config.FontSize), op)
+	}
+
+	if g.mode == config.ModeShop {
+		DrawShop(screen, g)
+	}
+
+	if g.mode == config.ModeCharacterSelect {
+		DrawCharacterSelect(screen, g)
+	}
+
+	if g.mode == config.ModeSettings {
+		DrawSettings(screen, g)
+	}
+
+	// 升级选择、回合结算以及暂停期间继续绘制暂停的游戏画面
+	if g.mode == config.ModeGame || g.mode == config.ModeLevelUp || g.mode == config.ModeRoundOver || g.mode == config.ModePause {
+		// 绘制地图上的危险区域
+		DrawHazards(screen, g)
+
+		// 绘制地图上的道具
+		DrawPickups(screen, g)
+
+		for i, player := range g.players {
+			g.drawPlayer(screen, player, i)
 		}
 
+		// 子弹、怪物以及怪物的武器数量很多，收集到一个批次中一起绘制
+		g.batch.Begin(screen)
+
 		// 绘制武器发射产物
 		for _, suspend := range g.suspends {
 			op := &ebiten.DrawImageOptions{}
-			op.GeoM.Rotate(directions[g.player.directIdx].spin)
+			op.GeoM.Rotate(directions[suspend.directIndex].spin)
 			op.GeoM.Translate(suspend.pos[0], suspend.pos[1])
-			screen.DrawImage(suspend.rangeWeapon.bullet.SubImage(image.Rect(0, 0, config.FrameWidth, config.FrameHeight)).(*ebiten.Image), op)
+			g.batch.DrawImage(suspend.rangeWeapon.bullet.Image(), op)
 		}
 
 		// 绘制怪物
 		for _, monster := range g.monsters {
-			op = &ebiten.DrawImageOptions{}
+			op := &ebiten.DrawImageOptions{}
 			op.GeoM.Translate(monster.x, monster.y)
-			screen.DrawImage(runnerImage.SubImage(image.Rect(sx, sy, sx+config.FrameWidth, sy+config.FrameHeight)).(*ebiten.Image), op)
+			monster.StatusTint(op)
+			monsterSprite.Draw(&g.batch, monster.anim.clip, monster.directIdx, monster.anim.faceLeft, monster.anim.tick, op)
 			// 绘制怪物武器
 			if monster.weapon != nil {
 				switch monster.weapon.(type) {
@@ -621,36 +1053,112 @@ func (g *Game) Draw(screen *ebiten.Image) {
 					op = &ebiten.DrawImageOptions{}
 					op.GeoM.Rotate(weapon.angle)
 					op.GeoM.Translate(monster.x+monster.weaponX, monster.y+monster.weaponY)
-					screen.DrawImage(weapon.Image.SubImage(image.Rect(0, 0, config.FrameWidth, config.FrameHeight)).(*ebiten.Image), op)
-					weapon.DrawTrail(screen)
+					g.batch.DrawImage(weapon.Image.Sub(frameRect), op)
 				case *RangedWeapon:
 					weapon := monster.weapon.(*RangedWeapon)
 					op = &ebiten.DrawImageOptions{}
 					op.GeoM.Rotate(directions[monster.directIdx].spin)
 					op.GeoM.Translate(rotateAdjust[monster.directIdx].dx*config.FrameWidth, rotateAdjust[monster.directIdx].dy*config.FrameHeight)
 					op.GeoM.Translate(monster.x, monster.y)
-					screen.DrawImage(weapon.Image.SubImage(image.Rect(0, 0, config.FrameWidth, config.FrameHeight)).(*ebiten.Image), op)
+					g.batch.DrawImage(weapon.Image.Sub(frameRect), op)
 				}
 			}
 		}
+		g.batch.End()
+
+		// 怪物近战武器的轨迹
+		for _, monster := range g.monsters {
+			if weapon, ok := monster.weapon.(*MeleeWeapon); ok {
+				weapon.DrawTrail(screen)
+			}
+		}
+
+		// 绘制粒子效果
+		if g.particles != nil {
+			g.particles.Draw(screen)
+		}
 
-		// 设置血条的位置和尺寸
-		x := g.player.x
-		y := g.player.y - 5                                         // 位于角色头顶上方
-		width := float64(config.FrameWidth) * g.player.health / 100 // 血条宽度根据当前血量动态变化
-		height := 5                                                 // 血条高度
-		// 绘制血条底部
-		ebitenutil.DrawRect(screen, x, y, float64(config.FrameWidth), float64(height), color.Gray{0x80})
-		// 绘制血条
-		ebitenutil.DrawRect(screen, x, y, float64(width), float64(height), color.RGBA{0xFF, 0x00, 0x00, 0xFF})
+		// 绘制人物头顶的伤害数字
+		DrawDamageNumbers(screen, g)
 
 		// 地图上的武器
+		g.batch.Begin(screen)
 		for id, weapon := range g.weapons {
 			op := &ebiten.DrawImageOptions{}
 			op.GeoM.Translate(g.weaponPosition[id][0], g.weaponPosition[id][1])
-			screen.DrawImage(weapon.GetImage().SubImage(image.Rect(0, 0, config.FrameWidth, config.FrameHeight)).(*ebiten.Image), op)
+			g.batch.DrawImage(weapon.GetImage().Sub(frameRect), op)
+		}
+		g.batch.End()
+
+		// 绘制分数、存活时间、连击数、技能槽以及经验条等 HUD
+		DrawHUD(screen, g)
+	}
+
+	if g.mode == config.ModeLevelUp {
+		DrawLevelUp(screen, g)
+	}
+
+	if g.mode == config.ModeRoundOver {
+		DrawRoundOver(screen, g)
+	}
+
+	if g.mode == config.ModePause {
+		DrawPause(screen)
+	}
+}
+
+// drawPlayer 绘制玩家的技能效果、角色、武器以及血条，倒地的玩家绘制为灰色
+func (g *Game) drawPlayer(screen *ebiten.Image, player *Player, i int) {
+	// 绘制技能效果
+	for _, slot := range player.skills {
+		if slot.active {
+			slot.skill.Draw(screen, player)
 		}
 	}
+
+	// 绘制角色
+	op := &ebiten.DrawImageOptions{}
+	op.GeoM.Translate(player.x, player.y)
+	if player.downed {
+		op.ColorScale.Scale(0.4, 0.4, 0.4, 0.8)
+	}
+	player.StatusTint(op)
+	DrawCharacter(screen, player, op)
+
+	// 绘制角色武器
+	if player.weapon != nil && !player.downed {
+		switch player.weapon.(type) {
+		case *MeleeWeapon:
+			weapon := player.weapon.(*MeleeWeapon)
+			op = &ebiten.DrawImageOptions{}
+			op.GeoM.Rotate(weapon.angle)
+			op.GeoM.Translate(player.x+player.weaponX, player.y+player.weaponY)
+			screen.DrawImage(weapon.Image.Sub(frameRect), op)
+			weapon.DrawTrail(screen)
+		case *RangedWeapon:
+			weapon := player.weapon.(*RangedWeapon)
+			op = &ebiten.DrawImageOptions{}
+			op.GeoM.Rotate(directions[player.directIdx].spin)
+			op.GeoM.Translate(rotateAdjust[player.directIdx].dx*config.FrameWidth, rotateAdjust[player.directIdx].dy*config.FrameHeight)
+			op.GeoM.Translate(player.x, player.y)
+			screen.DrawImage(weapon.Image.Sub(frameRect), op)
+		}
+	}
+
+	// 在角色头顶上方绘制血条，血条宽度根据当前血量动态变化。血条位于游戏世界中，不随 HUD 缩放
+	x := player.x
+	y := player.y - 5
+	health := &Bar{Width: config.FrameWidth, Height: 5, Value: player.health / player.maxHealth, Color: color.RGBA{0xFF, 0x00, 0x00, 0xFF}, Back: color.RGBA{0x80, 0x80, 0x80, 0xFF}}
+	health.Draw(screen, x, y)
+	// 绘制护盾，护盾值按生命值上限的比例覆盖在血条上方
+	if shield := player.Shield(); shield > 0 {
+		ebitenutil.DrawRect(screen, x, y, float64(config.FrameWidth)*math.Min(shield/player.maxHealth, 1), 2, pickupDefs[PickupShield].color)
+	}
+	// 血条下方绘制冲刺次数
+	DrawDashBar(screen, player)
+
+	// 绘制玩家编号以及救起进度
+	DrawPlayerTag(screen, g, i)
 }
 
 func (g *Game) Layout(outsideWidth, outsideHeight int) (int, int) {
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"avoid-the-enemies/content/config"
+	"image"
+	"time"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"golang.org/x/image/math/f64"
+
+	"avoid-the-enemies/content/utils"
+)
+
+// Hazard 地图上的危险区域，进入区域的人物会受到状态效果
+type Hazard struct {
+	pos       f64.Vec2   // 区域左上角的位置
+	radius    float64    // 区域的半径
+	effect    StatusKind // 施加的状态效果
+	remaining int        // 剩余的帧数
+	frame     int        // 动画的帧数
+}
+
+// Center 区域的中心位置
+func (h *Hazard) Center() (float64, float64) {
+	return h.pos[0] + 32, h.pos[1] + 32
+}
+
+// Contains 人物是否处于区域内
+func (h *Hazard) Contains(p *Player) bool {
+	cx, cy := h.Center()
+	return utils.GetDistance(cx, cy, p.x+config.FrameWidth/2, p.y+config.FrameHeight/2) < h.radius
+}
+
+// GenerateHazard 每隔一段时间在地图上随机位置生成火焰区域
+func GenerateHazard(g *Game) {
+	if g.clock.Since(g.hazardTimer) > time.Second*10 {
+		g.hazardTimer = g.clock.Now()
+		if len(g.hazards) < 2 {
+			g.uniqueId++
+			g.hazards[g.uniqueId] = &Hazard{
+				pos:       f64.Vec2{g.rng.Float64() * (config.ScreenWidth - 64), g.rng.Float64() * (config.ScreenHeight - 64)},
+				radius:    24,
+				effect:    StatusBurn,
+				remaining: 8 * 60,
+			}
+		}
+	}
+}
+
+// HazardMove 推进危险区域，并对区域内的人物施加状态效果
+func HazardMove(g *Game) {
+	for _, id := range sortedKeys(g.hazards) {
+		h := g.hazards[id]
+		h.frame++
+		h.remaining--
+		if h.remaining <= 0 {
+			delete(g.hazards, id)
+			continue
+		}
+		for _, p := range g.livingPlayers() {
+			if !p.Invincible() && h.Contains(p) {
+				p.ApplyStatus(h.effect)
+			}
+		}
+		for _, monster := range g.monsters {
+			if h.Contains(monster) {
+				monster.ApplyStatus(h.effect)
+			}
+		}
+	}
+}
+
+// DrawHazards 绘制地图上的危险区域
+func DrawHazards(screen *ebiten.Image, g *Game) {
+	for _, h := range g.hazards {
+		op := &ebiten.DrawImageOptions{}
+		op.GeoM.Translate(h.pos[0], h.pos[1])
+		// 即将消失时逐渐变淡
+		if h.remaining < 60 {
+			op.ColorScale.ScaleAlpha(float32(h.remaining) / 60)
+		}
+		i := (h.frame / 5) % 4
+		sx, sy := i*64, 0
+		screen.DrawImage(fireImage.Sub(image.Rect(sx, sy, sx+64, sy+64)), op)
+	}
+}
@@ -0,0 +1,211 @@
+package main
+
+import (
+	"avoid-the-enemies/content/config"
+	"image"
+	"image/color"
+	"math"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/text/v2"
+	"github.com/hajimehoshi/ebiten/v2/vector"
+)
+
+// Anchor 控件对齐的位置，既是屏幕上的位置，也是控件自身用来对齐的点
+type Anchor int
+
+const (
+	AnchorTopLeft Anchor = iota
+	AnchorTop
+	AnchorTopRight
+	AnchorLeft
+	AnchorCenter
+	AnchorRight
+	AnchorBottomLeft
+	AnchorBottom
+	AnchorBottomRight
+)
+
+// point 锚点在宽为 w、高为 h 的矩形中的位置
+func (a Anchor) point(w, h float64) (float64, float64) {
+	return float64(a%3) / 2 * w, float64(a/3) / 2 * h
+}
+
+// Widget HUD 中的控件，尺寸以屏幕像素为单位。
+// 所有模式的 Layout 都返回固定的逻辑屏幕尺寸，窗口的缩放由 ebiten 统一处理，控件不需要自己缩放
+type Widget interface {
+	Size() (w, h float64)
+	// Draw 绘制控件，(x, y) 为控件左上角在屏幕上的位置
+	Draw(screen *ebiten.Image, x, y float64)
+}
+
+// HUD 按锚点在屏幕上摆放控件
+type HUD struct{}
+
+// hud 全局唯一的 HUD，游戏和联网客户端共用
+var hud = &HUD{}
+
+// Place 把控件的 anchor 点对齐到屏幕的 anchor 点，再偏移 (dx, dy) 后绘制
+func (h *HUD) Place(screen *ebiten.Image, anchor Anchor, dx, dy float64, w Widget) {
+	bounds := screen.Bounds()
+	x, y := anchor.point(float64(bounds.Dx()), float64(bounds.Dy()))
+	h.DrawAt(screen, anchor, x+dx, y+dy, w)
+}
+
+// DrawAt 把控件的 anchor 点对齐到屏幕上的 (x, y) 后绘制
+func (h *HUD) DrawAt(screen *ebiten.Image, anchor Anchor, x, y float64, w Widget) {
+	width, height := w.Size()
+	ax, ay := anchor.point(width, height)
+	w.Draw(screen, x-ax, y-ay)
+}
+
+// fade 按 alpha 降低预乘透明度的颜色的不透明度
+func fade(c color.RGBA, alpha float64) color.RGBA {
+	a := min(max(alpha, 0), 1)
+	return color.RGBA{uint8(float64(c.R) * a), uint8(float64(c.G) * a), uint8(float64(c.B) * a), uint8(float64(c.A) * a)}
+}
+
+// Label 一行或者多行文字，多行时行距等于字号
+type Label struct {
+	Text     string
+	FontSize float64
+	Color    color.RGBA
+}
+
+func (l *Label) Size() (float64, float64) {
+	return text.Measure(l.Text, arcadeFace(l.FontSize), l.FontSize)
+}
+
+func (l *Label) Draw(screen *ebiten.Image, x, y float64) {
+	op := &text.DrawOptions{}
+	op.GeoM.Translate(x, y)
+	op.ColorScale.ScaleWithColor(l.Color)
+	op.LineSpacing = l.FontSize
+	text.Draw(screen, l.Text, arcadeFace(l.FontSize), op)
+}
+
+// Bar 从左往右填充的进度条
+type Bar struct {
+	Width, Height float64
+	Value         float64 // 填充的比例，取值为 0 到 1
+	Color         color.RGBA
+	Back          color.RGBA // 未填充部分的颜色
+}
+
+func (b *Bar) Size() (float64, float64) {
+	return b.Width, b.Height
+}
+
+func (b *Bar) Draw(screen *ebiten.Image, x, y float64) {
+	w, h := float32(b.Width), float32(b.Height)
+	vector.DrawFilledRect(screen, float32(x), float32(y), w, h, b.Back, false)
+	vector.DrawFilledRect(screen, float32(x), float32(y), w*float32(min(max(b.Value, 0), 1)), h, b.Color, false)
+}
+
+// Icon 缩放到指定尺寸的图片，图片为 nil 时只绘制背景和边框
+type Icon struct {
+	Image         *ebiten.Image
+	Width, Height float64
+	Back          color.RGBA // 背景颜色，透明时不绘制背景
+	Border        color.RGBA // 边框颜色，透明时不绘制边框
+}
+
+func (i *Icon) Size() (float64, float64) {
+	return i.Width, i.Height
+}
+
+func (i *Icon) Draw(screen *ebiten.Image, x, y float64) {
+	w, h := i.Width, i.Height
+	if i.Back.A > 0 {
+		vector.DrawFilledRect(screen, float32(x), float32(y), float32(w), float32(h), i.Back, false)
+	}
+	if i.Image != nil {
+		bounds := i.Image.Bounds()
+		s := min(w/float64(bounds.Dx()), h/float64(bounds.Dy()))
+		op := &ebiten.DrawImageOptions{}
+		op.GeoM.Scale(s, s)
+		op.GeoM.Translate(x+(w-float64(bounds.Dx())*s)/2, y+(h-float64(bounds.Dy())*s)/2)
+		op.Filter = ebiten.FilterLinear
+		screen.DrawImage(i.Image, op)
+	}
+	if i.Border.A > 0 {
+		vector.StrokeRect(screen, float32(x), float32(y), float32(w), float32(h), 1, i.Border, false)
+	}
+}
+
+// hudPixel 绘制扇形时使用的白色像素
+var hudPixel *ebiten.Image
+
+// Radial 从正上方开始顺时针扫过的扇形，用于显示冷却进度
+type Radial struct {
+	Radius   float64
+	Progress float64 // 已经扫过的比例，取值为 0 到 1，扫过的部分不绘制
+	Color    color.RGBA
+}
+
+func (r *Radial) Size() (float64, float64) {
+	return 2 * r.Radius, 2 * r.Radius
+}
+
+func (r *Radial) Draw(screen *ebiten.Image, x, y float64) {
+	remaining := 1 - min(max(r.Progress, 0), 1)
+	if remaining <= 0 {
+		return
+	}
+	if hudPixel == nil {
+		img := ebiten.NewImage(3, 3)
+		img.Fill(color.White)
+		hudPixel = img.SubImage(image.Rect(1, 1, 2, 2)).(*ebiten.Image)
+	}
+	radius := float32(r.Radius)
+	cx, cy := float32(x)+radius, float32(y)+radius
+	start := float32(-math.Pi/2 + 2*math.Pi*(1-remaining))
+	var path vector.Path
+	path.MoveTo(cx, cy)
+	path.Arc(cx, cy, radius, start, 3*math.Pi/2, vector.Clockwise)
+	path.Close()
+	vertices, indices := path.AppendVerticesAndIndicesForFilling(nil, nil)
+	cr, cg, cb, ca := float32(r.Color.R)/0xFF, float32(r.Color.G)/0xFF, float32(r.Color.B)/0xFF, float32(r.Color.A)/0xFF
+	for i := range vertices {
+		vertices[i].SrcX, vertices[i].SrcY = 1, 1
+		vertices[i].ColorR, vertices[i].ColorG, vertices[i].ColorB, vertices[i].ColorA = cr, cg, cb, ca
+	}
+	op := &ebiten.DrawTrianglesOptions{ColorScaleMode: ebiten.ColorScaleModePremultipliedAlpha, AntiAlias: true}
+	screen.DrawTriangles(vertices, indices, hudPixel, op)
+}
+
+// DrawHUD 绘制游戏中的 HUD：左上角为每个玩家的分数以及生效中的道具效果，上方为存活时间和波次（竞技场中为回合），
+// 右上角为连击数，屏幕底部按玩家数量平均分配，绘制每个玩家的技能槽、武器、经验条以及等级
+func DrawHUD(screen *ebiten.Image, g *Game) {
+	for i, player := range g.players {
+		y := float64(3 + i*2*(config.FontSize+3))
+		clr := color.RGBA{0xFF, 0xFF, 0xFF, 0xFF}
+		if len(g.players) > 1 {
+			clr = playerColors[i%len(playerColors)]
+		}
+		label := g.playerLabel(i) + T("hud.score", player.score)
+		if g.arena != nil {
+			label += " " + T("hud.wins", g.roundWins[player.team])
+		}
+		hud.Place(screen, AnchorTopLeft, 3, y, &Label{Text: label, FontSize: config.FontSize, Color: clr})
+
+		DrawBuffHUD(screen, player, y)
+	}
+
+	if g.arena != nil {
+		DrawArenaHUD(screen, g)
+	} else {
+		label := &Label{Text: T("hud.survival_time", int(g.clock.Since(g.startTime).Seconds())), FontSize: config.FontSize, Color: color.RGBA{0xFF, 0xFF, 0xFF, 0xFF}}
+		hud.Place(screen, AnchorTop, 0, 3, label)
+		DrawWaveHUD(screen, g)
+	}
+
+	DrawComboHUD(screen, g)
+
+	width := float64(screen.Bounds().Dx()) / float64(len(g.players))
+	for i, player := range g.players {
+		left := float64(i) * width
+		DrawSkillHUD(screen, g, player, left+3)
+		DrawXPBar(screen, player, left, width)
+	}
+}
@@ -1,63 +1,222 @@
 package main
 
 import (
-	"avoid-the-enemies/resources/images"
+	"avoid-the-enemies/content/atlas"
 	"bytes"
+	"encoding/json"
+	"fmt"
 	"github.com/hajimehoshi/ebiten/v2"
 	"image"
 	"log"
+	"path"
+	"slices"
+	"strings"
 )
 
 var (
-	akImage     *ebiten.Image
-	bulletImage *ebiten.Image
-	runnerImage *ebiten.Image
-	sickleImage *ebiten.Image
-	swordImage  *ebiten.Image
-	skillImage  *ebiten.Image
-	fireImage   *ebiten.Image
+	akImage     *Region
+	bulletImage *Region
+	runnerImage *Region
+	sickleImage *Region
+	swordImage  *Region
+	skillImage  *Region
+	fireImage   *Region
+
+	atlasImage  *ebiten.Image      // 所有图片打包成的图集，由 tools/atlas 生成，资源包覆盖图片时重新打包
+	imageByName map[string]*Region // 按名称查找图片，供数据文件引用
 )
 
 func InitImage() {
-	img, _, err := image.Decode(bytes.NewReader(images.Runner_png))
-	if err != nil {
+	imageByName = make(map[string]*Region)
+	if err := loadImages(); err != nil {
 		log.Fatal(err)
 	}
-	runnerImage = ebiten.NewImageFromImage(img)
+	for name, r := range map[string]**Region{
+		"ak":     &akImage,
+		"bullet": &bulletImage,
+		"runner": &runnerImage,
+		"sickle": &sickleImage,
+		"sword":  &swordImage,
+		"skill":  &skillImage,
+		"fire":   &fireImage,
+	} {
+		if *r = imageByName[name]; *r == nil {
+			log.Fatal(fmt.Errorf("atlas: missing image %q", name))
+		}
+	}
+}
 
-	img, _, err = image.Decode(bytes.NewReader(images.Sickle_png))
+// loadImages 加载图集，资源包中有单独的图片时从图集中还原所有原图，替换后重新打包。
+// 重新加载时原地更新已有的 Region，武器等持有的图片随之改变
+func loadImages() error {
+	rawImage, err := ReadAsset("images/atlas.png")
 	if err != nil {
-		log.Fatal(err)
+		return err
 	}
-	sickleImage = ebiten.NewImageFromImage(img)
-
-	img, _, err = image.Decode(bytes.NewReader(images.Sword_png))
+	img, _, err := image.Decode(bytes.NewReader(rawImage))
 	if err != nil {
-		log.Fatal(err)
+		return fmt.Errorf("atlas.png: %w", err)
 	}
-	swordImage = ebiten.NewImageFromImage(img)
-
-	img, _, err = image.Decode(bytes.NewReader(images.AK_png))
+	rawAtlas, err := ReadAsset("images/atlas.json")
 	if err != nil {
-		log.Fatal(err)
+		return err
+	}
+	var a atlas.Atlas
+	if err := json.Unmarshal(rawAtlas, &a); err != nil {
+		return fmt.Errorf("atlas.json: %w", err)
 	}
-	akImage = ebiten.NewImageFromImage(img)
 
-	img, _, err = image.Decode(bytes.NewReader(images.Bullet_png))
-	if err != nil {
-		log.Fatal(err)
+	overrides := slices.DeleteFunc(OverrideAssetNames("images", ".png"), func(name string) bool {
+		return name == "images/atlas.png"
+	})
+	if len(overrides) > 0 {
+		sources := a.Extract(img)
+		for _, name := range overrides {
+			raw, err := ReadAsset(name)
+			if err != nil {
+				return err
+			}
+			src, _, err := image.Decode(bytes.NewReader(raw))
+			if err != nil {
+				return fmt.Errorf("%s: %w", name, err)
+			}
+			base := strings.TrimSuffix(path.Base(name), ".png")
+			i := slices.IndexFunc(sources, func(s atlas.Source) bool { return s.Name == base })
+			if i < 0 {
+				sources = append(sources, atlas.Source{Name: base, Image: src})
+			} else {
+				sources[i].Image = src
+			}
+		}
+		packed, repacked, err := atlas.Pack(sources, atlas.DefaultOptions)
+		if err != nil {
+			return err
+		}
+		img, a = packed, *repacked
+	}
+	for name := range imageByName {
+		if a.Sprites[name] == nil {
+			return fmt.Errorf("atlas: missing image %q", name)
+		}
 	}
-	bulletImage = ebiten.NewImageFromImage(img)
 
-	img, _, err = image.Decode(bytes.NewReader(images.Skill_png))
-	if err != nil {
-		log.Fatal(err)
+	atlasImage = ebiten.NewImageFromImage(img)
+	for name, sprite := range a.Sprites {
+		r := imageByName[name]
+		if r == nil {
+			r = &Region{name: name}
+			imageByName[name] = r
+		}
+		r.width, r.height = sprite.W, sprite.H
+		r.pieces = r.pieces[:0]
+		for _, p := range sprite.Pieces {
+			r.pieces = append(r.pieces, atlasPiece{src: p.Src.Rectangle(), dst: image.Pt(p.X, p.Y)})
+		}
+		r.subs = make(map[image.Rectangle]*ebiten.Image)
 	}
-	skillImage = ebiten.NewImageFromImage(img)
+	return nil
+}
 
-	img, _, err = image.Decode(bytes.NewReader(images.Fire_png))
-	if err != nil {
-		log.Fatal(err)
+// Region 图集中的一张原图，使用原图自己的坐标取子图片。原图太宽时在图集中被切成几段
+type Region struct {
+	name          string
+	width, height int
+	pieces        []atlasPiece
+	subs          map[image.Rectangle]*ebiten.Image // 缓存的子图片，只在绘制时使用
+}
+
+// atlasPiece 原图中的一段区域以及它在图集中的左上角
+type atlasPiece struct {
+	src image.Rectangle
+	dst image.Point
+}
+
+// Bounds 原图的大小
+func (r *Region) Bounds() image.Rectangle {
+	return image.Rect(0, 0, r.width, r.height)
+}
+
+// Image 返回整张原图，原图必须没有被切开
+func (r *Region) Image() *ebiten.Image {
+	return r.Sub(r.Bounds())
+}
+
+// Sub 返回原图中 rect 区域的子图片，rect 必须位于同一段之内，同样的区域只创建一次子图片
+func (r *Region) Sub(rect image.Rectangle) *ebiten.Image {
+	if img, ok := r.subs[rect]; ok {
+		return img
+	}
+	img := atlasImage.SubImage(r.atlasRect(rect)).(*ebiten.Image)
+	r.subs[rect] = img
+	return img
+}
+
+// atlasRect 原图中 rect 区域在图集中的位置
+func (r *Region) atlasRect(rect image.Rectangle) image.Rectangle {
+	for _, p := range r.pieces {
+		if rect.In(p.src) {
+			return rect.Add(p.dst.Sub(p.src.Min))
+		}
+	}
+	log.Panicf("image %s: %v is not inside one piece of the atlas", r.name, rect)
+	return image.Rectangle{}
+}
+
+// ImageDrawer 可以绘制图片的目标，*ebiten.Image 直接绘制，*SpriteBatch 收集后一起绘制
+type ImageDrawer interface {
+	DrawImage(img *ebiten.Image, op *ebiten.DrawImageOptions)
+}
+
+// batchMaxQuads 一次 DrawTriangles 最多绘制的矩形数量
+const batchMaxQuads = ebiten.MaxIndicesCount / 6
+
+// SpriteBatch 收集图集中的子图片，结束时用一次 DrawTriangles 绘制，顶点和索引在多次绘制之间复用。
+// 只能绘制图集中的图片，所有图片使用默认的混合方式
+type SpriteBatch struct {
+	target   *ebiten.Image
+	vertices []ebiten.Vertex
+	indices  []uint16
+}
+
+// Begin 开始向 target 收集图片
+func (b *SpriteBatch) Begin(target *ebiten.Image) {
+	b.target = target
+	b.vertices = b.vertices[:0]
+	b.indices = b.indices[:0]
+}
+
+// DrawImage 按 op 的变换和颜色收集一张图集中的子图片，达到上限时先绘制已收集的图片
+func (b *SpriteBatch) DrawImage(img *ebiten.Image, op *ebiten.DrawImageOptions) {
+	if len(b.vertices)/4 >= batchMaxQuads {
+		b.End()
+	}
+	bounds := img.Bounds()
+	w, h := float64(bounds.Dx()), float64(bounds.Dy())
+	// ColorScale 是预乘透明度的
+	cr, cg, cb, ca := op.ColorScale.R(), op.ColorScale.G(), op.ColorScale.B(), op.ColorScale.A()
+	v := uint16(len(b.vertices))
+	for _, corner := range [4][2]float64{{0, 0}, {w, 0}, {0, h}, {w, h}} {
+		x, y := op.GeoM.Apply(corner[0], corner[1])
+		b.vertices = append(b.vertices, ebiten.Vertex{
+			DstX:   float32(x),
+			DstY:   float32(y),
+			SrcX:   float32(float64(bounds.Min.X) + corner[0]),
+			SrcY:   float32(float64(bounds.Min.Y) + corner[1]),
+			ColorR: cr,
+			ColorG: cg,
+			ColorB: cb,
+			ColorA: ca,
+		})
+	}
+	b.indices = append(b.indices, v, v+1, v+2, v+1, v+3, v+2)
+}
+
+// End 绘制收集的所有图片
+func (b *SpriteBatch) End() {
+	if len(b.indices) > 0 {
+		op := &ebiten.DrawTrianglesOptions{ColorScaleMode: ebiten.ColorScaleModePremultipliedAlpha}
+		b.target.DrawTriangles(b.vertices, b.indices, atlasImage, op)
 	}
-	fireImage = ebiten.NewImageFromImage(img)
+	b.vertices = b.vertices[:0]
+	b.indices = b.indices[:0]
 }
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"avoid-the-enemies/content/config"
+	"fmt"
+	"testing"
+
+	"github.com/hajimehoshi/ebiten/v2"
+)
+
+// benchEntities 与 bench 子命令相同的一组实体，只衡量 CPU 的耗时，GPU 的绘制时间需要运行 bench 子命令
+func benchEntities(n int) []benchEntity {
+	b := &benchGame{cases: []benchCase{{mode: benchBatch, entities: n}}}
+	b.rng.Seed(1)
+	b.reset()
+	return b.entities
+}
+
+// BenchmarkSpriteBatch 把所有实体收集到 SpriteBatch 中，实体数量不超过一次绘制的上限，不会提交绘制
+func BenchmarkSpriteBatch(b *testing.B) {
+	target := ebiten.NewImage(config.ScreenWidth, config.ScreenHeight)
+	for _, n := range []int{1000, 5000, 10000} {
+		entities := benchEntities(n)
+		b.Run(fmt.Sprint(n), func(b *testing.B) {
+			var batch SpriteBatch
+			op := &ebiten.DrawImageOptions{}
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				batch.Begin(target)
+				for j := range entities {
+					e := &entities[j]
+					op.GeoM.Reset()
+					op.GeoM.Translate(e.x, e.y)
+					batch.DrawImage(e.region.Sub(e.rect), op)
+				}
+			}
+		})
+	}
+}
+
+// BenchmarkRegion 比较缓存的子图片与每次调用 SubImage
+func BenchmarkRegion(b *testing.B) {
+	entities := benchEntities(1000)
+	b.Run(benchCached, func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			for j := range entities {
+				e := &entities[j]
+				e.region.Sub(e.rect)
+			}
+		}
+	})
+	b.Run(benchSubImage, func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			for j := range entities {
+				e := &entities[j]
+				_ = atlasImage.SubImage(e.region.atlasRect(e.rect)).(*ebiten.Image)
+			}
+		}
+	})
+}
+
+func TestRegionSub(t *testing.T) {
+	r := runnerImage
+	if got := r.Sub(frameRect); got != r.Sub(frameRect) {
+		t.Error("Sub created a new image for the same rect")
+	}
+	// 子图片在图集中的位置与原图中的位置相差原图所在段的偏移
+	p := r.pieces[0]
+	want := frameRect.Add(p.dst.Sub(p.src.Min))
+	if got := r.Sub(frameRect).Bounds(); got != want {
+		t.Errorf("Sub(%v).Bounds() = %v, want %v", frameRect, got, want)
+	}
+}
@@ -0,0 +1,171 @@
+package main
+
+import (
+	"math"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/inpututil"
+)
+
+// maxSkillSlots 每个玩家的技能槽数量：skillKeys 对应的技能槽加上角色专属技能槽
+const maxSkillSlots = 3
+
+// gamepadDeadZone 手柄摇杆的死区，小于该值的偏移视为没有操作
+const gamepadDeadZone = 0.25
+
+// Input 玩家一帧内的操作，与具体的输入设备无关
+type Input struct {
+	X, Y        float64             // 移动方向，每个分量在 -1 到 1 之间
+	Fire        bool                // 远程武器开火，只在按下的那一帧为 true
+	Dash        bool                // 冲刺，只在按下的那一帧为 true
+	Skills      [maxSkillSlots]bool // 释放对应技能槽的技能，只在按下的那一帧为 true
+	Left, Right bool                // 菜单中左右移动光标，只在按下的那一帧为 true
+}
+
+// InputSource 玩家操作的来源，例如键盘的一部分或者一个手柄
+type InputSource interface {
+	Read() Input             // 读取这一帧的操作
+	SkillLabel(i int) string // 第 i 个技能槽在 HUD 上显示的按键名称
+}
+
+// KeyboardInput 键盘上的一组按键
+type KeyboardInput struct {
+	Left, Right, Up, Down ebiten.Key
+	Fire                  ebiten.Key
+	Dash                  []ebiten.Key
+	Skills                [maxSkillSlots]ebiten.Key
+}
+
+var (
+	// 单人游戏的按键
+	soloKeyboard = &KeyboardInput{
+		Left: ebiten.KeyArrowLeft, Right: ebiten.KeyArrowRight, Up: ebiten.KeyArrowUp, Down: ebiten.KeyArrowDown,
+		Fire:   ebiten.KeySpace,
+		Dash:   []ebiten.Key{ebiten.KeyShiftLeft, ebiten.KeyShiftRight},
+		Skills: [maxSkillSlots]ebiten.Key{ebiten.KeyQ, ebiten.KeyE, signatureSkillKey},
+	}
+	// 多人游戏时键盘左半边的按键
+	leftKeyboard = &KeyboardInput{
+		Left: ebiten.KeyA, Right: ebiten.KeyD, Up: ebiten.KeyW, Down: ebiten.KeyS,
+		Fire:   ebiten.KeySpace,
+		Dash:   []ebiten.Key{ebiten.KeyShiftLeft},
+		Skills: [maxSkillSlots]ebiten.Key{ebiten.KeyQ, ebiten.KeyE, ebiten.KeyR},
+	}
+	// 多人游戏时键盘右半边的按键
+	rightKeyboard = &KeyboardInput{
+		Left: ebiten.KeyArrowLeft, Right: ebiten.KeyArrowRight, Up: ebiten.KeyArrowUp, Down: ebiten.KeyArrowDown,
+		Fire:   ebiten.KeyEnter,
+		Dash:   []ebiten.Key{ebiten.KeyShiftRight},
+		Skills: [maxSkillSlots]ebiten.Key{ebiten.KeyComma, ebiten.KeyPeriod, ebiten.KeySlash},
+	}
+)
+
+// keyLabels 名称过长的按键在 HUD 上显示的名称
+var keyLabels = map[ebiten.Key]string{
+	ebiten.KeyComma:  ",",
+	ebiten.KeyPeriod: ".",
+	ebiten.KeySlash:  "/",
+}
+
+func (k *KeyboardInput) Read() Input {
+	in := Input{
+		Fire:  inpututil.IsKeyJustPressed(k.Fire),
+		Left:  inpututil.IsKeyJustPressed(k.Left),
+		Right: inpututil.IsKeyJustPressed(k.Right),
+	}
+	if ebiten.IsKeyPressed(k.Left) {
+		in.X--
+	}
+	if ebiten.IsKeyPressed(k.Right) {
+		in.X++
+	}
+	if ebiten.IsKeyPressed(k.Up) {
+		in.Y--
+	}
+	if ebiten.IsKeyPressed(k.Down) {
+		in.Y++
+	}
+	for _, key := range k.Dash {
+		in.Dash = in.Dash || inpututil.IsKeyJustPressed(key)
+	}
+	for i, key := range k.Skills {
+		in.Skills[i] = inpututil.IsKeyJustPressed(key)
+	}
+	return in
+}
+
+func (k *KeyboardInput) SkillLabel(i int) string {
+	if label, ok := keyLabels[k.Skills[i]]; ok {
+		return label
+	}
+	return k.Skills[i].String()
+}
+
+// GamepadInput 标准布局的手柄，左摇杆或者十字键移动，A 开火，B 冲刺，X、Y、RB 释放技能
+type GamepadInput struct {
+	id ebiten.GamepadID
+}
+
+var gamepadSkillButtons = [maxSkillSlots]ebiten.StandardGamepadButton{
+	ebiten.StandardGamepadButtonRightLeft,
+	ebiten.StandardGamepadButtonRightTop,
+	ebiten.StandardGamepadButtonFrontTopRight,
+}
+
+var gamepadSkillLabels = [maxSkillSlots]string{"X", "Y", "RB"}
+
+func (p *GamepadInput) Read() Input {
+	in := Input{
+		X:     ebiten.StandardGamepadAxisValue(p.id, ebiten.StandardGamepadAxisLeftStickHorizontal),
+		Y:     ebiten.StandardGamepadAxisValue(p.id, ebiten.StandardGamepadAxisLeftStickVertical),
+		Fire:  inpututil.IsStandardGamepadButtonJustPressed(p.id, ebiten.StandardGamepadButtonRightBottom),
+		Dash:  inpututil.IsStandardGamepadButtonJustPressed(p.id, ebiten.StandardGamepadButtonRightRight),
+		Left:  inpututil.IsStandardGamepadButtonJustPressed(p.id, ebiten.StandardGamepadButtonLeftLeft),
+		Right: inpututil.IsStandardGamepadButtonJustPressed(p.id, ebiten.StandardGamepadButtonLeftRight),
+	}
+	if math.Abs(in.X) < gamepadDeadZone {
+		in.X = 0
+	}
+	if math.Abs(in.Y) < gamepadDeadZone {
+		in.Y = 0
+	}
+	// 十字键优先于摇杆
+	if ebiten.IsStandardGamepadButtonPressed(p.id, ebiten.StandardGamepadButtonLeftLeft) {
+		in.X = -1
+	}
+	if ebiten.IsStandardGamepadButtonPressed(p.id, ebiten.StandardGamepadButtonLeftRight) {
+		in.X = 1
+	}
+	if ebiten.IsStandardGamepadButtonPressed(p.id, ebiten.StandardGamepadButtonLeftTop) {
+		in.Y = -1
+	}
+	if ebiten.IsStandardGamepadButtonPressed(p.id, ebiten.StandardGamepadButtonLeftBottom) {
+		in.Y = 1
+	}
+	for i, button := range gamepadSkillButtons {
+		in.Skills[i] = inpututil.IsStandardGamepadButtonJustPressed(p.id, button)
+	}
+	return in
+}
+
+func (p *GamepadInput) SkillLabel(i int) string {
+	return gamepadSkillLabels[i]
+}
+
+// InputSources 为 n 个玩家分配操作来源，单人游戏使用整个键盘，
+// 多人游戏时前两名玩家分别使用键盘的左右两半，之后的玩家按连接顺序使用手柄
+func InputSources(n int) []InputSource {
+	if n <= 1 {
+		return []InputSource{soloKeyboard}
+	}
+	sources := []InputSource{leftKeyboard, rightKeyboard}
+	for _, id := range ebiten.AppendGamepadIDs(nil) {
+		if ebiten.IsStandardGamepadLayoutAvailable(id) {
+			sources = append(sources, &GamepadInput{id: id})
+		}
+	}
+	if len(sources) > n {
+		sources = sources[:n]
+	}
+	return sources
+}
@@ -0,0 +1,197 @@
+package main
+
+import (
+	"avoid-the-enemies/content/config"
+	"image/color"
+	"strconv"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/inpututil"
+	"github.com/hajimehoshi/ebiten/v2/text/v2"
+	"github.com/hajimehoshi/ebiten/v2/vector"
+)
+
+// Modifiers 升级获得的属性加成，作用于玩家以及玩家装备的武器
+type Modifiers struct {
+	spinBonus         float64 // 近战武器旋转速度加成
+	pierce            int     // 子弹额外穿透的敌人数量
+	cooldownReduction float64 // 技能冷却时间缩减比例
+	dashRecharge      float64 // 冲刺次数恢复速度加成
+}
+
+// Upgrade 升级时可以选择的强化
+type Upgrade struct {
+	name      string // 强化的名称，训练接口中使用
+	key       string // 显示的名称的字符串键，加上 .desc 为说明的字符串键
+	apply     func(p *Player)
+	available func(p *Player) bool // 是否还可以选择该强化，为 nil 时总是可以选择
+}
+
+var upgradeList = []*Upgrade{
+	{
+		name: "MAX HP",
+		key:  "upgrade.max_hp",
+		apply: func(p *Player) {
+			p.maxHealth += 20
+			p.health += 20
+		},
+	},
+	{
+		name: "SPEED",
+		key:  "upgrade.speed",
+		apply: func(p *Player) {
+			p.speed *= 1.1
+		},
+	},
+	{
+		name: "SPIN",
+		key:  "upgrade.spin",
+		apply: func(p *Player) {
+			p.mods.spinBonus += 0.2
+		},
+	},
+	{
+		name: "PIERCE",
+		key:  "upgrade.pierce",
+		apply: func(p *Player) {
+			p.mods.pierce++
+		},
+	},
+	{
+		name: "COOLDOWN",
+		key:  "upgrade.cooldown",
+		apply: func(p *Player) {
+			p.mods.cooldownReduction += 0.1
+		},
+		available: func(p *Player) bool {
+			return p.mods.cooldownReduction < 0.5
+		},
+	},
+	{
+		name: "DASH",
+		key:  "upgrade.dash",
+		apply: func(p *Player) {
+			p.mods.dashRecharge += 0.25
+		},
+	},
+}
+
+// XPToNextLevel 从 level 级升到下一级需要的经验
+func XPToNextLevel(level int) int {
+	return 5 + level*3
+}
+
+// ApplyModifiers 将属性加成同步到玩家装备的武器上
+func (p *Player) ApplyModifiers() {
+	if p.weapon != nil {
+		p.weapon.ApplyModifiers(p.mods)
+	}
+}
+
+// RollUpgrades 随机选择 n 个不重复的可选强化
+func RollUpgrades(rng *Rand, p *Player, n int) []*Upgrade {
+	var candidates []*Upgrade
+	for _, upgrade := range upgradeList {
+		if upgrade.available == nil || upgrade.available(p) {
+			candidates = append(candidates, upgrade)
+		}
+	}
+	rng.Shuffle(len(candidates), func(i, j int) {
+		candidates[i], candidates[j] = candidates[j], candidates[i]
+	})
+	if len(candidates) > n {
+		candidates = candidates[:n]
+	}
+	return candidates
+}
+
+// resolveLevelUp 经验足够时升级，暂停游戏并由升级的玩家选择强化
+func (g *Game) resolveLevelUp() {
+	for _, p := range g.players {
+		need := XPToNextLevel(p.level)
+		if p.xp < need {
+			continue
+		}
+		p.xp -= need
+		p.level++
+		g.levelUpPlayer = p
+		g.upgradeChoices = RollUpgrades(&g.rng, p, 3)
+		g.upgradeCursor = 0
+		g.mode = config.ModeLevelUp
+		return
+	}
+}
+
+// resolveModeLevelUp 选择强化，左右键移动光标，空格键确认，也可以直接按数字键选择，升级的玩家也可以用自己的按键选择
+func (g *Game) resolveModeLevelUp() {
+	in := g.levelUpPlayer.input.Read()
+	// 玩家由联网或者对战指定时只响应升级的玩家自己的操作，本地键盘的快捷键不参与模拟
+	local := g.seats == nil
+	if local && inpututil.IsKeyJustPressed(ebiten.KeyArrowLeft) || in.Left {
+		g.upgradeCursor = (g.upgradeCursor + len(g.upgradeChoices) - 1) % len(g.upgradeChoices)
+	}
+	if local && inpututil.IsKeyJustPressed(ebiten.KeyArrowRight) || in.Right {
+		g.upgradeCursor = (g.upgradeCursor + 1) % len(g.upgradeChoices)
+	}
+	chosen := -1
+	for i := range g.upgradeChoices {
+		if local && inpututil.IsKeyJustPressed(ebiten.Key1+ebiten.Key(i)) {
+			chosen = i
+		}
+	}
+	if local && (inpututil.IsKeyJustPressed(ebiten.KeySpace) || inpututil.IsKeyJustPressed(ebiten.KeyEnter)) || in.Fire {
+		chosen = g.upgradeCursor
+	}
+	if chosen < 0 {
+		return
+	}
+	g.upgradeChoices[chosen].apply(g.levelUpPlayer)
+	g.levelUpPlayer.ApplyModifiers()
+	g.upgradeChoices = nil
+	g.levelUpPlayer = nil
+	g.mode = config.ModeGame
+}
+
+// DrawXPBar 在屏幕底部从 left 开始绘制宽度为 width 的经验条，技能槽上方绘制等级
+func DrawXPBar(screen *ebiten.Image, p *Player, left, width float64) {
+	progress := float64(p.xp) / float64(XPToNextLevel(p.level))
+	hud.Place(screen, AnchorBottomLeft, left, 0, &Bar{Width: width, Height: 2, Value: progress, Color: pickupDefs[PickupXP].color, Back: color.RGBA{0x40, 0x40, 0x40, 0xFF}})
+	hud.Place(screen, AnchorBottomLeft, left+3, -3-20-3, &Label{Text: T("hud.level", p.level+1), FontSize: config.FontSize, Color: color.RGBA{0xFF, 0xFF, 0xFF, 0xFF}})
+}
+
+// DrawLevelUp 绘制升级时的强化选择卡片
+func DrawLevelUp(screen *ebiten.Image, g *Game) {
+	vector.DrawFilledRect(screen, 0, 0, config.ScreenWidth, config.ScreenHeight, color.RGBA{0x00, 0x00, 0x00, 0xA0}, false)
+
+	op := &text.DrawOptions{}
+	op.GeoM.Translate(config.ScreenWidth/2, 3*config.TitleFontSize)
+	op.ColorScale.ScaleWithColor(color.White)
+	op.PrimaryAlign = text.AlignCenter
+	text.Draw(screen, g.playerLabel(g.playerIndex(g.levelUpPlayer))+T("levelup.title"), arcadeFace(config.TitleFontSize), op)
+
+	const width, height, gap = 96, 72, 8
+	left := (config.ScreenWidth - len(g.upgradeChoices)*width - (len(g.upgradeChoices)-1)*gap) / 2
+	for i, upgrade := range g.upgradeChoices {
+		x := float32(left + i*(width+gap))
+		y := float32(80)
+		vector.DrawFilledRect(screen, x, y, width, height, color.RGBA{0x20, 0x20, 0x40, 0xFF}, false)
+		borderColor := color.Color(color.Gray{0x80})
+		if i == g.upgradeCursor {
+			borderColor = color.RGBA{0xFF, 0xE0, 0x30, 0xFF}
+		}
+		vector.StrokeRect(screen, x, y, width, height, 2, borderColor, false)
+
+		op := &text.DrawOptions{}
+		op.GeoM.Translate(float64(x)+width/2, float64(y)+8)
+		op.ColorScale.ScaleWithColor(color.White)
+		op.PrimaryAlign = text.AlignCenter
+		text.Draw(screen, strconv.Itoa(i+1)+"."+T(upgrade.key), arcadeFace(config.FontSize), op)
+
+		op = &text.DrawOptions{}
+		op.GeoM.Translate(float64(x)+width/2, float64(y)+32)
+		op.ColorScale.ScaleWithColor(color.Gray{0xC0})
+		op.LineSpacing = config.FontSize
+		op.PrimaryAlign = text.AlignCenter
+		text.Draw(screen, T(upgrade.key+".desc"), arcadeFace(6), op)
+	}
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path"
+	"slices"
+	"strings"
+)
+
+// defaultLocale 默认的语言，其他语言缺少的字符串使用这种语言
+const defaultLocale = "en"
+
+var (
+	localeList    []*Locale // 所有语言，按 ID 排序
+	currentLocale *Locale   // 界面使用的语言
+)
+
+// Locale 一种语言的字符串表，由 resources/locales 下的同名 json 文件定义
+type Locale struct {
+	ID        string            `json:"-"`          // 文件名，例如 en、zh
+	Name      string            `json:"name"`       // 设置界面中显示的名称，用这种语言书写
+	Fonts     []string          `json:"fonts"`      // 像素字体中没有的字符依次从这些字体中查找，绝对路径为系统中的字体，不存在的文件会被跳过
+	FontScale float64           `json:"font_scale"` // 后备字体相对于像素字体的字号比例，为 0 时与像素字体相同
+	Strings   map[string]string `json:"strings"`
+}
+
+func InitLocale() {
+	if err := loadLocales(); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// loadLocales 加载所有语言文件，重新加载时保持当前的语言
+func loadLocales() error {
+	var loaded []*Locale
+	for _, file := range AssetNames("locales", ".json") {
+		raw, err := ReadAsset(file)
+		if err != nil {
+			return err
+		}
+		l := &Locale{ID: strings.TrimSuffix(path.Base(file), ".json")}
+		if err := json.Unmarshal(raw, l); err != nil {
+			return fmt.Errorf("%s: %w", file, err)
+		}
+		if l.FontScale <= 0 {
+			l.FontScale = 1
+		}
+		loaded = append(loaded, l)
+	}
+	if !slices.ContainsFunc(loaded, func(l *Locale) bool { return l.ID == defaultLocale }) {
+		return fmt.Errorf("locales: missing %s.json", defaultLocale)
+	}
+	id := defaultLocale
+	if currentLocale != nil {
+		id = currentLocale.ID
+	}
+	localeList = loaded
+	SetLocale(id)
+	return nil
+}
+
+// findLocale ID 为 id 的语言，没有时返回 nil
+func findLocale(id string) *Locale {
+	i := slices.IndexFunc(localeList, func(l *Locale) bool { return l.ID == id })
+	if i < 0 {
+		return nil
+	}
+	return localeList[i]
+}
+
+// SetLocale 切换到 ID 为 id 的语言并加载它的后备字体，id 为空时按系统语言选择，没有这种语言时使用默认语言。
+// 重新加载语言文件后即使 ID 不变也会重新加载后备字体
+func SetLocale(id string) {
+	if id == "" {
+		id = systemLocale()
+	}
+	l := findLocale(id)
+	if l == nil {
+		l = findLocale(defaultLocale)
+	}
+	if l == currentLocale {
+		return
+	}
+	currentLocale = l
+	loadFallbackFonts()
+}
+
+// systemLocale 按 LC_ALL、LC_MESSAGES、LANG 环境变量猜测系统语言，例如 zh_CN.UTF-8 为 zh
+func systemLocale() string {
+	for _, key := range []string{"LC_ALL", "LC_MESSAGES", "LANG"} {
+		v := os.Getenv(key)
+		if v == "" || v == "C" || v == "POSIX" {
+			continue
+		}
+		id, _, _ := strings.Cut(v, "_")
+		id, _, _ = strings.Cut(id, ".")
+		return strings.ToLower(id)
+	}
+	return defaultLocale
+}
+
+// Lookup 当前语言中 key 对应的字符串，当前语言中没有时使用默认语言
+func Lookup(key string) (string, bool) {
+	if currentLocale != nil {
+		if s, ok := currentLocale.Strings[key]; ok {
+			return s, true
+		}
+	}
+	if l := findLocale(defaultLocale); l != nil {
+		if s, ok := l.Strings[key]; ok {
+			return s, true
+		}
+	}
+	return "", false
+}
+
+// T 界面上显示的字符串，所有语言中都没有 key 时返回 key 本身。有 args 时把字符串作为格式传给 fmt.Sprintf
+func T(key string, args ...any) string {
+	s, ok := Lookup(key)
+	if !ok {
+		s = key
+	}
+	if len(args) > 0 {
+		return fmt.Sprintf(s, args...)
+	}
+	return s
+}
@@ -16,26 +16,78 @@ package main
 
 import (
 	"avoid-the-enemies/content/config"
+	"flag"
 	_ "image/png"
 	"log"
-	"math/rand"
-	"time"
+	"os"
 
 	"github.com/hajimehoshi/ebiten/v2"
 )
 
+// headless 无界面运行（联网服务器），不创建音效
+var headless bool
+
 func Init() {
-	rand.New(rand.NewSource(time.Now().UnixNano()))
 	InitImage()
+	InitAnimation()
 	InitFont()
+	InitLocale()
+	InitShader()
+	InitSound()
+	InitMusic()
 	InitWeapon()
+	InitSkill()
+	InitCharacter()
 }
 
 func main() {
+	// 子命令：server 运行无界面的联网服务器，connect 作为客户端加入服务器，versus 与另一台电脑点对点对战，
+	// env 运行强化学习环境服务器，bot 无界面运行多局电脑玩家游戏，sim 按参数矩阵模拟并输出平衡性报告，
+	// bench 比较绘制大量实体时不同绘制方式的耗时
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "server":
+			RunServer(os.Args[2:])
+			return
+		case "connect":
+			RunClient(os.Args[2:])
+			return
+		case "versus":
+			RunVersus(os.Args[2:])
+			return
+		case "env":
+			RunEnv(os.Args[2:])
+			return
+		case "bot":
+			RunBots(os.Args[2:])
+			return
+		case "sim":
+			RunSim(os.Args[2:])
+			return
+		case "bench":
+			RunBench(os.Args[2:])
+			return
+		}
+	}
+
+	// -assets 使用目录或者 zip 资源包覆盖嵌入的资源，-dev 在游戏运行时监视资源包并重新加载改动的资源，
+	// 没有指定资源包时监视源码中的 resources 目录
+	assets := flag.String("assets", "", "asset pack directory or zip file overriding the embedded assets")
+	dev := flag.Bool("dev", false, "watch the asset pack and hot reload changed assets")
+	flag.Parse()
+	if *dev && *assets == "" {
+		*assets = "resources"
+	}
+	InitAssets(*assets)
+	if *dev {
+		WatchAssets()
+	}
+
 	Init()
 	ebiten.SetWindowSize(config.ScreenWidth*3, config.ScreenHeight*3)
 	ebiten.SetWindowTitle("Avoid the Enemies")
-	g := &Game{}
+	g := &Game{profile: InitProfile()}
+	g.profile.Settings.Apply()
 	g.init()
 	if err := ebiten.RunGame(g); err != nil {
 		log.Fatal(err)
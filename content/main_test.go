@@ -0,0 +1,13 @@
+package main
+
+import (
+	"os"
+	"testing"
+)
+
+// TestMain 以无界面方式加载资源，测试中的游戏与联网服务器一样不创建音效和画面效果
+func TestMain(m *testing.M) {
+	headless = true
+	Init()
+	os.Exit(m.Run())
+}
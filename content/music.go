@@ -0,0 +1,293 @@
+package main
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"slices"
+	"sync/atomic"
+
+	"github.com/hajimehoshi/ebiten/v2/audio"
+)
+
+const (
+	musicFadeFrames = 90  // 切换音乐时交叉淡入淡出的帧数
+	musicDuck       = 0.3 // 暂停时音乐音量降低到的比例
+	musicDuckFrames = 20  // 音乐音量降低或者恢复所需的帧数
+)
+
+const (
+	intensityRise        = 1.0 / 60  // 紧张程度每帧上升的最大幅度
+	intensityFall        = 1.0 / 300 // 紧张程度每帧下降的最大幅度，平静下来比紧张起来慢
+	intensityCrowdRadius = 96        // 与听者的距离小于这个距离的怪物计入紧张程度
+	intensityCrowd       = 8         // 附近有这么多怪物时怪物带来的紧张程度达到最大
+	intensityDamage      = 50        // 最近受到这么多伤害时伤害带来的紧张程度达到最大
+	intensityDamageDecay = 0.98      // 最近受到的伤害每帧衰减的比例
+	intensityLateGame    = 300       // 存活这么多秒后时间带来的紧张程度达到最大
+)
+
+// musicTracks 按名称查找的背景音乐，由 resources/data/music.json 定义
+var musicTracks map[string][]MusicLayer
+
+// music 全局唯一的音乐播放器
+var music = &MusicPlayer{duck: 1}
+
+// MusicLayer 一首音乐中的一层，同一首音乐的所有层同步播放，每一层是一个单独的音频文件。
+// 紧张程度从 From 上升到 To 时这一层从静音淡入到最大音量，From 和 To 都为 0 时始终以最大音量播放
+type MusicLayer struct {
+	File string  `json:"file"` // 相对于 resources 的路径
+	From float64 `json:"from"`
+	To   float64 `json:"to"`
+}
+
+// Gain 紧张程度为 intensity 时这一层的音量
+func (l MusicLayer) Gain(intensity float64) float64 {
+	if l.To <= l.From {
+		if intensity >= l.From {
+			return 1
+		}
+		return 0
+	}
+	return min(max((intensity-l.From)/(l.To-l.From), 0), 1)
+}
+
+// MusicMood 计算音乐紧张程度所需的游戏状态
+type MusicMood struct {
+	Nearby      int     // 听者附近的怪物数量
+	DamageTaken float64 // 本局所有玩家受到的总伤害
+	Elapsed     float64 // 本局经过的秒数
+}
+
+// MusicPlayer 循环播放背景音乐，切换音乐时新的音乐淡入、旧的音乐淡出，
+// 音乐的各层随游戏的紧张程度淡入淡出
+type MusicPlayer struct {
+	current      *musicVoice   // 正在淡入或者播放的音乐
+	fading       []*musicVoice // 正在淡出的音乐
+	duck         float64       // 当前的闪避比例，暂停时逐渐降低到 musicDuck
+	intensity    float64       // 平滑后的紧张程度，取值为 0 到 1
+	damageTaken  float64       // 上一帧的总伤害，用于计算这一帧受到的伤害
+	recentDamage float64       // 最近受到的伤害，随时间衰减
+}
+
+// musicVoice 一首正在播放的音乐
+type musicVoice struct {
+	name   string
+	player *audio.Player // 加载失败时为 nil
+	stream *layerStream
+	fade   float64 // 淡入淡出的进度，取值为 0 到 1
+}
+
+func InitMusic() {
+	if err := loadMusicTracks(); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// loadMusicTracks 加载并检查音乐的定义
+func loadMusicTracks() error {
+	raw, err := ReadAsset("data/music.json")
+	if err != nil {
+		return err
+	}
+	var tracks map[string][]MusicLayer
+	if err := json.Unmarshal(raw, &tracks); err != nil {
+		return fmt.Errorf("music.json: %w", err)
+	}
+	for name, layers := range tracks {
+		if len(layers) == 0 {
+			return fmt.Errorf("music %s: no layers", name)
+		}
+	}
+	musicTracks = tracks
+	return nil
+}
+
+// updateIntensity 按听者附近的怪物数量、最近受到的伤害以及存活时间推进紧张程度
+func (m *MusicPlayer) updateIntensity(mood MusicMood) {
+	// 总伤害变小说明开始了新的一局
+	damage := max(mood.DamageTaken-m.damageTaken, 0)
+	m.damageTaken = mood.DamageTaken
+	m.recentDamage = m.recentDamage*intensityDamageDecay + damage
+
+	crowd := min(float64(mood.Nearby)/intensityCrowd, 1)
+	hurt := min(m.recentDamage/intensityDamage, 1)
+	late := min(mood.Elapsed/intensityLateGame, 1)
+	target := min(0.5*crowd+0.5*hurt+0.3*late, 1)
+	if m.intensity < target {
+		m.intensity = min(m.intensity+intensityRise, target)
+	} else {
+		m.intensity = max(m.intensity-intensityFall, target)
+	}
+}
+
+// Update 每帧调用，切换到名为 name 的音乐（为空时淡出所有音乐），paused 时降低音乐音量并保持紧张程度不变
+func (m *MusicPlayer) Update(name string, paused bool, mood MusicMood) {
+	if audioContext == nil {
+		return
+	}
+	current := ""
+	if m.current != nil {
+		current = m.current.name
+	}
+	if name != current {
+		if m.current != nil {
+			m.fading = append(m.fading, m.current)
+			m.current = nil
+		}
+		if name != "" {
+			voice, err := newMusicVoice(name)
+			if err != nil {
+				// 记录下名称，加载失败的音乐不会每帧重试
+				log.Println("play music:", err)
+				voice = &musicVoice{name: name}
+			}
+			m.current = voice
+		}
+	}
+
+	target := 1.0
+	if paused {
+		target = musicDuck
+	} else {
+		m.updateIntensity(mood)
+	}
+	step := (1 - musicDuck) / musicDuckFrames
+	if m.duck < target {
+		m.duck = min(m.duck+step, target)
+	} else {
+		m.duck = max(m.duck-step, target)
+	}
+
+	volume := BusVolume(BusMusic) * m.duck
+	if m.current != nil && m.current.player != nil {
+		m.current.fade = min(m.current.fade+1.0/musicFadeFrames, 1)
+		m.current.player.SetVolume(volume * m.current.fade)
+		m.current.stream.SetIntensity(m.intensity)
+	}
+	fading := m.fading[:0]
+	for _, v := range m.fading {
+		v.fade -= 1.0 / musicFadeFrames
+		if v.player == nil {
+			continue
+		}
+		if v.fade <= 0 {
+			v.player.Close()
+			continue
+		}
+		v.player.SetVolume(volume * v.fade)
+		fading = append(fading, v)
+	}
+	m.fading = fading
+}
+
+// newMusicVoice 从头开始循环播放名为 name 的音乐，音量从 0 开始淡入，各层的音量按当前的紧张程度设置
+func newMusicVoice(name string) (*musicVoice, error) {
+	layers, ok := musicTracks[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown music %q", name)
+	}
+	stream := &layerStream{}
+	for _, l := range layers {
+		raw, err := ReadAsset(l.File)
+		if err != nil {
+			return nil, err
+		}
+		decoded, err := decodeAudio(l.File, raw)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", l.File, err)
+		}
+		stem := &musicStem{layer: l, src: audio.NewInfiniteLoop(decoded, decoded.Length()), gain: l.Gain(music.intensity)}
+		stream.stems = append(stream.stems, stem)
+	}
+	stream.SetIntensity(music.intensity)
+	player, err := audioContext.NewPlayer(stream)
+	if err != nil {
+		return nil, fmt.Errorf("music %s: %w", name, err)
+	}
+	player.SetVolume(0)
+	player.Play()
+	return &musicVoice{name: name, player: player, stream: stream}, nil
+}
+
+// loadMusic 重新加载音乐的定义，与正在播放的同一首音乐交叉淡入淡出
+func loadMusic() error {
+	if err := loadMusicTracks(); err != nil {
+		return err
+	}
+	if music.current == nil {
+		return nil
+	}
+	voice, err := newMusicVoice(music.current.name)
+	if err != nil {
+		return err
+	}
+	music.fading = append(music.fading, music.current)
+	music.current = voice
+	return nil
+}
+
+// musicChanged 改动的文件是否是音乐的定义或者正在播放的音乐的某一层
+func musicChanged(file string) bool {
+	if file == "data/music.json" {
+		return true
+	}
+	if music.current == nil {
+		return false
+	}
+	return slices.ContainsFunc(musicTracks[music.current.name], func(l MusicLayer) bool { return l.File == file })
+}
+
+// layerStream 把一首音乐的所有层混合成一个 16 位立体声音频流。各层在同一次读取中前进相同的采样数，
+// 因此始终保持同步；每一层的音量在一次读取中逐个采样地过渡到目标音量，避免音量跳变产生杂音
+type layerStream struct {
+	stems []*musicStem
+	buf   []byte
+	mix   []float64
+}
+
+// musicStem 正在播放的一层
+type musicStem struct {
+	layer  MusicLayer
+	src    io.Reader
+	target atomic.Uint64 // 目标音量的 float64 的位，在主循环中设置
+	gain   float64       // 当前音量，只在播放器的协程中读写
+}
+
+// SetIntensity 按紧张程度设置每一层的目标音量
+func (s *layerStream) SetIntensity(intensity float64) {
+	for _, stem := range s.stems {
+		stem.target.Store(math.Float64bits(stem.layer.Gain(intensity)))
+	}
+}
+
+func (s *layerStream) Read(p []byte) (int, error) {
+	n := len(p) / 4 * 4
+	if cap(s.buf) < n {
+		s.buf = make([]byte, n)
+		s.mix = make([]float64, n/2)
+	}
+	buf, mix := s.buf[:n], s.mix[:n/2]
+	clear(mix)
+	for _, stem := range s.stems {
+		if _, err := io.ReadFull(stem.src, buf); err != nil {
+			return 0, err
+		}
+		target := math.Float64frombits(stem.target.Load())
+		step := (target - stem.gain) / float64(max(len(mix)/2, 1))
+		for i := range mix {
+			x := float64(int16(binary.LittleEndian.Uint16(buf[2*i:])))
+			mix[i] += x * stem.gain
+			if i%2 == 1 {
+				stem.gain += step
+			}
+		}
+		stem.gain = target
+	}
+	for i, v := range mix {
+		binary.LittleEndian.PutUint16(p[2*i:], uint16(int16(min(max(v, math.MinInt16), math.MaxInt16))))
+	}
+	return n, nil
+}
@@ -0,0 +1,28 @@
+package main
+
+import "testing"
+
+// TestMusicLayersSameLength 同一首音乐的各层分别循环播放，长度不同时各层会逐渐错开
+func TestMusicLayersSameLength(t *testing.T) {
+	if len(musicTracks["game"]) < 2 {
+		t.Fatal("the game music has no layers to compare")
+	}
+	for name, layers := range musicTracks {
+		var length int64
+		for i, l := range layers {
+			raw, err := ReadAsset(l.File)
+			if err != nil {
+				t.Fatal(err)
+			}
+			decoded, err := decodeAudio(l.File, raw)
+			if err != nil {
+				t.Fatalf("%s: %v", l.File, err)
+			}
+			if i == 0 {
+				length = decoded.Length()
+			} else if decoded.Length() != length {
+				t.Errorf("music %s: %s is %d bytes after decoding, the first layer is %d bytes", name, l.File, decoded.Length(), length)
+			}
+		}
+	}
+}
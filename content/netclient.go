@@ -0,0 +1,394 @@
+package main
+
+import (
+	"avoid-the-enemies/content/config"
+	"avoid-the-enemies/content/netcode"
+	"errors"
+	"flag"
+	"image/color"
+	"log"
+	"math"
+	"net"
+	"time"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/inpututil"
+	"github.com/hajimehoshi/ebiten/v2/text/v2"
+	"golang.org/x/image/math/f64"
+)
+
+const (
+	interpolationDelay = 6   // 插值落后于最新快照的帧数，约 100ms
+	inputRedundancy    = 3   // 每个数据包中重复发送的操作帧数
+	helloInterval      = 30  // 加入服务器之前重复发送请求的间隔帧数
+	maxPendingInputs   = 120 // 等待服务器处理的操作帧数上限
+	maxBufferedFrames  = 32  // 用于插值的快照数量上限
+)
+
+// NetClient 联网模式的客户端，发送本地操作，预测本地玩家的移动，插值绘制其他实体
+type NetClient struct {
+	conn        *netcode.Conn
+	packets     <-chan netcode.Datagram
+	character   string // 加入时选择的角色
+	view        *Game  // 用于绘制的游戏状态，由快照同步
+	history     netcode.History
+	snapshots   []*netcode.Snapshot // 按帧序号排列的最近快照
+	playerID    uint32              // 服务器分配的玩家，加入之前为 0
+	frame       int
+	seq         uint32               // 最新的操作序号
+	pending     []netcode.InputFrame // 服务器尚未处理的本地操作
+	lastInput   uint32               // 服务器已经处理的最新操作序号
+	sinceLatest int                  // 收到最新快照之后经过的帧数
+}
+
+func NewNetClient(conn *netcode.Conn, character string) *NetClient {
+	view := &Game{profile: NewProfile("")}
+	view.init()
+	return &NetClient{
+		conn:      conn,
+		packets:   conn.Receive(),
+		character: character,
+		view:      view,
+	}
+}
+
+// RunClient 作为客户端加入服务器，用法：avoid-the-enemies connect [-latency 50ms] [-jitter 10ms] [-loss 0.05] host:port
+func RunClient(args []string) {
+	fs := flag.NewFlagSet("connect", flag.ExitOnError)
+	var cond netcode.LinkConditions
+	cond.RegisterFlags(fs)
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		log.Fatal("usage: avoid-the-enemies connect [flags] host:port")
+	}
+
+	Init()
+	addr, err := net.ResolveUDPAddr("udp", fs.Arg(0))
+	if err != nil {
+		log.Fatal(err)
+	}
+	conn, err := net.DialUDP("udp", nil, addr)
+	if err != nil {
+		log.Fatal(err)
+	}
+	c := NewNetClient(netcode.NewConn(conn, cond), InitProfile().Character)
+	ebiten.SetWindowSize(config.ScreenWidth*3, config.ScreenHeight*3)
+	ebiten.SetWindowTitle("Avoid the Enemies - " + fs.Arg(0))
+	if err := ebiten.RunGame(c); err != nil && !errors.Is(err, ebiten.Termination) {
+		log.Fatal(err)
+	}
+}
+
+func (c *NetClient) Update() error {
+	c.receive()
+	c.frame++
+	c.sinceLatest++
+
+	// 按 Esc 键离开服务器
+	if inpututil.IsKeyJustPressed(ebiten.KeyEscape) {
+		c.conn.Send([]byte{netcode.PacketBye}, nil)
+		return ebiten.Termination
+	}
+
+	if c.playerID == 0 {
+		if c.frame%helloInterval == 1 {
+			c.conn.Send((&netcode.Hello{Version: netcode.ProtocolVersion, Character: c.character}).Encode(), nil)
+		}
+		return nil
+	}
+
+	// 发送本帧的操作，同时重复发送最近几帧以抵抗丢包
+	c.seq++
+	c.pending = append(c.pending, encodeInputFrame(c.seq, soloKeyboard.Read()))
+	if len(c.pending) > maxPendingInputs {
+		c.pending = c.pending[len(c.pending)-maxPendingInputs:]
+	}
+	packet := &netcode.InputPacket{Frames: c.pending[max(len(c.pending)-inputRedundancy, 0):]}
+	if latest := c.latest(); latest != nil {
+		packet.Ack = latest.Tick
+	}
+	c.conn.Send(packet.Encode(), nil)
+
+	c.sync()
+	return nil
+}
+
+// receive 处理收到的所有数据包
+func (c *NetClient) receive() {
+	for {
+		select {
+		case d, ok := <-c.packets:
+			if !ok {
+				return
+			}
+			c.handle(d)
+		default:
+			return
+		}
+	}
+}
+
+func (c *NetClient) handle(d netcode.Datagram) {
+	r, packetType := netcode.NewReader(d.Data)
+	switch packetType {
+	case netcode.PacketWelcome:
+		welcome, err := netcode.DecodeWelcome(r)
+		if err == nil && c.playerID == 0 {
+			c.playerID = welcome.PlayerID
+			log.Println("joined as player", c.playerID)
+		}
+	case netcode.PacketSnapshot:
+		packet, err := netcode.DecodeSnapshotPacket(r, c.history.Get)
+		if err != nil {
+			return
+		}
+		s := packet.Snapshot
+		c.history.Put(s)
+		// 乱序到达的旧快照只作为增量压缩的基准
+		if latest := c.latest(); latest != nil && s.Tick <= latest.Tick {
+			return
+		}
+		c.snapshots = append(c.snapshots, s)
+		if len(c.snapshots) > maxBufferedFrames {
+			c.snapshots = c.snapshots[1:]
+		}
+		c.lastInput = packet.LastInput
+		c.sinceLatest = 0
+	}
+}
+
+func (c *NetClient) latest() *netcode.Snapshot {
+	if len(c.snapshots) == 0 {
+		return nil
+	}
+	return c.snapshots[len(c.snapshots)-1]
+}
+
+// sync 将插值后的快照同步到用于绘制的游戏状态，并预测本地玩家的位置
+func (c *NetClient) sync() {
+	latest := c.latest()
+	if latest == nil {
+		return
+	}
+	// 绘制落后于服务器一段时间的状态，这样总有前后两个快照可以插值
+	renderTick := float64(latest.Tick) + float64(c.sinceLatest) - interpolationDelay
+	from, to := c.snapshots[0], latest
+	for i, s := range c.snapshots {
+		if float64(s.Tick) <= renderTick {
+			from = s
+			to = s
+			if i+1 < len(c.snapshots) {
+				to = c.snapshots[i+1]
+			}
+		}
+	}
+	t := 0.0
+	if to.Tick > from.Tick {
+		t = math.Min(math.Max((renderTick-float64(from.Tick))/float64(to.Tick-from.Tick), 0), 1)
+	}
+	c.view.applySnapshot(interpolateSnapshot(from, to, t))
+	c.predict(latest)
+}
+
+// predict 从服务器确认的位置出发，重新执行服务器尚未处理的本地操作，得到本地玩家的预测位置
+func (c *NetClient) predict(latest *netcode.Snapshot) {
+	for len(c.pending) > 0 && c.pending[0].Seq <= c.lastInput {
+		c.pending = c.pending[1:]
+	}
+	self := latest.Entity(c.playerID)
+	p := c.view.playerByID(int(c.playerID))
+	if self == nil || p == nil {
+		return
+	}
+	p.x, p.y = float64(self.X), float64(self.Y)
+	if config.Mode(latest.Mode) != config.ModeGame || self.Flags&(netcode.FlagDowned|netcode.FlagDashing) != 0 {
+		return
+	}
+	for _, f := range c.pending {
+		in := decodeInputFrame(f)
+		p.Move(in.X*float64(self.Speed), 0)
+		p.Move(0, in.Y*float64(self.Speed))
+	}
+}
+
+// interpolateSnapshot 在两个快照之间插值实体的位置和武器角度，t 为 0 时为 from，为 1 时为 to
+func interpolateSnapshot(from, to *netcode.Snapshot, t float64) *netcode.Snapshot {
+	s := *to
+	s.Entities = append([]netcode.Entity(nil), to.Entities...)
+	for i := range s.Entities {
+		e := &s.Entities[i]
+		prev := from.Entity(e.ID)
+		if prev == nil || prev.Kind != e.Kind {
+			continue
+		}
+		e.X = prev.X + (e.X-prev.X)*float32(t)
+		e.Y = prev.Y + (e.Y-prev.Y)*float32(t)
+		// 角度沿较短的方向插值
+		delta := math.Remainder(float64(e.Angle-prev.Angle), 2*math.Pi)
+		e.Angle = prev.Angle + float32(delta*t)
+	}
+	return &s
+}
+
+// applySnapshot 将快照中的实体同步到游戏状态中，只用于绘制
+func (g *Game) applySnapshot(s *netcode.Snapshot) {
+	g.mode = config.Mode(s.Mode)
+	g.startTime = g.clock.Now().Add(-time.Duration(s.Elapsed) * time.Second / 60)
+	g.timeScale = float64(s.TimeScale)
+	g.upgradeCursor = int(s.Cursor)
+	g.upgradeChoices = nil
+	for _, i := range s.Choices {
+		if int(i) < len(upgradeList) {
+			g.upgradeChoices = append(g.upgradeChoices, upgradeList[i])
+		}
+	}
+
+	seen := make(map[int]bool)
+	var players []*Player
+	for i := range s.Entities {
+		e := &s.Entities[i]
+		id := int(e.ID)
+		seen[id] = true
+		pos := f64.Vec2{float64(e.X), float64(e.Y)}
+		switch e.Kind {
+		case netcode.EntityPlayer:
+			p := g.playerByID(id)
+			if p == nil {
+				character := characters[0]
+				if int(e.Aux) < len(characters) {
+					character = characters[e.Aux]
+				}
+				p = NewCharacterPlayer(character, id, g.clock)
+				p.input = soloKeyboard
+			}
+			applyPerson(p, e)
+			p.shield = float64(e.Shield)
+			p.shieldUntil = g.clock.Now().Add(time.Second)
+			p.score = int(e.Score)
+			p.level = int(e.Level)
+			p.xp = int(e.Counter)
+			p.dashCharges = float64(e.Charges) / 100
+			p.downed = e.Flags&netcode.FlagDowned != 0
+			players = append(players, p)
+		case netcode.EntityMonster:
+			monster, ok := g.monsters[id]
+			if !ok {
+				monster = &Player{id: id, clock: g.clock, weaponX: config.FrameWidth / 2, weaponY: config.FrameHeight / 2}
+				g.monsters[id] = monster
+			}
+			applyPerson(monster, e)
+		case netcode.EntityBullet:
+			bullet, ok := g.suspends[id]
+			if !ok {
+				weapon, ok := weaponByIndex(e.Weapon).(*RangedWeapon)
+				if !ok {
+					continue
+				}
+				bullet = &Suspend{rangeWeapon: weapon}
+				g.suspends[id] = bullet
+			}
+			bullet.pos = pos
+			bullet.directIndex = int(e.Dir)
+		case netcode.EntityWeapon:
+			if _, ok := g.weapons[id]; !ok {
+				weapon := weaponByIndex(e.Weapon)
+				if weapon == nil {
+					continue
+				}
+				g.weapons[id] = weapon
+			}
+			g.weaponPosition[id] = pos
+		case netcode.EntityPickup:
+			g.pickups[id] = &Pickup{kind: PickupKind(e.Aux), pos: pos, remaining: int(e.Counter)}
+		case netcode.EntityHazard:
+			hazard, ok := g.hazards[id]
+			if !ok {
+				hazard = &Hazard{radius: 24, effect: StatusBurn}
+				g.hazards[id] = hazard
+			}
+			hazard.pos = pos
+			hazard.remaining = int(e.Counter)
+			hazard.frame++
+		}
+	}
+	g.players = players
+
+	// 删除快照中已经不存在的实体
+	for id := range g.monsters {
+		if !seen[id] {
+			delete(g.monsters, id)
+		}
+	}
+	for id := range g.suspends {
+		if !seen[id] {
+			delete(g.suspends, id)
+		}
+	}
+	for id := range g.weapons {
+		if !seen[id] {
+			delete(g.weapons, id)
+			delete(g.weaponPosition, id)
+		}
+	}
+	for id := range g.pickups {
+		if !seen[id] {
+			delete(g.pickups, id)
+		}
+	}
+	for id := range g.hazards {
+		if !seen[id] {
+			delete(g.hazards, id)
+		}
+	}
+	g.levelUpPlayer = g.playerByID(int(s.LevelUp))
+}
+
+// applyPerson 同步玩家和怪物共用的字段
+func applyPerson(p *Player, e *netcode.Entity) {
+	p.count++
+	p.x, p.y = float64(e.X), float64(e.Y)
+	p.directIdx = int(e.Dir)
+	if float64(e.Health) < p.health {
+		p.anim.Hurt()
+	}
+	p.health = float64(e.Health)
+	p.maxHealth = float64(e.MaxHealth)
+	if weaponIndex(p.weapon) != e.Weapon {
+		p.weapon = weaponByIndex(e.Weapon)
+	}
+	if weapon, ok := p.weapon.(*MeleeWeapon); ok {
+		weapon.angle = float64(e.Angle)
+	}
+	p.statuses = nil
+	if kind := StatusKind(e.Status); kind != StatusNone {
+		p.statuses = map[StatusKind]*Status{kind: {kind: kind}}
+	}
+	p.animate()
+}
+
+func (c *NetClient) Draw(screen *ebiten.Image) {
+	var message string
+	switch {
+	case c.playerID == 0:
+		message = T("net.connecting")
+	case c.latest() == nil:
+		message = T("net.waiting_server")
+	case c.view.mode == config.ModeTitle:
+		message = T("net.waiting_players", int(c.latest().Waiting))
+	case c.view.mode == config.ModeGameOver:
+		message = T("net.game_over", c.view.totalScore())
+	default:
+		c.view.Draw(screen)
+		return
+	}
+	op := &text.DrawOptions{}
+	op.GeoM.Translate(config.ScreenWidth/2, config.ScreenHeight/2-config.TitleFontSize)
+	op.ColorScale.ScaleWithColor(color.White)
+	op.LineSpacing = config.TitleFontSize
+	op.PrimaryAlign = text.AlignCenter
+	text.Draw(screen, message, arcadeFace(config.TitleFontSize), op)
+}
+
+func (c *NetClient) Layout(outsideWidth, outsideHeight int) (int, int) {
+	return config.ScreenWidth, config.ScreenHeight
+}
@@ -0,0 +1,94 @@
+package netcode
+
+import (
+	"flag"
+	"math/rand"
+	"net"
+	"sync"
+	"time"
+)
+
+// LinkConditions 模拟的网络状况，只作用于发送方向，客户端和服务器同时设置时往返延迟为两者之和
+type LinkConditions struct {
+	Latency time.Duration // 固定延迟
+	Jitter  time.Duration // 延迟的随机波动，可能导致数据包乱序
+	Loss    float64       // 丢包率，0 到 1 之间
+}
+
+// RegisterFlags 注册模拟网络状况的命令行参数
+func (c *LinkConditions) RegisterFlags(fs *flag.FlagSet) {
+	fs.DurationVar(&c.Latency, "latency", 0, "simulated one-way latency, e.g. 50ms")
+	fs.DurationVar(&c.Jitter, "jitter", 0, "simulated latency jitter")
+	fs.Float64Var(&c.Loss, "loss", 0, "simulated packet loss between 0 and 1")
+}
+
+// Conn 在 UDP 连接上模拟延迟、抖动和丢包
+type Conn struct {
+	*net.UDPConn
+	cond LinkConditions
+
+	mu  sync.Mutex
+	rng *rand.Rand
+}
+
+func NewConn(conn *net.UDPConn, cond LinkConditions) *Conn {
+	return &Conn{
+		UDPConn: conn,
+		cond:    cond,
+		rng:     rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+}
+
+// Send 发送数据包，addr 为 nil 时发送到已连接的地址
+func (c *Conn) Send(data []byte, addr *net.UDPAddr) {
+	c.mu.Lock()
+	lost := c.rng.Float64() < c.cond.Loss
+	delay := c.cond.Latency
+	if c.cond.Jitter > 0 {
+		delay += time.Duration((c.rng.Float64()*2 - 1) * float64(c.cond.Jitter))
+	}
+	c.mu.Unlock()
+	if lost {
+		return
+	}
+	if delay <= 0 {
+		c.write(data, addr)
+		return
+	}
+	data = append([]byte(nil), data...)
+	time.AfterFunc(delay, func() {
+		c.write(data, addr)
+	})
+}
+
+// write 发送失败时直接丢弃，与真实网络中的丢包一样由上层协议处理
+func (c *Conn) write(data []byte, addr *net.UDPAddr) {
+	if addr == nil {
+		c.UDPConn.Write(data)
+		return
+	}
+	c.UDPConn.WriteToUDP(data, addr)
+}
+
+// Datagram 收到的数据包
+type Datagram struct {
+	Addr *net.UDPAddr
+	Data []byte
+}
+
+// Receive 在后台持续读取数据包，连接关闭时关闭返回的通道
+func (c *Conn) Receive() <-chan Datagram {
+	ch := make(chan Datagram, 256)
+	go func() {
+		defer close(ch)
+		buf := make([]byte, MaxPacketSize)
+		for {
+			n, addr, err := c.ReadFromUDP(buf)
+			if err != nil {
+				return
+			}
+			ch <- Datagram{Addr: addr, Data: append([]byte(nil), buf[:n]...)}
+		}
+	}()
+	return ch
+}
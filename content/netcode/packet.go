@@ -0,0 +1,213 @@
+// Package netcode 联网模式使用的数据包格式、快照的增量压缩以及模拟网络状况的 UDP 连接
+package netcode
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+)
+
+// ProtocolVersion 协议版本，修改数据包格式时递增，版本不同的客户端无法加入服务器
+const ProtocolVersion = 2
+
+// MaxPacketSize 数据包的最大长度
+const MaxPacketSize = 64 * 1024
+
+// 数据包类型，位于每个数据包的第一个字节
+const (
+	PacketHello     byte = iota + 1 // 客户端请求加入，携带选择的角色
+	PacketWelcome                   // 服务器为客户端分配的玩家
+	PacketInput                     // 客户端最近几帧的操作
+	PacketSnapshot                  // 服务器的状态快照
+	PacketBye                       // 客户端离开
+	PacketPeerHello                 // 对战双方交换的握手信息
+	PacketChecksum                  // 对战双方交换的状态校验值
+)
+
+var (
+	ErrShortPacket   = errors.New("netcode: short packet")
+	ErrMissingBase   = errors.New("netcode: missing base snapshot")
+	ErrUnknownPacket = errors.New("netcode: unknown packet")
+)
+
+// Writer 按小端序写入数据包
+type Writer struct {
+	buf []byte
+}
+
+func NewWriter(packetType byte) *Writer {
+	return &Writer{buf: []byte{packetType}}
+}
+
+func (w *Writer) U8(v uint8)   { w.buf = append(w.buf, v) }
+func (w *Writer) I8(v int8)    { w.buf = append(w.buf, uint8(v)) }
+func (w *Writer) U16(v uint16) { w.buf = binary.LittleEndian.AppendUint16(w.buf, v) }
+func (w *Writer) U32(v uint32) { w.buf = binary.LittleEndian.AppendUint32(w.buf, v) }
+func (w *Writer) I32(v int32)  { w.U32(uint32(v)) }
+func (w *Writer) F32(v float32) {
+	w.U32(math.Float32bits(v))
+}
+
+// String 写入长度不超过 255 的字符串
+func (w *Writer) String(s string) {
+	if len(s) > math.MaxUint8 {
+		s = s[:math.MaxUint8]
+	}
+	w.U8(uint8(len(s)))
+	w.buf = append(w.buf, s...)
+}
+
+func (w *Writer) Bytes() []byte {
+	return w.buf
+}
+
+// Reader 按小端序读取数据包，数据不足时记录错误并返回零值
+type Reader struct {
+	buf []byte
+	err error
+}
+
+// NewReader 读取数据包，返回数据包的类型
+func NewReader(data []byte) (*Reader, byte) {
+	if len(data) == 0 {
+		return &Reader{err: ErrShortPacket}, 0
+	}
+	return &Reader{buf: data[1:]}, data[0]
+}
+
+func (r *Reader) next(n int) []byte {
+	if r.err != nil || len(r.buf) < n {
+		r.err = ErrShortPacket
+		return make([]byte, n)
+	}
+	b := r.buf[:n]
+	r.buf = r.buf[n:]
+	return b
+}
+
+func (r *Reader) U8() uint8   { return r.next(1)[0] }
+func (r *Reader) I8() int8    { return int8(r.next(1)[0]) }
+func (r *Reader) U16() uint16 { return binary.LittleEndian.Uint16(r.next(2)) }
+func (r *Reader) U32() uint32 { return binary.LittleEndian.Uint32(r.next(4)) }
+func (r *Reader) I32() int32  { return int32(r.U32()) }
+func (r *Reader) F32() float32 {
+	return math.Float32frombits(r.U32())
+}
+
+func (r *Reader) String() string {
+	return string(r.next(int(r.U8())))
+}
+
+// Err 读取过程中遇到的第一个错误
+func (r *Reader) Err() error {
+	return r.err
+}
+
+// Hello 客户端请求加入
+type Hello struct {
+	Version   uint8
+	Character string // 客户端选择的角色
+}
+
+func (h *Hello) Encode() []byte {
+	w := NewWriter(PacketHello)
+	w.U8(h.Version)
+	w.String(h.Character)
+	return w.Bytes()
+}
+
+func DecodeHello(r *Reader) (*Hello, error) {
+	h := &Hello{Version: r.U8(), Character: r.String()}
+	if h.Version != ProtocolVersion {
+		return nil, fmt.Errorf("netcode: protocol version %d, want %d", h.Version, ProtocolVersion)
+	}
+	return h, r.Err()
+}
+
+// Welcome 服务器为客户端分配的玩家
+type Welcome struct {
+	PlayerID uint32
+}
+
+func (m *Welcome) Encode() []byte {
+	w := NewWriter(PacketWelcome)
+	w.U32(m.PlayerID)
+	return w.Bytes()
+}
+
+func DecodeWelcome(r *Reader) (*Welcome, error) {
+	m := &Welcome{PlayerID: r.U32()}
+	return m, r.Err()
+}
+
+// 操作中的按键，每一位只在按下的那一帧为 1
+const (
+	ButtonFire uint8 = 1 << iota
+	ButtonDash
+	ButtonSkill0
+	ButtonSkill1
+	ButtonSkill2
+	ButtonLeft
+	ButtonRight
+)
+
+// InputFrame 客户端一帧的操作
+type InputFrame struct {
+	Seq     uint32 // 操作的序号，从 1 开始每帧递增
+	X, Y    int8   // 移动方向，-127 到 127
+	Buttons uint8
+}
+
+// InputPacket 客户端最近几帧的操作，重复发送以抵抗丢包
+type InputPacket struct {
+	Ack    uint32       // 客户端收到的最新快照，服务器以此作为增量压缩的基准
+	Frames []InputFrame // 按序号从小到大排列
+}
+
+func (p *InputPacket) Encode() []byte {
+	w := NewWriter(PacketInput)
+	w.U32(p.Ack)
+	w.U8(uint8(len(p.Frames)))
+	for _, f := range p.Frames {
+		w.U32(f.Seq)
+		w.I8(f.X)
+		w.I8(f.Y)
+		w.U8(f.Buttons)
+	}
+	return w.Bytes()
+}
+
+func DecodeInput(r *Reader) (*InputPacket, error) {
+	p := &InputPacket{Ack: r.U32()}
+	n := int(r.U8())
+	for i := 0; i < n && r.Err() == nil; i++ {
+		p.Frames = append(p.Frames, InputFrame{Seq: r.U32(), X: r.I8(), Y: r.I8(), Buttons: r.U8()})
+	}
+	return p, r.Err()
+}
+
+// SnapshotPacket 发送给某个客户端的快照
+type SnapshotPacket struct {
+	LastInput uint32 // 服务器已经处理的该客户端的最新操作序号，客户端据此校正预测
+	Snapshot  *Snapshot
+}
+
+// Encode 以 base 为基准增量压缩快照，base 为 nil 时发送完整的快照
+func (p *SnapshotPacket) Encode(base *Snapshot) []byte {
+	w := NewWriter(PacketSnapshot)
+	w.U32(p.LastInput)
+	EncodeSnapshot(w, p.Snapshot, base)
+	return w.Bytes()
+}
+
+// DecodeSnapshotPacket 读取快照，lookup 用于查找增量压缩的基准快照
+func DecodeSnapshotPacket(r *Reader, lookup func(tick uint32) *Snapshot) (*SnapshotPacket, error) {
+	p := &SnapshotPacket{LastInput: r.U32()}
+	s, err := DecodeSnapshot(r, lookup)
+	if err != nil {
+		return nil, err
+	}
+	p.Snapshot = s
+	return p, nil
+}
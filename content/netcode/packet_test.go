@@ -0,0 +1,142 @@
+package netcode
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestReaderShortPacket(t *testing.T) {
+	r, typ := NewReader(nil)
+	if typ != 0 || !errors.Is(r.Err(), ErrShortPacket) {
+		t.Fatalf("NewReader(nil) = type %d, error %v", typ, r.Err())
+	}
+
+	w := NewWriter(PacketWelcome)
+	w.U16(0x1234)
+	r, _ = NewReader(w.Bytes())
+	if v := r.U32(); v != 0 {
+		t.Errorf("U32 past the end = %d, want 0", v)
+	}
+	if !errors.Is(r.Err(), ErrShortPacket) {
+		t.Errorf("Err = %v, want %v", r.Err(), ErrShortPacket)
+	}
+	// 出错后即使数据足够也只返回零值
+	if v := r.U8(); v != 0 {
+		t.Errorf("U8 after error = %d, want 0", v)
+	}
+}
+
+func TestReaderShortString(t *testing.T) {
+	w := NewWriter(PacketHello)
+	w.U8(10) // 声明的长度超过剩余的数据
+	w.U8('a')
+	r, _ := NewReader(w.Bytes())
+	if s := r.String(); len(s) != 10 || strings.Trim(s, "\x00") != "" {
+		t.Errorf("String = %q, want 10 zero bytes", s)
+	}
+	if !errors.Is(r.Err(), ErrShortPacket) {
+		t.Errorf("Err = %v, want %v", r.Err(), ErrShortPacket)
+	}
+}
+
+func TestWriterReaderRoundTrip(t *testing.T) {
+	w := NewWriter(PacketInput)
+	w.U8(200)
+	w.I8(-100)
+	w.U16(60000)
+	w.U32(4000000000)
+	w.I32(-123456)
+	w.F32(3.25)
+	w.String("knight")
+	w.String(strings.Repeat("x", 300)) // 超过 255 的部分被截断
+
+	r, typ := NewReader(w.Bytes())
+	if typ != PacketInput {
+		t.Fatalf("type = %d, want %d", typ, PacketInput)
+	}
+	if v := r.U8(); v != 200 {
+		t.Errorf("U8 = %d", v)
+	}
+	if v := r.I8(); v != -100 {
+		t.Errorf("I8 = %d", v)
+	}
+	if v := r.U16(); v != 60000 {
+		t.Errorf("U16 = %d", v)
+	}
+	if v := r.U32(); v != 4000000000 {
+		t.Errorf("U32 = %d", v)
+	}
+	if v := r.I32(); v != -123456 {
+		t.Errorf("I32 = %d", v)
+	}
+	if v := r.F32(); v != 3.25 {
+		t.Errorf("F32 = %v", v)
+	}
+	if v := r.String(); v != "knight" {
+		t.Errorf("String = %q", v)
+	}
+	if v := r.String(); len(v) != 255 {
+		t.Errorf("long String has %d bytes, want 255", len(v))
+	}
+	if r.Err() != nil {
+		t.Errorf("Err = %v", r.Err())
+	}
+}
+
+func TestHelloVersion(t *testing.T) {
+	r, _ := NewReader((&Hello{Version: ProtocolVersion, Character: "reaper"}).Encode())
+	h, err := DecodeHello(r)
+	if err != nil || h.Character != "reaper" {
+		t.Fatalf("DecodeHello = %+v, %v", h, err)
+	}
+
+	r, _ = NewReader((&Hello{Version: ProtocolVersion + 1}).Encode())
+	if _, err := DecodeHello(r); err == nil {
+		t.Error("DecodeHello accepted a different protocol version")
+	}
+}
+
+func TestInputPacketRoundTrip(t *testing.T) {
+	p := &InputPacket{Ack: 42, Frames: []InputFrame{
+		{Seq: 7, X: -127, Y: 0, Buttons: ButtonFire},
+		{Seq: 8, X: 127, Y: 64, Buttons: ButtonDash | ButtonSkill1},
+	}}
+	r, typ := NewReader(p.Encode())
+	if typ != PacketInput {
+		t.Fatalf("type = %d, want %d", typ, PacketInput)
+	}
+	got, err := DecodeInput(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, p) {
+		t.Errorf("DecodeInput = %+v, want %+v", got, p)
+	}
+
+	// 帧数声明为 2 但只有一帧的数据
+	data := p.Encode()
+	r, _ = NewReader(data[:len(data)-3])
+	if _, err := DecodeInput(r); !errors.Is(err, ErrShortPacket) {
+		t.Errorf("DecodeInput of a truncated packet: error = %v, want %v", err, ErrShortPacket)
+	}
+}
+
+func TestSnapshotPacketRoundTrip(t *testing.T) {
+	base := testSnapshot(5)
+	var history History
+	history.Put(base)
+	s := testSnapshot(6)
+	s.Entities[1].X++
+
+	p := &SnapshotPacket{LastInput: 99, Snapshot: s}
+	r, _ := NewReader(p.Encode(base))
+	got, err := DecodeSnapshotPacket(r, history.Get)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.LastInput != 99 || !reflect.DeepEqual(got.Snapshot, s) {
+		t.Errorf("DecodeSnapshotPacket = %+v, want %+v", got, p)
+	}
+}
@@ -0,0 +1,62 @@
+package netcode
+
+import "fmt"
+
+// 点对点对战时双方都发送 PeerHello 握手，之后以 InputPacket 交换每一帧的操作：
+// InputFrame.Seq 为操作生效的帧序号，Ack 为收到的对方操作的最新帧序号
+
+// PeerHello 的规则标志位
+const (
+	RuleFriendlyFire uint8 = 1 << iota // 开启友军伤害
+	RuleMonsters                       // 刷新怪物
+)
+
+// PeerHello 对战双方交换的握手信息，玩家 1 使用玩家 0 的竞技场规则
+type PeerHello struct {
+	Version   uint8
+	Player    uint8  // 发送方的玩家序号，0 或 1
+	Seed      uint32 // 玩家 0 选择的随机数种子，玩家 1 发送时为 0
+	Character string // 发送方选择的角色
+	RoundTime uint16 // 每回合的秒数
+	Rounds    uint8  // 赢得比赛需要赢下的回合数
+	Rules     uint8  // RuleFriendlyFire 等标志位
+}
+
+func (h *PeerHello) Encode() []byte {
+	w := NewWriter(PacketPeerHello)
+	w.U8(h.Version)
+	w.U8(h.Player)
+	w.U32(h.Seed)
+	w.String(h.Character)
+	w.U16(h.RoundTime)
+	w.U8(h.Rounds)
+	w.U8(h.Rules)
+	return w.Bytes()
+}
+
+func DecodePeerHello(r *Reader) (*PeerHello, error) {
+	h := &PeerHello{Version: r.U8(), Player: r.U8(), Seed: r.U32(), Character: r.String()}
+	h.RoundTime, h.Rounds, h.Rules = r.U16(), r.U8(), r.U8()
+	if h.Version != ProtocolVersion {
+		return nil, fmt.Errorf("netcode: protocol version %d, want %d", h.Version, ProtocolVersion)
+	}
+	return h, r.Err()
+}
+
+// Checksum 某一帧开始时的状态校验值，用于检测双方的模拟结果不一致
+type Checksum struct {
+	Frame uint32
+	Sum   uint32
+}
+
+func (c *Checksum) Encode() []byte {
+	w := NewWriter(PacketChecksum)
+	w.U32(c.Frame)
+	w.U32(c.Sum)
+	return w.Bytes()
+}
+
+func DecodeChecksum(r *Reader) (*Checksum, error) {
+	c := &Checksum{Frame: r.U32(), Sum: r.U32()}
+	return c, r.Err()
+}
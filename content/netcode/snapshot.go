@@ -0,0 +1,247 @@
+package netcode
+
+import "sort"
+
+// EntityKind 快照中实体的种类
+type EntityKind uint8
+
+const (
+	EntityPlayer  EntityKind = iota + 1 // 玩家
+	EntityMonster                       // 怪物
+	EntityBullet                        // 远程武器的子弹
+	EntityWeapon                        // 地图上的武器
+	EntityPickup                        // 地图上的道具
+	EntityHazard                        // 地图上的危险区域
+)
+
+// 实体的标记
+const (
+	FlagDowned     uint8 = 1 << iota // 玩家倒地
+	FlagInvincible                   // 无敌
+	FlagDashing                      // 正在冲刺
+)
+
+// Entity 快照中的一个实体，不同种类的实体只使用其中的一部分字段
+type Entity struct {
+	ID        uint32
+	Kind      EntityKind
+	X, Y      float32
+	Dir       uint8   // 人物或者子弹的方向
+	Health    float32 // 生命值
+	MaxHealth float32 // 生命值上限
+	Shield    float32 // 护盾值
+	Angle     float32 // 近战武器的旋转角度
+	Weapon    uint8   // 武器类型的序号加一，没有武器时为 0
+	Flags     uint8
+	Status    uint8   // 优先级最高的状态效果
+	Score     int32   // 玩家的积分
+	Level     uint8   // 玩家的等级
+	Counter   uint16  // 玩家的经验，道具和危险区域的剩余帧数
+	Aux       uint8   // 玩家的角色序号，道具的种类
+	Speed     float32 // 玩家当前的移动速度，客户端预测移动时使用
+	Charges   uint8   // 玩家的冲刺次数乘以 100
+}
+
+// entityFields 实体中可以增量压缩的字段，第 i 个字段对应字段掩码的第 i 位
+var entityFields = []struct {
+	equal func(a, b *Entity) bool
+	write func(w *Writer, e *Entity)
+	read  func(r *Reader, e *Entity)
+}{
+	{func(a, b *Entity) bool { return a.Kind == b.Kind }, func(w *Writer, e *Entity) { w.U8(uint8(e.Kind)) }, func(r *Reader, e *Entity) { e.Kind = EntityKind(r.U8()) }},
+	{func(a, b *Entity) bool { return a.X == b.X }, func(w *Writer, e *Entity) { w.F32(e.X) }, func(r *Reader, e *Entity) { e.X = r.F32() }},
+	{func(a, b *Entity) bool { return a.Y == b.Y }, func(w *Writer, e *Entity) { w.F32(e.Y) }, func(r *Reader, e *Entity) { e.Y = r.F32() }},
+	{func(a, b *Entity) bool { return a.Dir == b.Dir }, func(w *Writer, e *Entity) { w.U8(e.Dir) }, func(r *Reader, e *Entity) { e.Dir = r.U8() }},
+	{func(a, b *Entity) bool { return a.Health == b.Health }, func(w *Writer, e *Entity) { w.F32(e.Health) }, func(r *Reader, e *Entity) { e.Health = r.F32() }},
+	{func(a, b *Entity) bool { return a.MaxHealth == b.MaxHealth }, func(w *Writer, e *Entity) { w.F32(e.MaxHealth) }, func(r *Reader, e *Entity) { e.MaxHealth = r.F32() }},
+	{func(a, b *Entity) bool { return a.Shield == b.Shield }, func(w *Writer, e *Entity) { w.F32(e.Shield) }, func(r *Reader, e *Entity) { e.Shield = r.F32() }},
+	{func(a, b *Entity) bool { return a.Angle == b.Angle }, func(w *Writer, e *Entity) { w.F32(e.Angle) }, func(r *Reader, e *Entity) { e.Angle = r.F32() }},
+	{func(a, b *Entity) bool { return a.Weapon == b.Weapon }, func(w *Writer, e *Entity) { w.U8(e.Weapon) }, func(r *Reader, e *Entity) { e.Weapon = r.U8() }},
+	{func(a, b *Entity) bool { return a.Flags == b.Flags }, func(w *Writer, e *Entity) { w.U8(e.Flags) }, func(r *Reader, e *Entity) { e.Flags = r.U8() }},
+	{func(a, b *Entity) bool { return a.Status == b.Status }, func(w *Writer, e *Entity) { w.U8(e.Status) }, func(r *Reader, e *Entity) { e.Status = r.U8() }},
+	{func(a, b *Entity) bool { return a.Score == b.Score }, func(w *Writer, e *Entity) { w.I32(e.Score) }, func(r *Reader, e *Entity) { e.Score = r.I32() }},
+	{func(a, b *Entity) bool { return a.Level == b.Level }, func(w *Writer, e *Entity) { w.U8(e.Level) }, func(r *Reader, e *Entity) { e.Level = r.U8() }},
+	{func(a, b *Entity) bool { return a.Counter == b.Counter }, func(w *Writer, e *Entity) { w.U16(e.Counter) }, func(r *Reader, e *Entity) { e.Counter = r.U16() }},
+	{func(a, b *Entity) bool { return a.Aux == b.Aux }, func(w *Writer, e *Entity) { w.U8(e.Aux) }, func(r *Reader, e *Entity) { e.Aux = r.U8() }},
+	{func(a, b *Entity) bool { return a.Speed == b.Speed }, func(w *Writer, e *Entity) { w.F32(e.Speed) }, func(r *Reader, e *Entity) { e.Speed = r.F32() }},
+	{func(a, b *Entity) bool { return a.Charges == b.Charges }, func(w *Writer, e *Entity) { w.U8(e.Charges) }, func(r *Reader, e *Entity) { e.Charges = r.U8() }},
+}
+
+// diff 与 base 相比发生变化的字段掩码
+func (e *Entity) diff(base *Entity) uint32 {
+	var mask uint32
+	for i, field := range entityFields {
+		if !field.equal(e, base) {
+			mask |= 1 << i
+		}
+	}
+	return mask
+}
+
+// Snapshot 服务器某一帧的完整状态
+type Snapshot struct {
+	Tick      uint32  // 服务器的帧序号，从 1 开始
+	Mode      uint8   // 游戏模式
+	Waiting   uint8   // 还需要等待加入的玩家数量
+	Elapsed   uint32  // 本局游戏经过的帧数
+	TimeScale float32 // 怪物的时间流速
+	LevelUp   uint32  // 正在选择强化的玩家 id
+	Choices   []uint8 // 可以选择的强化序号
+	Cursor    uint8   // 选中的强化
+	Entities  []Entity
+}
+
+// Sort 按实体 id 排序，编码快照前需要调用
+func (s *Snapshot) Sort() {
+	sort.Slice(s.Entities, func(i, j int) bool { return s.Entities[i].ID < s.Entities[j].ID })
+}
+
+// Entity 根据 id 查找实体，找不到时返回 nil
+func (s *Snapshot) Entity(id uint32) *Entity {
+	i := sort.Search(len(s.Entities), func(i int) bool { return s.Entities[i].ID >= id })
+	if i < len(s.Entities) && s.Entities[i].ID == id {
+		return &s.Entities[i]
+	}
+	return nil
+}
+
+// EncodeSnapshot 写入快照，base 不为 nil 时只写入与 base 相比新增、变化以及删除的实体
+func EncodeSnapshot(w *Writer, s, base *Snapshot) {
+	w.U32(s.Tick)
+	if base == nil {
+		base = &Snapshot{}
+	}
+	w.U32(base.Tick)
+	w.U8(s.Mode)
+	w.U8(s.Waiting)
+	w.U32(s.Elapsed)
+	w.F32(s.TimeScale)
+	w.U32(s.LevelUp)
+	w.U8(s.Cursor)
+	w.U8(uint8(len(s.Choices)))
+	for _, c := range s.Choices {
+		w.U8(c)
+	}
+
+	// 两个快照都按 id 排序，同时遍历找出变化的实体
+	type change struct {
+		entity *Entity
+		mask   uint32
+	}
+	var changes []change
+	var removed []uint32
+	zero := &Entity{}
+	i, j := 0, 0
+	for i < len(s.Entities) || j < len(base.Entities) {
+		switch {
+		case j >= len(base.Entities) || (i < len(s.Entities) && s.Entities[i].ID < base.Entities[j].ID):
+			// 新增的实体与零值比较
+			e := &s.Entities[i]
+			changes = append(changes, change{e, e.diff(zero)})
+			i++
+		case i >= len(s.Entities) || base.Entities[j].ID < s.Entities[i].ID:
+			removed = append(removed, base.Entities[j].ID)
+			j++
+		default:
+			e := &s.Entities[i]
+			if *e != base.Entities[j] {
+				changes = append(changes, change{e, e.diff(&base.Entities[j])})
+			}
+			i++
+			j++
+		}
+	}
+
+	w.U16(uint16(len(changes)))
+	for _, c := range changes {
+		w.U32(c.entity.ID)
+		w.U32(c.mask)
+		for k, field := range entityFields {
+			if c.mask&(1<<k) != 0 {
+				field.write(w, c.entity)
+			}
+		}
+	}
+	w.U16(uint16(len(removed)))
+	for _, id := range removed {
+		w.U32(id)
+	}
+}
+
+// DecodeSnapshot 读取快照，lookup 用于查找增量压缩的基准快照，基准快照已经丢弃时返回 ErrMissingBase
+func DecodeSnapshot(r *Reader, lookup func(tick uint32) *Snapshot) (*Snapshot, error) {
+	s := &Snapshot{Tick: r.U32()}
+	baseTick := r.U32()
+	s.Mode = r.U8()
+	s.Waiting = r.U8()
+	s.Elapsed = r.U32()
+	s.TimeScale = r.F32()
+	s.LevelUp = r.U32()
+	s.Cursor = r.U8()
+	n := int(r.U8())
+	for i := 0; i < n; i++ {
+		s.Choices = append(s.Choices, r.U8())
+	}
+	if r.Err() != nil {
+		return nil, r.Err()
+	}
+
+	entities := make(map[uint32]Entity)
+	if baseTick != 0 {
+		base := lookup(baseTick)
+		if base == nil {
+			return nil, ErrMissingBase
+		}
+		for _, e := range base.Entities {
+			entities[e.ID] = e
+		}
+	}
+	changed := int(r.U16())
+	for i := 0; i < changed && r.Err() == nil; i++ {
+		id := r.U32()
+		mask := r.U32()
+		e := entities[id]
+		e.ID = id
+		for k, field := range entityFields {
+			if mask&(1<<k) != 0 {
+				field.read(r, &e)
+			}
+		}
+		entities[id] = e
+	}
+	removed := int(r.U16())
+	for i := 0; i < removed && r.Err() == nil; i++ {
+		delete(entities, r.U32())
+	}
+	if r.Err() != nil {
+		return nil, r.Err()
+	}
+
+	s.Entities = make([]Entity, 0, len(entities))
+	for _, e := range entities {
+		s.Entities = append(s.Entities, e)
+	}
+	s.Sort()
+	return s, nil
+}
+
+// HistorySize 保留的历史快照数量
+const HistorySize = 64
+
+// History 最近的快照，用作增量压缩的基准
+type History struct {
+	snapshots [HistorySize]*Snapshot
+}
+
+func (h *History) Put(s *Snapshot) {
+	h.snapshots[s.Tick%HistorySize] = s
+}
+
+// Get 查找某一帧的快照，已经被覆盖时返回 nil
+func (h *History) Get(tick uint32) *Snapshot {
+	s := h.snapshots[tick%HistorySize]
+	if s == nil || s.Tick != tick {
+		return nil
+	}
+	return s
+}
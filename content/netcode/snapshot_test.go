@@ -0,0 +1,127 @@
+package netcode
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+// testSnapshot 包含各种实体的快照，实体已经按 id 排序
+func testSnapshot(tick uint32) *Snapshot {
+	s := &Snapshot{
+		Tick:      tick,
+		Mode:      2,
+		Elapsed:   tick * 2,
+		TimeScale: 1,
+		Choices:   []uint8{0, 3, 5},
+		Cursor:    1,
+		Entities: []Entity{
+			{ID: 1, Kind: EntityPlayer, X: 10, Y: 20, Dir: 1, Health: 100, MaxHealth: 100, Weapon: 2, Score: 7, Level: 2, Counter: 30, Aux: 1, Speed: 2, Charges: 200},
+			{ID: 2, Kind: EntityMonster, X: 50.5, Y: 60.25, Health: 30, MaxHealth: 30},
+			{ID: 3, Kind: EntityBullet, X: 70, Y: 80, Dir: 3},
+			{ID: 4, Kind: EntityPickup, X: 90, Y: 100, Counter: 600, Aux: 2},
+		},
+	}
+	s.Sort()
+	return s
+}
+
+// roundTrip 以 base 为基准编码快照后再解码，lookup 查找 history 中的快照
+func roundTrip(t *testing.T, s, base *Snapshot, history *History) (*Snapshot, int) {
+	t.Helper()
+	w := NewWriter(PacketSnapshot)
+	EncodeSnapshot(w, s, base)
+	data := w.Bytes()
+	r, typ := NewReader(data)
+	if typ != PacketSnapshot {
+		t.Fatalf("packet type = %d, want %d", typ, PacketSnapshot)
+	}
+	got, err := DecodeSnapshot(r, history.Get)
+	if err != nil {
+		t.Fatalf("DecodeSnapshot: %v", err)
+	}
+	return got, len(data)
+}
+
+func TestSnapshotFullRoundTrip(t *testing.T) {
+	s := testSnapshot(1)
+	got, _ := roundTrip(t, s, nil, &History{})
+	if !reflect.DeepEqual(got, s) {
+		t.Errorf("decoded snapshot\n%+v\nwant\n%+v", got, s)
+	}
+}
+
+func TestSnapshotDeltaRoundTrip(t *testing.T) {
+	base := testSnapshot(10)
+	var history History
+	history.Put(base)
+
+	s := testSnapshot(12)
+	s.Entities[0].X += 2                                   // 移动的玩家
+	s.Entities[0].Flags = FlagDashing                      // 变化的标记
+	s.Entities[1].Health = 10                              // 受伤的怪物
+	s.Entities = append(s.Entities[:2], s.Entities[3:]...) // 消失的子弹
+	s.Entities = append(s.Entities, Entity{ID: 9, Kind: EntityHazard, X: 5, Y: 6, Counter: 120})
+	s.Sort()
+
+	got, size := roundTrip(t, s, base, &history)
+	if !reflect.DeepEqual(got, s) {
+		t.Errorf("decoded snapshot\n%+v\nwant\n%+v", got, s)
+	}
+
+	// 增量快照只包含变化的字段，应该比完整的快照小
+	_, full := roundTrip(t, s, nil, &history)
+	if size >= full {
+		t.Errorf("delta snapshot is %d bytes, full snapshot is %d bytes", size, full)
+	}
+}
+
+func TestSnapshotDeltaUnchanged(t *testing.T) {
+	base := testSnapshot(10)
+	var history History
+	history.Put(base)
+
+	s := testSnapshot(11)
+	got, _ := roundTrip(t, s, base, &history)
+	if !reflect.DeepEqual(got, s) {
+		t.Errorf("decoded snapshot\n%+v\nwant\n%+v", got, s)
+	}
+}
+
+func TestSnapshotMissingBase(t *testing.T) {
+	base := testSnapshot(10)
+	w := NewWriter(PacketSnapshot)
+	EncodeSnapshot(w, testSnapshot(11), base)
+
+	var history History
+	history.Put(testSnapshot(10 + HistorySize)) // 基准快照已经被覆盖
+	r, _ := NewReader(w.Bytes())
+	if _, err := DecodeSnapshot(r, history.Get); !errors.Is(err, ErrMissingBase) {
+		t.Errorf("DecodeSnapshot error = %v, want %v", err, ErrMissingBase)
+	}
+}
+
+func TestSnapshotTruncated(t *testing.T) {
+	w := NewWriter(PacketSnapshot)
+	EncodeSnapshot(w, testSnapshot(1), nil)
+	data := w.Bytes()
+	for n := 1; n < len(data); n++ {
+		r, _ := NewReader(data[:n])
+		if _, err := DecodeSnapshot(r, (&History{}).Get); !errors.Is(err, ErrShortPacket) {
+			t.Fatalf("DecodeSnapshot of %d/%d bytes: error = %v, want %v", n, len(data), err, ErrShortPacket)
+		}
+	}
+}
+
+func TestHistory(t *testing.T) {
+	var h History
+	for tick := uint32(1); tick <= HistorySize+5; tick++ {
+		h.Put(&Snapshot{Tick: tick})
+	}
+	if s := h.Get(3); s != nil {
+		t.Errorf("Get(3) = tick %d, want nil after it was overwritten", s.Tick)
+	}
+	if s := h.Get(HistorySize + 3); s == nil || s.Tick != HistorySize+3 {
+		t.Errorf("Get(%d) = %v", HistorySize+3, s)
+	}
+}
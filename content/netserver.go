@@ -0,0 +1,445 @@
+package main
+
+import (
+	"avoid-the-enemies/content/config"
+	"avoid-the-enemies/content/netcode"
+	"flag"
+	"log"
+	"math"
+	"net"
+	"time"
+)
+
+const (
+	serverTimeout   = 5 * time.Second // 超过该时间没有收到数据包的客户端视为离开
+	gameOverDelay   = 180             // 游戏结束后重新开始前等待的帧数
+	maxInputBacklog = 4               // 服务器为每个玩家缓存的操作帧数上限
+)
+
+// NetInput 从网络收到的操作，服务器每帧按顺序取出一帧
+type NetInput struct {
+	queue    []netcode.InputFrame
+	received uint32 // 收到的最新操作序号
+	applied  uint32 // 已经处理的最新操作序号
+}
+
+// Push 缓存新的操作，重复收到的操作会被忽略
+func (n *NetInput) Push(frames []netcode.InputFrame) {
+	for _, f := range frames {
+		if f.Seq > n.received {
+			n.queue = append(n.queue, f)
+			n.received = f.Seq
+		}
+	}
+	// 积压过多时丢弃最早的移动，按键合并到下一帧，避免延迟越来越大
+	for len(n.queue) > maxInputBacklog {
+		n.queue[1].Buttons |= n.queue[0].Buttons
+		n.queue = n.queue[1:]
+	}
+}
+
+// reset 新的客户端使用该玩家时重新计算操作序号
+func (n *NetInput) reset() {
+	n.queue = nil
+	n.received = 0
+	n.applied = 0
+}
+
+// Read 取出一帧操作，没有收到操作时玩家原地不动
+func (n *NetInput) Read() Input {
+	if len(n.queue) == 0 {
+		return Input{}
+	}
+	f := n.queue[0]
+	n.queue = n.queue[1:]
+	n.applied = f.Seq
+	return decodeInputFrame(f)
+}
+
+func (n *NetInput) SkillLabel(i int) string {
+	return soloKeyboard.SkillLabel(i)
+}
+
+// encodeInputFrame 将一帧操作编码为网络传输的格式
+func encodeInputFrame(seq uint32, in Input) netcode.InputFrame {
+	f := netcode.InputFrame{
+		Seq: seq,
+		X:   int8(math.Round(in.X * math.MaxInt8)),
+		Y:   int8(math.Round(in.Y * math.MaxInt8)),
+	}
+	buttons := []struct {
+		pressed bool
+		bit     uint8
+	}{
+		{in.Fire, netcode.ButtonFire},
+		{in.Dash, netcode.ButtonDash},
+		{in.Skills[0], netcode.ButtonSkill0},
+		{in.Skills[1], netcode.ButtonSkill1},
+		{in.Skills[2], netcode.ButtonSkill2},
+		{in.Left, netcode.ButtonLeft},
+		{in.Right, netcode.ButtonRight},
+	}
+	for _, b := range buttons {
+		if b.pressed {
+			f.Buttons |= b.bit
+		}
+	}
+	return f
+}
+
+// decodeInputFrame 将网络传输的操作还原为一帧操作
+func decodeInputFrame(f netcode.InputFrame) Input {
+	return Input{
+		X:      float64(f.X) / math.MaxInt8,
+		Y:      float64(f.Y) / math.MaxInt8,
+		Fire:   f.Buttons&netcode.ButtonFire != 0,
+		Dash:   f.Buttons&netcode.ButtonDash != 0,
+		Skills: [maxSkillSlots]bool{f.Buttons&netcode.ButtonSkill0 != 0, f.Buttons&netcode.ButtonSkill1 != 0, f.Buttons&netcode.ButtonSkill2 != 0},
+		Left:   f.Buttons&netcode.ButtonLeft != 0,
+		Right:  f.Buttons&netcode.ButtonRight != 0,
+	}
+}
+
+// remoteClient 加入服务器的客户端
+type remoteClient struct {
+	addr      *net.UDPAddr
+	character *Character
+	ack       uint32    // 客户端收到的最新快照
+	lastSeen  time.Time // 上次收到数据包的时间
+}
+
+// Server 无界面运行的权威服务器，所有玩家加入后开始游戏，模拟结果以快照发送给客户端
+type Server struct {
+	conn          *netcode.Conn
+	game          *Game
+	clients       []*remoteClient // 与玩家一一对应，空位为 nil
+	inputs        []*NetInput     // 每个玩家的操作，客户端离开后保留
+	history       netcode.History
+	tick          uint32
+	gameOverTicks int
+}
+
+func NewServer(conn *netcode.Conn, players int) *Server {
+	s := &Server{
+		conn:    conn,
+		game:    &Game{profile: NewProfile("")},
+		clients: make([]*remoteClient, players),
+	}
+	for i := 0; i < players; i++ {
+		s.inputs = append(s.inputs, &NetInput{})
+	}
+	s.game.init()
+	return s
+}
+
+// RunServer 运行联网服务器，用法：avoid-the-enemies server [-addr :7777] [-players 2] [-latency 50ms] [-jitter 10ms] [-loss 0.05]
+func RunServer(args []string) {
+	fs := flag.NewFlagSet("server", flag.ExitOnError)
+	addr := fs.String("addr", ":7777", "UDP address to listen on")
+	players := fs.Int("players", 2, "number of players, the game starts when all of them have joined")
+	var cond netcode.LinkConditions
+	cond.RegisterFlags(fs)
+	fs.Parse(args)
+	if *players < 1 || *players > config.MaxPlayers {
+		log.Fatalf("players must be between 1 and %d", config.MaxPlayers)
+	}
+
+	headless = true
+	Init()
+	udpAddr, err := net.ResolveUDPAddr("udp", *addr)
+	if err != nil {
+		log.Fatal(err)
+	}
+	conn, err := net.ListenUDP("udp", udpAddr)
+	if err != nil {
+		log.Fatal(err)
+	}
+	log.Println("server listening on", conn.LocalAddr())
+	NewServer(netcode.NewConn(conn, cond), *players).Run()
+}
+
+// Run 以每秒 60 帧推进模拟，每帧处理收到的数据包并向所有客户端发送快照
+func (s *Server) Run() {
+	packets := s.conn.Receive()
+	ticker := time.NewTicker(time.Second / 60)
+	defer ticker.Stop()
+	for range ticker.C {
+		for drained := false; !drained; {
+			select {
+			case d, ok := <-packets:
+				if !ok {
+					return
+				}
+				s.handle(d)
+			default:
+				drained = true
+			}
+		}
+		s.dropTimedOut()
+		s.step()
+		s.broadcast()
+	}
+}
+
+func (s *Server) clientIndex(addr *net.UDPAddr) int {
+	for i, c := range s.clients {
+		if c != nil && c.addr.String() == addr.String() {
+			return i
+		}
+	}
+	return -1
+}
+
+// waiting 还需要等待加入的玩家数量
+func (s *Server) waiting() int {
+	n := 0
+	for _, c := range s.clients {
+		if c == nil {
+			n++
+		}
+	}
+	return n
+}
+
+func (s *Server) handle(d netcode.Datagram) {
+	r, packetType := netcode.NewReader(d.Data)
+	switch packetType {
+	case netcode.PacketHello:
+		hello, err := netcode.DecodeHello(r)
+		if err != nil {
+			log.Println("hello from", d.Addr, err)
+			return
+		}
+		i := s.clientIndex(d.Addr)
+		if i < 0 {
+			// 分配第一个空位，没有空位时忽略
+			for j, c := range s.clients {
+				if c == nil {
+					i = j
+					break
+				}
+			}
+			if i < 0 {
+				return
+			}
+			s.clients[i] = &remoteClient{addr: d.Addr, character: CharacterByID(hello.Character)}
+			s.inputs[i].reset()
+			log.Printf("player %d joined from %s", i+1, d.Addr)
+		}
+		s.clients[i].lastSeen = time.Now()
+		// 客户端收到之前会重复发送请求，每次都回复
+		s.conn.Send((&netcode.Welcome{PlayerID: uint32(i + 1)}).Encode(), d.Addr)
+	case netcode.PacketInput:
+		i := s.clientIndex(d.Addr)
+		if i < 0 {
+			return
+		}
+		p, err := netcode.DecodeInput(r)
+		if err != nil {
+			return
+		}
+		c := s.clients[i]
+		c.lastSeen = time.Now()
+		c.ack = max(c.ack, p.Ack)
+		s.inputs[i].Push(p.Frames)
+	case netcode.PacketBye:
+		if i := s.clientIndex(d.Addr); i >= 0 {
+			s.clients[i] = nil
+			log.Printf("player %d left", i+1)
+		}
+	}
+}
+
+// dropTimedOut 移除长时间没有发送数据包的客户端，其玩家留在游戏中原地不动
+func (s *Server) dropTimedOut() {
+	for i, c := range s.clients {
+		if c != nil && time.Since(c.lastSeen) > serverTimeout {
+			s.clients[i] = nil
+			log.Printf("player %d timed out", i+1)
+		}
+	}
+}
+
+// start 按加入的客户端选择的角色开始新的一局
+func (s *Server) start() {
+	seats := make([]Seat, len(s.clients))
+	for i, c := range s.clients {
+		seats[i] = Seat{input: s.inputs[i], character: c.character}
+	}
+	s.game.seats = seats
+	s.game.init()
+	s.game.mode = config.ModeGame
+	s.gameOverTicks = 0
+}
+
+func (s *Server) step() {
+	g := s.game
+	switch g.mode {
+	case config.ModeTitle:
+		if s.waiting() == 0 {
+			s.start()
+		}
+	case config.ModeGame, config.ModeLevelUp:
+		if err := g.Update(); err != nil {
+			log.Println("update:", err)
+		}
+	case config.ModeGameOver:
+		// 一段时间后重新开始，有玩家离开时等待新的玩家加入
+		s.gameOverTicks++
+		if s.gameOverTicks < gameOverDelay {
+			return
+		}
+		if s.waiting() == 0 {
+			s.start()
+		} else {
+			g.mode = config.ModeTitle
+		}
+	}
+}
+
+// broadcast 向每个客户端发送以其收到的最新快照为基准增量压缩的快照
+func (s *Server) broadcast() {
+	s.tick++
+	snapshot := s.game.Snapshot(s.tick)
+	snapshot.Waiting = uint8(s.waiting())
+	s.history.Put(snapshot)
+	for i, c := range s.clients {
+		if c == nil {
+			continue
+		}
+		packet := &netcode.SnapshotPacket{LastInput: s.inputs[i].applied, Snapshot: snapshot}
+		s.conn.Send(packet.Encode(s.history.Get(c.ack)), c.addr)
+	}
+}
+
+// weaponIndex 武器类型在 weaponList 中的序号加一，没有武器时为 0
+func weaponIndex(w Weapon) uint8 {
+	if w == nil {
+		return 0
+	}
+	for i, item := range weaponList {
+		if item.GetType() == w.GetType() {
+			return uint8(i + 1)
+		}
+	}
+	return 0
+}
+
+// weaponByIndex 根据 weaponIndex 返回的序号创建武器，序号无效时返回 nil
+func weaponByIndex(i uint8) Weapon {
+	if i == 0 || int(i) > len(weaponList) {
+		return nil
+	}
+	return NewWeapon(weaponList[i-1].GetType())
+}
+
+// personEntity 玩家和怪物共用的快照字段
+func personEntity(p *Player, kind netcode.EntityKind) netcode.Entity {
+	e := netcode.Entity{
+		ID:        uint32(p.id),
+		Kind:      kind,
+		X:         float32(p.x),
+		Y:         float32(p.y),
+		Dir:       uint8(p.directIdx),
+		Health:    float32(p.health),
+		MaxHealth: float32(p.maxHealth),
+		Weapon:    weaponIndex(p.weapon),
+		Status:    uint8(p.TopStatus()),
+	}
+	if weapon, ok := p.weapon.(*MeleeWeapon); ok {
+		e.Angle = float32(weapon.angle)
+	}
+	return e
+}
+
+// Snapshot 当前游戏状态的快照
+func (g *Game) Snapshot(tick uint32) *netcode.Snapshot {
+	s := &netcode.Snapshot{
+		Tick:      tick,
+		Mode:      uint8(g.mode),
+		Elapsed:   uint32(g.clock.Since(g.startTime) / (time.Second / 60)),
+		TimeScale: float32(g.timeScale),
+		Cursor:    uint8(g.upgradeCursor),
+	}
+	if g.mode == config.ModeTitle {
+		return s
+	}
+	if g.levelUpPlayer != nil {
+		s.LevelUp = uint32(g.levelUpPlayer.id)
+		for _, choice := range g.upgradeChoices {
+			for i, upgrade := range upgradeList {
+				if upgrade == choice {
+					s.Choices = append(s.Choices, uint8(i))
+				}
+			}
+		}
+	}
+
+	for _, p := range g.players {
+		e := personEntity(p, netcode.EntityPlayer)
+		e.Shield = float32(p.Shield())
+		e.Score = int32(p.score)
+		e.Level = uint8(p.level)
+		e.Counter = uint16(p.xp)
+		e.Speed = float32(p.Speed())
+		e.Charges = uint8(p.dashCharges * 100)
+		for i, c := range characters {
+			if c == p.character {
+				e.Aux = uint8(i)
+			}
+		}
+		if p.downed {
+			e.Flags |= netcode.FlagDowned
+		}
+		if p.Invincible() {
+			e.Flags |= netcode.FlagInvincible
+		}
+		if p.Dashing() {
+			e.Flags |= netcode.FlagDashing
+		}
+		s.Entities = append(s.Entities, e)
+	}
+	for _, monster := range g.monsters {
+		s.Entities = append(s.Entities, personEntity(monster, netcode.EntityMonster))
+	}
+	for id, bullet := range g.suspends {
+		s.Entities = append(s.Entities, netcode.Entity{
+			ID:     uint32(id),
+			Kind:   netcode.EntityBullet,
+			X:      float32(bullet.pos[0]),
+			Y:      float32(bullet.pos[1]),
+			Dir:    uint8(bullet.directIndex),
+			Weapon: weaponIndex(bullet.rangeWeapon),
+		})
+	}
+	for id, weapon := range g.weapons {
+		s.Entities = append(s.Entities, netcode.Entity{
+			ID:     uint32(id),
+			Kind:   netcode.EntityWeapon,
+			X:      float32(g.weaponPosition[id][0]),
+			Y:      float32(g.weaponPosition[id][1]),
+			Weapon: weaponIndex(weapon),
+		})
+	}
+	for id, pickup := range g.pickups {
+		s.Entities = append(s.Entities, netcode.Entity{
+			ID:      uint32(id),
+			Kind:    netcode.EntityPickup,
+			X:       float32(pickup.pos[0]),
+			Y:       float32(pickup.pos[1]),
+			Counter: uint16(pickup.remaining),
+			Aux:     uint8(pickup.kind),
+		})
+	}
+	for id, hazard := range g.hazards {
+		s.Entities = append(s.Entities, netcode.Entity{
+			ID:      uint32(id),
+			Kind:    netcode.EntityHazard,
+			X:       float32(hazard.pos[0]),
+			Y:       float32(hazard.pos[1]),
+			Counter: uint16(hazard.remaining),
+		})
+	}
+	s.Sort()
+	return s
+}
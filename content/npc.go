@@ -2,7 +2,6 @@ package main
 
 import (
 	"avoid-the-enemies/content/config"
-	"math/rand"
 	"time"
 
 	"golang.org/x/image/math/f64"
@@ -10,7 +9,9 @@ import (
 
 type Player struct {
 	id                int
-	score             int // 玩家的得分
+	clock             *Clock     // 所在游戏的时钟
+	character         *Character // 玩家选择的角色，怪物为 nil
+	score             int        // 玩家的得分
 	count             int
 	x, y              float64   // 人物在屏幕上的位置
 	speed             float64   // 人物移动速度
@@ -20,10 +21,38 @@ type Player struct {
 	health            float64   // 人物的生命值
 	lastCollisionTime time.Time // 上次碰撞发生的时间
 	directIdx         int       // 人物的方向
-	isSkill           bool      // 是否释放技能
-	skillFrame        int       // 技能的帧数
-	skillTime         time.Time // 技能释放的时间
-	startTime         time.Time // 游戏开始的时间
+	invincibleUntil   time.Time // 无敌状态的结束时间
+	skills            []*SkillSlot
+	dashCharges       float64   // 剩余的冲刺次数，小数部分为恢复进度
+	dashFrame         int       // 冲刺剩余的帧数
+	dashTime          time.Time // 上次冲刺的时间
+	dashX, dashY      float64   // 冲刺的方向
+
+	statuses map[StatusKind]*Status // 生效中的状态效果
+
+	input          InputSource // 玩家的操作来源，怪物为 nil
+	team           Team        // 所属的阵营，怪物为 TeamNeutral
+	downed         bool        // 生命值归零倒地，等待队友救起
+	reviveProgress int         // 队友救起的进度（帧数）
+
+	maxHealth            float64   // 生命值上限
+	shield               float64   // 护盾值，优先于生命值抵挡伤害
+	shieldUntil          time.Time // 护盾的结束时间
+	speedBoostUntil      time.Time // 加速的结束时间
+	scoreMultiplierUntil time.Time // 积分倍率的结束时间
+	magnetUntil          time.Time // 磁铁的结束时间
+
+	anim Animator // 当前播放的动画
+
+	kills       int     // 消灭的怪物数量
+	damageTaken float64 // 累计损失的生命值，不包括护盾抵挡的伤害
+
+	xp    int       // 当前等级已获得的经验
+	level int       // 等级，从 0 开始
+	mods  Modifiers // 升级获得的属性加成
+
+	target        *Player // 仅对怪物生效，追逐的玩家
+	targetWeakest bool    // 仅对怪物生效，是否优先追逐生命值最低的玩家
 
 	hasSteadyWeaponPosition bool
 	steadyWeaponId          int
@@ -32,18 +61,27 @@ type Player struct {
 
 // Invincible 是否无敌
 func (p *Player) Invincible() bool {
-	return p.isSkill
+	return p.clock.Now().Before(p.invincibleUntil) || p.clock.Since(p.dashTime) < config.DashInvincible*time.Millisecond
+}
+
+// UpdateSkills 更新所有生效中的技能
+func (p *Player) UpdateSkills(g *Game) {
+	for _, slot := range p.skills {
+		slot.Update(g, p)
+	}
 }
 
-func (p *Player) Move(dx, dy float64) {
-	p.x += dx
-	p.y += dy
-	p.x = clamp(p.x, -config.FrameWidth/2, config.ScreenWidth-config.FrameWidth/2)
-	p.y = clamp(p.y, -config.FrameHeight/2, config.ScreenHeight-config.FrameHeight/2)
+// Move 移动人物并限制在屏幕范围内，返回是否被屏幕边缘阻挡
+func (p *Player) Move(dx, dy float64) bool {
+	x, y := p.x+dx, p.y+dy
+	p.x = clamp(x, -config.FrameWidth/2, config.ScreenWidth-config.FrameWidth/2)
+	p.y = clamp(y, -config.FrameHeight/2, config.ScreenHeight-config.FrameHeight/2)
 
 	if p.hasSteadyWeaponPosition == true && p.x == p.steadyWeaponPosition[0] && p.y == p.steadyWeaponPosition[1] {
 		p.hasSteadyWeaponPosition = false
 	}
+
+	return p.x != x || p.y != y
 }
 
 func clamp(v, min, max float64) float64 {
@@ -58,20 +96,26 @@ func clamp(v, min, max float64) float64 {
 
 func GenerateMonster(g *Game) {
 	// 随着时间的推移，怪物的数量会增加
-	if len(g.monsters) < g.uniqueId/10+3 {
+	if len(g.monsters) < g.monsterCap() {
 		g.uniqueId++
-		g.monsters[g.uniqueId] = &Player{
+		monster := &Player{
 			id:        g.uniqueId,
-			count:     g.player.count,
-			x:         rand.Float64() * (config.ScreenWidth - config.FrameWidth/2),
-			y:         rand.Float64() * (config.ScreenHeight - config.FrameHeight/2),
-			speed:     1.0 / 180,
+			clock:     g.clock,
+			count:     g.players[0].count,
+			x:         g.rng.Float64() * (config.ScreenWidth - config.FrameWidth/2),
+			y:         g.rng.Float64() * (config.ScreenHeight - config.FrameHeight/2),
+			speed:     monsterBaseSpeed * g.Balance().MonsterSpeed,
+			health:    20,
+			maxHealth: 20,
 			weaponX:   config.FrameWidth / 2,
 			weaponY:   config.FrameHeight / 2,
 			directIdx: 0,
+
+			targetWeakest: g.rng.Float64() < monsterTargetWeakestChance,
 		}
+		g.monsters[g.uniqueId] = monster
 		g.monsterTimer[g.uniqueId] = 0
 		// 以玩家为目标
-		g.monsterTarget[g.uniqueId] = f64.Vec2{g.player.x, g.player.y}
+		g.monsterTarget[g.uniqueId] = g.chaseTarget(monster)
 	}
 }
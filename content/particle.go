@@ -0,0 +1,283 @@
+package main
+
+import (
+	"avoid-the-enemies/content/config"
+	"image"
+	"image/color"
+	"math"
+
+	"github.com/hajimehoshi/ebiten/v2"
+)
+
+// maxParticles 同时存在的粒子数量上限，粒子池、顶点和索引都按上限预先分配，池满时新的粒子被丢弃
+const maxParticles = 2048
+
+// Emitter 一种粒子效果的参数，每次发射 Count 个粒子
+type Emitter struct {
+	Count    int          // 每次发射的粒子数量
+	Life     [2]int       // 粒子寿命的范围（帧）
+	Speed    [2]float64   // 初速度的范围（像素/帧）
+	Spread   float64      // 发射方向两侧扩散的角度（弧度），π 为向四周发射
+	Gravity  float64      // 每帧增加的向下速度
+	Drag     float64      // 每帧速度衰减的比例
+	Size     [2]float64   // 粒子出生和消失时的边长
+	Colors   []color.RGBA // 颜色曲线，关键帧按寿命均匀分布
+	Alpha    []float64    // 透明度曲线，关键帧按寿命均匀分布
+	Additive bool         // 叠加混合，用于闪光和火花
+}
+
+var (
+	// 怪物死亡时向四周溅开的碎块
+	deathBurst = Emitter{
+		Count: 24, Life: [2]int{25, 45}, Speed: [2]float64{0.8, 2.5}, Spread: math.Pi,
+		Gravity: 0.08, Drag: 0.04, Size: [2]float64{3, 1},
+		Colors: []color.RGBA{{0xE0, 0x40, 0xE0, 0xFF}, {0x80, 0x10, 0x60, 0xFF}},
+		Alpha:  []float64{1, 1, 0},
+	}
+	// 子弹击中时的火花
+	impactSparks = Emitter{
+		Count: 10, Life: [2]int{8, 16}, Speed: [2]float64{1, 3}, Spread: math.Pi,
+		Drag: 0.12, Size: [2]float64{2, 1},
+		Colors:   []color.RGBA{{0xFF, 0xFF, 0xC0, 0xFF}, {0xFF, 0x80, 0x20, 0xFF}},
+		Alpha:    []float64{1, 0},
+		Additive: true,
+	}
+	// 开火时枪口的闪光
+	muzzleFlash = Emitter{
+		Count: 6, Life: [2]int{3, 6}, Speed: [2]float64{1.5, 3}, Spread: 0.35,
+		Drag: 0.2, Size: [2]float64{3, 1},
+		Colors:   []color.RGBA{{0xFF, 0xFF, 0xFF, 0xFF}, {0xFF, 0xC0, 0x40, 0xFF}},
+		Alpha:    []float64{1, 0},
+		Additive: true,
+	}
+	// 玩家受伤时溅出的血
+	bloodSplat = Emitter{
+		Count: 14, Life: [2]int{20, 35}, Speed: [2]float64{0.5, 2}, Spread: math.Pi,
+		Gravity: 0.12, Drag: 0.03, Size: [2]float64{2, 2},
+		Colors: []color.RGBA{{0xE0, 0x10, 0x10, 0xFF}, {0x60, 0x00, 0x00, 0xFF}},
+		Alpha:  []float64{1, 1, 0},
+	}
+	// 护盾抵挡伤害时的火花
+	shieldSparks = Emitter{
+		Count: 12, Life: [2]int{10, 20}, Speed: [2]float64{1, 2.5}, Spread: math.Pi,
+		Drag: 0.1, Size: [2]float64{2, 1},
+		Colors:   []color.RGBA{{0xC0, 0xF0, 0xFF, 0xFF}, {0x40, 0x80, 0xFF, 0xFF}},
+		Alpha:    []float64{1, 0},
+		Additive: true,
+	}
+	// 道具周围缓缓上升的闪光，获得道具时一次发射更多
+	pickupSparkle = Emitter{
+		Count: 1, Life: [2]int{20, 40}, Speed: [2]float64{0.2, 0.5}, Spread: 0.6,
+		Size:     [2]float64{2, 0},
+		Colors:   []color.RGBA{{0xFF, 0xFF, 0xFF, 0xFF}},
+		Alpha:    []float64{0, 1, 0},
+		Additive: true,
+	}
+	pickupBurst = Emitter{
+		Count: 16, Life: [2]int{15, 30}, Speed: [2]float64{0.6, 1.6}, Spread: math.Pi,
+		Drag: 0.06, Size: [2]float64{2, 0},
+		Colors:   []color.RGBA{{0xFF, 0xFF, 0xFF, 0xFF}},
+		Alpha:    []float64{1, 0},
+		Additive: true,
+	}
+	// 近战武器轨迹上留下的火星
+	trailSparks = Emitter{
+		Count: 1, Life: [2]int{10, 18}, Speed: [2]float64{0, 0.3}, Spread: math.Pi,
+		Size:     [2]float64{2, 0},
+		Colors:   []color.RGBA{{0xFF, 0xFF, 0xFF, 0xFF}, {0x80, 0xC0, 0xFF, 0xFF}},
+		Alpha:    []float64{0.8, 0},
+		Additive: true,
+	}
+)
+
+type particle struct {
+	emitter *Emitter
+	x, y    float64
+	vx, vy  float64
+	age     int
+	life    int
+	tint    color.RGBA
+}
+
+// Particles 粒子池，只用于画面表现，不属于游戏的模拟状态
+type Particles struct {
+	items    [maxParticles]particle
+	n        int
+	rng      Rand // 粒子使用自己的随机数，不影响游戏的随机数
+	vertices [maxParticles * 4]ebiten.Vertex
+	indices  [maxParticles * 6]uint16
+	pixel    *ebiten.Image
+}
+
+func NewParticles() *Particles {
+	ps := &Particles{}
+	ps.rng.Seed(1)
+	// 每个粒子是一个矩形，索引是固定的
+	for i := 0; i < maxParticles; i++ {
+		v := uint16(i * 4)
+		copy(ps.indices[i*6:], []uint16{v, v + 1, v + 2, v + 1, v + 3, v + 2})
+	}
+	// 从白色图片中间取一个像素作为纹理，避免采样到边缘
+	img := ebiten.NewImage(3, 3)
+	img.Fill(color.White)
+	ps.pixel = img.SubImage(image.Rect(1, 1, 2, 2)).(*ebiten.Image)
+	return ps
+}
+
+// Emit 在 (x, y) 发射一次粒子，angle 为发射的方向
+func (ps *Particles) Emit(e *Emitter, x, y, angle float64) {
+	ps.EmitTint(e, x, y, angle, color.RGBA{0xFF, 0xFF, 0xFF, 0xFF})
+}
+
+// EmitTint 发射一次粒子，粒子的颜色乘以 tint
+func (ps *Particles) EmitTint(e *Emitter, x, y, angle float64, tint color.RGBA) {
+	for i := 0; i < e.Count && ps.n < maxParticles; i++ {
+		a := angle + (ps.rng.Float64()*2-1)*e.Spread
+		speed := e.Speed[0] + ps.rng.Float64()*(e.Speed[1]-e.Speed[0])
+		ps.items[ps.n] = particle{
+			emitter: e,
+			x:       x,
+			y:       y,
+			vx:      math.Cos(a) * speed,
+			vy:      math.Sin(a) * speed,
+			life:    e.Life[0] + ps.rng.Intn(e.Life[1]-e.Life[0]+1),
+			tint:    tint,
+		}
+		ps.n++
+	}
+}
+
+// Update 推进所有粒子一帧，寿命结束的粒子与最后一个粒子交换后移除
+func (ps *Particles) Update() {
+	for i := 0; i < ps.n; {
+		p := &ps.items[i]
+		p.age++
+		if p.age >= p.life {
+			ps.n--
+			ps.items[i] = ps.items[ps.n]
+			continue
+		}
+		p.vy += p.emitter.Gravity
+		p.vx *= 1 - p.emitter.Drag
+		p.vy *= 1 - p.emitter.Drag
+		p.x += p.vx
+		p.y += p.vy
+		i++
+	}
+}
+
+// Clear 移除所有粒子
+func (ps *Particles) Clear() {
+	ps.n = 0
+}
+
+// Draw 普通粒子和叠加混合的粒子各用一次 DrawTriangles 绘制
+func (ps *Particles) Draw(screen *ebiten.Image) {
+	ps.draw(screen, false)
+	ps.draw(screen, true)
+}
+
+func (ps *Particles) draw(screen *ebiten.Image, additive bool) {
+	n := 0
+	for i := 0; i < ps.n; i++ {
+		p := &ps.items[i]
+		e := p.emitter
+		if e.Additive != additive {
+			continue
+		}
+		t := float64(p.age) / float64(p.life)
+		half := float32(e.Size[0]+(e.Size[1]-e.Size[0])*t) / 2
+		c := sampleColor(e.Colors, t)
+		alpha := float32(sampleCurve(e.Alpha, t)) * float32(c.A) / 0xFF * float32(p.tint.A) / 0xFF
+		// 顶点颜色使用预乘透明度
+		r := float32(c.R) / 0xFF * float32(p.tint.R) / 0xFF * alpha
+		g := float32(c.G) / 0xFF * float32(p.tint.G) / 0xFF * alpha
+		b := float32(c.B) / 0xFF * float32(p.tint.B) / 0xFF * alpha
+		x, y := float32(p.x), float32(p.y)
+		for j, corner := range [4][2]float32{{-1, -1}, {1, -1}, {-1, 1}, {1, 1}} {
+			ps.vertices[n*4+j] = ebiten.Vertex{
+				DstX: x + corner[0]*half, DstY: y + corner[1]*half,
+				SrcX: 1.5, SrcY: 1.5,
+				ColorR: r, ColorG: g, ColorB: b, ColorA: alpha,
+			}
+		}
+		n++
+	}
+	if n == 0 {
+		return
+	}
+	op := &ebiten.DrawTrianglesOptions{ColorScaleMode: ebiten.ColorScaleModePremultipliedAlpha}
+	if additive {
+		op.Blend = ebiten.BlendLighter
+	}
+	screen.DrawTriangles(ps.vertices[:n*4], ps.indices[:n*6], ps.pixel, op)
+}
+
+// sampleCurve 按 t 在均匀分布的关键帧之间线性插值，t 的范围为 [0, 1]
+func sampleCurve(keys []float64, t float64) float64 {
+	if len(keys) == 0 {
+		return 1
+	}
+	if len(keys) == 1 {
+		return keys[0]
+	}
+	pos := t * float64(len(keys)-1)
+	i := min(int(pos), len(keys)-2)
+	return keys[i] + (keys[i+1]-keys[i])*(pos-float64(i))
+}
+
+// sampleColor 按 t 在均匀分布的颜色关键帧之间线性插值
+func sampleColor(keys []color.RGBA, t float64) color.RGBA {
+	if len(keys) == 0 {
+		return color.RGBA{0xFF, 0xFF, 0xFF, 0xFF}
+	}
+	if len(keys) == 1 {
+		return keys[0]
+	}
+	pos := t * float64(len(keys)-1)
+	i := min(int(pos), len(keys)-2)
+	f := pos - float64(i)
+	lerp := func(a, b uint8) uint8 {
+		return uint8(float64(a) + (float64(b)-float64(a))*f)
+	}
+	a, b := keys[i], keys[i+1]
+	return color.RGBA{lerp(a.R, b.R), lerp(a.G, b.G), lerp(a.B, b.B), lerp(a.A, b.A)}
+}
+
+// emit 在游戏中发射粒子，无界面运行以及回滚重新模拟时不发射
+func (g *Game) emit(e *Emitter, x, y, angle float64) {
+	if g.particles == nil || g.resimulating {
+		return
+	}
+	g.particles.Emit(e, x, y, angle)
+}
+
+// emitTint 在游戏中发射指定颜色的粒子
+func (g *Game) emitTint(e *Emitter, x, y, angle float64, tint color.RGBA) {
+	if g.particles == nil || g.resimulating {
+		return
+	}
+	g.particles.EmitTint(e, x, y, angle, tint)
+}
+
+// updateParticles 推进粒子，并为地图上的道具和近战武器的轨迹发射持续的粒子
+func (g *Game) updateParticles() {
+	if g.particles == nil || g.resimulating {
+		return
+	}
+	ps := g.particles
+	ps.Update()
+	for _, pickup := range g.pickups {
+		if ps.rng.Intn(12) == 0 {
+			x := pickup.pos[0] + config.FrameWidth/2 + (ps.rng.Float64()*2-1)*6
+			y := pickup.pos[1] + config.FrameHeight/2 + (ps.rng.Float64()*2-1)*6
+			ps.EmitTint(&pickupSparkle, x, y, -math.Pi/2, pickupDefs[pickup.kind].color)
+		}
+	}
+	for _, p := range g.players {
+		if weapon, ok := p.weapon.(*MeleeWeapon); ok && !p.downed && len(weapon.Trail) > 0 {
+			tip := weapon.Trail[len(weapon.Trail)-1]
+			ps.Emit(&trailSparks, tip[0], tip[1], 0)
+		}
+	}
+}
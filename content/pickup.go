@@ -0,0 +1,257 @@
+package main
+
+import (
+	"avoid-the-enemies/content/config"
+	"image/color"
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/text/v2"
+	"github.com/hajimehoshi/ebiten/v2/vector"
+	"golang.org/x/image/math/f64"
+
+	"avoid-the-enemies/content/utils"
+)
+
+type PickupKind int
+
+const (
+	PickupHealth     PickupKind = iota // 回复生命值
+	PickupShield                       // 临时护盾，优先抵挡伤害
+	PickupSpeed                        // 临时加速
+	PickupMultiplier                   // 临时积分倍率
+	PickupMagnet                       // 临时吸引附近的道具
+	PickupXP                           // 经验宝石，只由怪物死亡掉落
+)
+
+type pickupDef struct {
+	label    string        // 道具图标上的字母
+	color    color.RGBA    // 道具图标的颜色
+	value    float64       // 道具的数值：回复量、护盾量、速度倍率或者积分倍率
+	duration time.Duration // 道具效果的持续时间，立即生效的道具为 0
+}
+
+var pickupDefs = map[PickupKind]pickupDef{
+	PickupHealth:     {label: "H", color: color.RGBA{0xE0, 0x30, 0x30, 0xFF}, value: 30},
+	PickupShield:     {label: "S", color: color.RGBA{0x30, 0xC0, 0xFF, 0xFF}, value: 50, duration: time.Second * 10},
+	PickupSpeed:      {label: "V", color: color.RGBA{0xFF, 0xE0, 0x30, 0xFF}, value: 1.5, duration: time.Second * 6},
+	PickupMultiplier: {label: "X", color: color.RGBA{0xC0, 0x60, 0xFF, 0xFF}, value: 2, duration: time.Second * 10},
+	PickupMagnet:     {label: "M", color: color.RGBA{0x60, 0xFF, 0x90, 0xFF}, duration: time.Second * 8},
+	PickupXP:         {color: color.RGBA{0x40, 0xE0, 0xFF, 0xFF}, value: 1},
+}
+
+// pickupEntry 刷新表中的一项，weight 越大越容易刷新
+type pickupEntry struct {
+	kind   PickupKind
+	weight int
+}
+
+var (
+	// 定时刷新的道具表
+	timedPickupTable = []pickupEntry{
+		{PickupHealth, 3},
+		{PickupShield, 2},
+		{PickupSpeed, 2},
+		{PickupMultiplier, 1},
+		{PickupMagnet, 1},
+	}
+	// 怪物死亡掉落的道具表
+	dropPickupTable = []pickupEntry{
+		{PickupHealth, 2},
+		{PickupShield, 1},
+		{PickupSpeed, 1},
+		{PickupMultiplier, 2},
+		{PickupMagnet, 2},
+	}
+)
+
+const (
+	pickupDropChance = 0.15 // 怪物死亡时掉落道具的概率
+	pickupLifetime   = 600  // 道具在地图上停留的帧数
+	magnetRadius     = 120  // 磁铁吸引道具的范围
+	magnetSpeed      = 3.0  // 磁铁每帧吸引道具移动的距离
+)
+
+// Pickup 地图上的道具
+type Pickup struct {
+	kind      PickupKind
+	pos       f64.Vec2 // 道具左上角的位置
+	remaining int      // 剩余的帧数
+}
+
+// rollPickup 按权重从道具表中随机选择一种道具
+func rollPickup(rng *Rand, table []pickupEntry) PickupKind {
+	total := 0
+	for _, entry := range table {
+		total += entry.weight
+	}
+	n := rng.Intn(total)
+	for _, entry := range table {
+		if n < entry.weight {
+			return entry.kind
+		}
+		n -= entry.weight
+	}
+	return table[0].kind
+}
+
+func spawnPickup(g *Game, kind PickupKind, pos f64.Vec2) {
+	g.uniqueId++
+	g.pickups[g.uniqueId] = &Pickup{
+		kind:      kind,
+		pos:       pos,
+		remaining: pickupLifetime,
+	}
+}
+
+// GeneratePickup 道具每隔一段时间在地图上随机位置刷新
+func GeneratePickup(g *Game) {
+	if g.clock.Since(g.pickupTimer) > time.Second*8 {
+		g.pickupTimer = g.clock.Now()
+		if countPickups(g, PickupXP) < 3 {
+			spawnPickup(g, rollPickup(&g.rng, timedPickupTable), RandomSpawnPosition(&g.rng))
+		}
+	}
+}
+
+// countPickups 地图上除 except 之外的道具数量
+func countPickups(g *Game, except PickupKind) int {
+	count := 0
+	for _, pickup := range g.pickups {
+		if pickup.kind != except {
+			count++
+		}
+	}
+	return count
+}
+
+// DropPickup 怪物死亡时在死亡位置掉落经验宝石，并按概率掉落道具
+func DropPickup(g *Game, monster *Player) {
+	spawnPickup(g, PickupXP, f64.Vec2{monster.x, monster.y})
+	if g.rng.Float64() < pickupDropChance {
+		spawnPickup(g, rollPickup(&g.rng, dropPickupTable), f64.Vec2{monster.x, monster.y})
+	}
+}
+
+// PickupMove 推进道具的生命周期，磁铁生效时将附近的道具吸向玩家，玩家触碰道具时获得道具效果
+func PickupMove(g *Game) {
+	players := g.livingPlayers()
+	for _, id := range sortedKeys(g.pickups) {
+		pickup := g.pickups[id]
+		pickup.remaining--
+		if pickup.remaining <= 0 {
+			delete(g.pickups, id)
+			continue
+		}
+		// 多个玩家的磁铁同时生效时，道具吸向第一个在范围内的玩家
+		for _, p := range players {
+			if !g.clock.Now().Before(p.magnetUntil) {
+				continue
+			}
+			distance := utils.GetDistance(pickup.pos[0], pickup.pos[1], p.x, p.y)
+			if distance > 0 && distance < magnetRadius {
+				dx, dy := utils.Normal(p.x-pickup.pos[0], p.y-pickup.pos[1])
+				step := math.Min(magnetSpeed, distance)
+				pickup.pos[0] += dx * step
+				pickup.pos[1] += dy * step
+				break
+			}
+		}
+		for _, p := range players {
+			if IsTouch(p.x, p.y, pickup.pos[0], pickup.pos[1]) {
+				p.ApplyPickup(pickup.kind)
+				g.emitTint(&pickupBurst, pickup.pos[0]+config.FrameWidth/2, pickup.pos[1]+config.FrameHeight/2, 0, pickupDefs[pickup.kind].color)
+				delete(g.pickups, id)
+				break
+			}
+		}
+	}
+}
+
+// ApplyPickup 玩家获得道具效果
+func (p *Player) ApplyPickup(kind PickupKind) {
+	def := pickupDefs[kind]
+	until := p.clock.Now().Add(def.duration)
+	switch kind {
+	case PickupHealth:
+		p.health = math.Min(p.health+def.value, p.maxHealth)
+	case PickupShield:
+		p.shield = def.value
+		p.shieldUntil = until
+	case PickupSpeed:
+		p.speedBoostUntil = until
+	case PickupMultiplier:
+		p.scoreMultiplierUntil = until
+	case PickupMagnet:
+		p.magnetUntil = until
+	case PickupXP:
+		p.xp += int(def.value)
+	}
+}
+
+// Shield 当前生效的护盾值
+func (p *Player) Shield() float64 {
+	if p.clock.Now().After(p.shieldUntil) {
+		return 0
+	}
+	return p.shield
+}
+
+// AddScore 增加积分，积分倍率道具生效时按倍率增加
+func (p *Player) AddScore(score int) {
+	if p.clock.Now().Before(p.scoreMultiplierUntil) {
+		score *= int(pickupDefs[PickupMultiplier].value)
+	}
+	p.score += score
+}
+
+// DrawPickups 绘制地图上的道具，即将消失的道具会闪烁
+func DrawPickups(screen *ebiten.Image, g *Game) {
+	for _, pickup := range g.pickups {
+		if pickup.remaining < 120 && (pickup.remaining/8)%2 == 0 {
+			continue
+		}
+		def := pickupDefs[pickup.kind]
+		cx := float32(pickup.pos[0] + config.FrameWidth/2)
+		cy := float32(pickup.pos[1] + config.FrameHeight/2 + 2*math.Sin(float64(pickup.remaining)/10))
+		// 经验宝石绘制为小圆点
+		if pickup.kind == PickupXP {
+			vector.DrawFilledCircle(screen, cx, cy, 2.5, def.color, true)
+			continue
+		}
+		vector.DrawFilledCircle(screen, cx, cy, 6, def.color, true)
+		vector.StrokeCircle(screen, cx, cy, 6, 1, color.White, true)
+
+		op := &text.DrawOptions{}
+		op.GeoM.Translate(float64(cx), float64(cy)-3)
+		op.ColorScale.ScaleWithColor(color.Black)
+		op.PrimaryAlign = text.AlignCenter
+		text.Draw(screen, def.label, arcadeFace(6), op)
+	}
+}
+
+// DrawBuffHUD 在积分下方绘制生效中的道具效果以及剩余秒数，y 为积分所在的高度
+func DrawBuffHUD(screen *ebiten.Image, p *Player, y float64) {
+	buffs := []struct {
+		kind  PickupKind
+		until time.Time
+	}{
+		{PickupShield, p.shieldUntil},
+		{PickupSpeed, p.speedBoostUntil},
+		{PickupMultiplier, p.scoreMultiplierUntil},
+		{PickupMagnet, p.magnetUntil},
+	}
+	x := 3.0
+	for _, buff := range buffs {
+		remaining := p.clock.Until(buff.until)
+		if remaining <= 0 || (buff.kind == PickupShield && p.Shield() <= 0) {
+			continue
+		}
+		def := pickupDefs[buff.kind]
+		label := def.label + strconv.Itoa(int(remaining.Seconds())+1)
+		hud.Place(screen, AnchorTopLeft, x, y+config.FontSize+3, &Label{Text: label, FontSize: config.FontSize, Color: def.color})
+		x += float64(len(label)+1) * config.FontSize
+	}
+}
@@ -0,0 +1,182 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+)
+
+// profileVersion 存档格式的当前版本，修改存档格式时递增并在 profileMigrations 中追加迁移函数
+const profileVersion = 3
+
+// profileMigrations 存档迁移函数，第 i 个函数将版本 i 的存档迁移到版本 i+1
+var profileMigrations = []func(raw map[string]any){
+	// 0 -> 1：没有版本号的存档补全解锁记录和属性等级
+	func(raw map[string]any) {
+		if _, ok := raw["unlocked"]; !ok {
+			raw["unlocked"] = map[string]any{}
+		}
+		if _, ok := raw["levels"]; !ok {
+			raw["levels"] = map[string]any{}
+		}
+	},
+	// 1 -> 2：补全设置，音量默认为最大
+	func(raw map[string]any) {
+		if _, ok := raw["settings"]; !ok {
+			raw["settings"] = map[string]any{"master_volume": 100, "music_volume": 100, "sfx_volume": 100}
+		}
+	},
+	// 2 -> 3：补全画面效果的开关，默认全部开启
+	func(raw map[string]any) {
+		settings, ok := raw["settings"].(map[string]any)
+		if !ok {
+			return
+		}
+		for _, key := range []string{"damage_flash", "vignette", "screen_shake", "hit_stop", "chroma_pulse"} {
+			if _, ok := settings[key]; !ok {
+				settings[key] = true
+			}
+		}
+	},
+}
+
+// Profile 跨局保存的玩家档案
+type Profile struct {
+	Version     int             `json:"version"`
+	Coins       int             `json:"coins"`        // 可以在商店中消费的金币
+	Unlocked    map[string]bool `json:"unlocked"`     // 已解锁的商品
+	Levels      map[string]int  `json:"levels"`       // 永久属性加成的等级
+	StartWeapon string          `json:"start_weapon"` // 开局携带的武器，为空时使用角色的武器
+	Character   string          `json:"character"`    // 上次选择的角色
+	BestTime    int             `json:"best_time"`    // 最长存活时间（秒）
+	BestScore   int             `json:"best_score"`   // 最高积分
+	Settings    Settings        `json:"settings"`     // 游戏设置
+
+	path string
+}
+
+func NewProfile(path string) *Profile {
+	return &Profile{
+		Version:  profileVersion,
+		Unlocked: make(map[string]bool),
+		Levels:   make(map[string]int),
+		Settings: DefaultSettings(),
+		path:     path,
+	}
+}
+
+// ProfilePath 存档文件的路径
+func ProfilePath() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "avoid-the-enemies", "profile.json"), nil
+}
+
+// LoadProfile 读取存档，存档不存在时返回新的存档，旧版本的存档会迁移到当前版本
+func LoadProfile(path string) (*Profile, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return NewProfile(path), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	raw := make(map[string]any)
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, err
+	}
+	if err := migrate(raw, profileMigrations, 0, profileVersion); err != nil {
+		return nil, fmt.Errorf("profile: %w", err)
+	}
+
+	if data, err = json.Marshal(raw); err != nil {
+		return nil, err
+	}
+	p := NewProfile(path)
+	if err := json.Unmarshal(data, p); err != nil {
+		return nil, err
+	}
+	if p.Unlocked == nil {
+		p.Unlocked = make(map[string]bool)
+	}
+	if p.Levels == nil {
+		p.Levels = make(map[string]int)
+	}
+	return p, nil
+}
+
+// Save 保存存档，路径为空的存档只保存在内存中
+func (p *Profile) Save() error {
+	if p.path == "" {
+		return nil
+	}
+	data, err := json.MarshalIndent(p, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(p.path, data)
+}
+
+// migrate 依次执行迁移函数，将存档迁移到 latest 版本。migrations[i] 将版本 from+i 的存档迁移到版本 from+i+1，
+// 没有版本号的存档视为版本 0，版本不在 from 到 latest 之间时失败
+func migrate(raw map[string]any, migrations []func(raw map[string]any), from, latest int) error {
+	version := 0
+	switch v := raw["version"].(type) {
+	case float64:
+		version = int(v)
+	case json.Number:
+		n, _ := v.Int64()
+		version = int(n)
+	}
+	if version < from || version > latest {
+		return fmt.Errorf("version %d is not supported, want %d to %d", version, from, latest)
+	}
+	for ; version < latest; version++ {
+		migrations[version-from](raw)
+	}
+	raw["version"] = latest
+	return nil
+}
+
+// writeFileAtomic 先写入临时文件再替换，避免写入过程中退出导致存档损坏
+func writeFileAtomic(path string, data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// InitProfile 读取玩家档案，读取失败时使用新的档案，保证游戏可以继续
+func InitProfile() *Profile {
+	path, err := ProfilePath()
+	if err != nil {
+		log.Println("profile path:", err)
+		return NewProfile(filepath.Join(".", "profile.json"))
+	}
+	p, err := LoadProfile(path)
+	if err != nil {
+		// 保留无法读取的存档，避免被新的存档覆盖
+		log.Println("load profile:", err)
+		if err := os.Rename(path, path+".corrupt"); err != nil {
+			log.Println("backup profile:", err)
+		}
+		return NewProfile(path)
+	}
+	return p
+}
+
+// CoinsEarned 一局游戏结束时获得的金币，由积分和存活时间决定
+func CoinsEarned(score, survivalSeconds int) int {
+	return score + survivalSeconds/5
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// writeProfile 在临时目录中写入存档，返回存档的路径
+func writeProfile(t *testing.T, data string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "profile.json")
+	if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestProfileMigrations(t *testing.T) {
+	if profileVersion != len(profileMigrations) {
+		t.Fatalf("profile version %d needs %d migrations, have %d", profileVersion, profileVersion, len(profileMigrations))
+	}
+
+	tests := []struct {
+		name string
+		data string
+		want func(p *Profile)
+	}{
+		{"v0", `{"coins": 42, "best_score": 7}`, func(p *Profile) {
+			p.Coins, p.BestScore = 42, 7
+		}},
+		{"v1", `{"version": 1, "coins": 5, "unlocked": {"skill:decoy": true}, "levels": {"health": 2}}`, func(p *Profile) {
+			p.Coins = 5
+			p.Unlocked["skill:decoy"] = true
+			p.Levels["health"] = 2
+		}},
+		{"v2", `{"version": 2, "character": "knight", "settings": {"master_volume": 30, "music_volume": 40, "sfx_volume": 50}}`, func(p *Profile) {
+			p.Character = "knight"
+			p.Settings.MasterVolume, p.Settings.MusicVolume, p.Settings.SFXVolume = 30, 40, 50
+		}},
+		{"v3", `{"version": 3, "settings": {"master_volume": 100, "music_volume": 100, "sfx_volume": 100, "hit_stop": false}}`, func(p *Profile) {
+			p.Settings.HitStop = false
+		}},
+	}
+	for _, test := range tests {
+		path := writeProfile(t, test.data)
+		p, err := LoadProfile(path)
+		if err != nil {
+			t.Errorf("%s: %v", test.name, err)
+			continue
+		}
+		want := NewProfile(path)
+		test.want(want)
+		if !reflect.DeepEqual(p, want) {
+			t.Errorf("%s: loaded %+v, want %+v", test.name, p, want)
+			continue
+		}
+
+		// 迁移后的存档保存为当前版本，再次读取得到相同的存档
+		if err := p.Save(); err != nil {
+			t.Fatal(err)
+		}
+		again, err := LoadProfile(path)
+		if err != nil {
+			t.Fatalf("%s: reload: %v", test.name, err)
+		}
+		if !reflect.DeepEqual(again, p) {
+			t.Errorf("%s: reloaded %+v, want %+v", test.name, again, p)
+		}
+	}
+}
+
+func TestProfileVersion(t *testing.T) {
+	for _, version := range []string{"-1", "4"} {
+		path := writeProfile(t, `{"version": `+version+`, "coins": 1}`)
+		if _, err := LoadProfile(path); err == nil {
+			t.Errorf("LoadProfile accepted version %s", version)
+		}
+	}
+
+	path := filepath.Join(t.TempDir(), "missing.json")
+	p, err := LoadProfile(path)
+	if err != nil || !reflect.DeepEqual(p, NewProfile(path)) {
+		t.Errorf("LoadProfile of a missing file = %+v, %v, want a new profile", p, err)
+	}
+}
@@ -0,0 +1,41 @@
+package main
+
+import "math/bits"
+
+// Rand 游戏模拟使用的随机数生成器（splitmix64），状态只有一个整数，可以随游戏状态一起复制，
+// 同样的种子和同样的操作总是得到同样的结果
+type Rand struct {
+	state uint64
+}
+
+// Seed 重新设置随机数种子
+func (r *Rand) Seed(seed int64) {
+	r.state = uint64(seed)
+}
+
+// Uint64 返回下一个随机数
+func (r *Rand) Uint64() uint64 {
+	r.state += 0x9E3779B97F4A7C15
+	z := r.state
+	z = (z ^ (z >> 30)) * 0xBF58476D1CE4E5B9
+	z = (z ^ (z >> 27)) * 0x94D049BB133111EB
+	return z ^ (z >> 31)
+}
+
+// Float64 返回 [0, 1) 之间的随机数
+func (r *Rand) Float64() float64 {
+	return float64(r.Uint64()>>11) / (1 << 53)
+}
+
+// Intn 返回 [0, n) 之间的随机整数，n 必须大于 0
+func (r *Rand) Intn(n int) int {
+	hi, _ := bits.Mul64(r.Uint64(), uint64(n))
+	return int(hi)
+}
+
+// Shuffle 随机打乱 n 个元素的顺序
+func (r *Rand) Shuffle(n int, swap func(i, j int)) {
+	for i := n - 1; i > 0; i-- {
+		swap(i, r.Intn(i+1))
+	}
+}
@@ -0,0 +1,459 @@
+package main
+
+import (
+	"avoid-the-enemies/content/config"
+	"avoid-the-enemies/content/netcode"
+	"errors"
+	"flag"
+	"fmt"
+	"image/color"
+	"log"
+	"math"
+	"net"
+	"strconv"
+	"time"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/inpututil"
+	"github.com/hajimehoshi/ebiten/v2/text/v2"
+)
+
+const (
+	rollbackBuffer     = 128 // 保存操作和状态的帧数，必须大于最大回滚帧数与输入延迟之和
+	maxRollback        = 8   // 本地最多领先对方的帧数，超过时暂停等待对方的操作
+	maxInputDelay      = 10  // 输入延迟的上限
+	maxInputsPerPacket = 64  // 每个数据包中最多发送的操作帧数
+	checksumInterval   = 60  // 每隔多少帧交换一次状态校验值
+	checksumResend     = 15  // 重复发送最新校验值的间隔帧数
+)
+
+// frameInput 回滚对战中玩家的操作来源，每帧模拟之前由 Rollback 设置
+type frameInput struct {
+	in Input
+}
+
+func (f *frameInput) Read() Input {
+	return f.in
+}
+
+func (f *frameInput) SkillLabel(i int) string {
+	return soloKeyboard.SkillLabel(i)
+}
+
+// savedState 某一帧开始时的模拟状态
+type savedState struct {
+	frame    uint32
+	state    *GameState
+	checksum uint32 // 只在每隔 checksumInterval 帧时计算
+}
+
+// Rollback 点对点的双人对战，双方各自模拟完整的游戏，只交换操作
+// 对方的操作没有到达时先按对方上一帧的移动预测，收到的操作与预测不同时回滚到该帧重新模拟
+type Rollback struct {
+	game      *Game
+	conn      *netcode.Conn
+	packets   <-chan netcode.Datagram
+	remote    *net.UDPAddr
+	local     int         // 本地玩家的序号，0 或 1
+	delay     int         // 输入延迟的帧数，本地操作延迟生效以减少回滚
+	seed      uint32      // 玩家 0 选择的随机数种子
+	character string      // 本地选择的角色
+	arena     *ArenaRules // 竞技场的规则，玩家 1 使用玩家 0 的规则
+	sources   [2]*frameInput
+	ticks     int // 界面更新的次数
+
+	started      bool
+	peerLeft     bool
+	frame        uint32                                // 下一个要模拟的帧，从 1 开始
+	localLast    uint32                                // 已经确定本地操作的最新一帧
+	remoteLast   uint32                                // 已经收到对方操作的最新一帧，之后的帧使用预测的操作
+	remoteAck    uint32                                // 对方已经收到的本地操作的最新一帧
+	inputs       [2][rollbackBuffer]netcode.InputFrame // 双方每一帧的操作，按帧序号取模存放
+	states       [rollbackBuffer]savedState
+	rollbackFrom uint32 // 需要回滚到的最早一帧，为 0 时不需要回滚
+	rollbacks    int    // 最近一次回滚重新模拟的帧数
+
+	lastChecksum    netcode.Checksum  // 最近一次发送的校验值
+	localChecksums  map[uint32]uint32 // 等待比较的本地校验值
+	remoteChecksums map[uint32]uint32 // 等待比较的对方校验值
+	desync          uint32            // 检测到模拟结果不一致的帧，为 0 时一致
+}
+
+func NewRollback(conn *netcode.Conn, remote *net.UDPAddr, local, delay int, character string, arena *ArenaRules) *Rollback {
+	r := &Rollback{
+		game:            &Game{profile: NewProfile("")},
+		conn:            conn,
+		packets:         conn.Receive(),
+		remote:          remote,
+		local:           local,
+		delay:           delay,
+		character:       character,
+		arena:           arena,
+		localChecksums:  make(map[uint32]uint32),
+		remoteChecksums: make(map[uint32]uint32),
+	}
+	if local == 0 {
+		r.seed = uint32(time.Now().UnixNano()) | 1
+	}
+	for i := range r.sources {
+		r.sources[i] = &frameInput{}
+	}
+	r.game.init()
+	return r
+}
+
+// RunVersus 与另一台电脑在竞技场中点对点对战，用法：avoid-the-enemies versus [-player 1] [-local :7001] [-delay 2] host:port
+func RunVersus(args []string) {
+	fs := flag.NewFlagSet("versus", flag.ExitOnError)
+	player := fs.Int("player", 1, "player number, 1 or 2; player 1 chooses the random seed")
+	local := fs.String("local", ":7001", "local UDP address")
+	delay := fs.Int("delay", 2, "input delay in frames")
+	arena := DefaultArenaRules()
+	fs.DurationVar(&arena.RoundTime, "round", arena.RoundTime, "round time, decided by player 1")
+	fs.IntVar(&arena.RoundsToWin, "rounds", arena.RoundsToWin, "rounds to win the match, decided by player 1")
+	fs.BoolVar(&arena.FriendlyFire, "friendly-fire", false, "bullets hit teammates, decided by player 1")
+	fs.BoolVar(&arena.Monsters, "monsters", false, "spawn neutral monsters, decided by player 1")
+	var cond netcode.LinkConditions
+	cond.RegisterFlags(fs)
+	fs.Parse(args)
+	if fs.NArg() != 1 || (*player != 1 && *player != 2) {
+		log.Fatal("usage: avoid-the-enemies versus [-player 1|2] [-local addr] [-delay frames] [flags] host:port")
+	}
+	if *delay < 0 || *delay > maxInputDelay {
+		log.Fatalf("input delay must be between 0 and %d", maxInputDelay)
+	}
+	if arena.RoundTime < time.Second || arena.RoundTime > math.MaxUint16*time.Second || arena.RoundsToWin < 1 || arena.RoundsToWin > math.MaxUint8 {
+		log.Fatal("invalid round time or rounds to win")
+	}
+
+	Init()
+	remote, err := net.ResolveUDPAddr("udp", fs.Arg(0))
+	if err != nil {
+		log.Fatal(err)
+	}
+	addr, err := net.ResolveUDPAddr("udp", *local)
+	if err != nil {
+		log.Fatal(err)
+	}
+	conn, err := net.ListenUDP("udp", addr)
+	if err != nil {
+		log.Fatal(err)
+	}
+	r := NewRollback(netcode.NewConn(conn, cond), remote, *player-1, *delay, InitProfile().Character, arena)
+	ebiten.SetWindowSize(config.ScreenWidth*3, config.ScreenHeight*3)
+	ebiten.SetWindowTitle("Avoid the Enemies - P" + strconv.Itoa(*player) + " VERSUS")
+	if err := ebiten.RunGame(r); err != nil && !errors.Is(err, ebiten.Termination) {
+		log.Fatal(err)
+	}
+}
+
+func (r *Rollback) Update() error {
+	r.receive()
+	r.ticks++
+
+	// 按 Esc 键离开对战
+	if inpututil.IsKeyJustPressed(ebiten.KeyEscape) {
+		r.conn.Send([]byte{netcode.PacketBye}, r.remote)
+		return ebiten.Termination
+	}
+
+	if !r.started {
+		if r.ticks%helloInterval == 1 {
+			r.sendHello()
+		}
+		return nil
+	}
+
+	if r.rollbackFrom != 0 {
+		r.resimulate()
+	}
+
+	// 本地领先对方太多时暂停，等待对方的操作
+	if r.frame-r.remoteLast <= maxRollback {
+		r.localLast++
+		r.inputs[r.local][r.localLast%rollbackBuffer] = encodeInputFrame(r.localLast, soloKeyboard.Read())
+		r.simulate(r.frame)
+		r.frame++
+	}
+
+	r.sendInputs()
+	r.exchangeChecksums()
+	return nil
+}
+
+// start 双方都收到握手信息后，以相同的种子、角色和竞技场规则开始游戏
+func (r *Rollback) start(remoteCharacter string) {
+	characters := [2]*Character{}
+	characters[r.local] = CharacterByID(r.character)
+	characters[1-r.local] = CharacterByID(remoteCharacter)
+	g := r.game
+	g.seed = int64(r.seed)
+	g.arena = r.arena
+	g.seats = nil
+	for i, c := range characters {
+		g.seats = append(g.seats, Seat{input: r.sources[i], character: c})
+	}
+	g.init()
+	g.mode = config.ModeGame
+
+	// 输入延迟之前的帧双方都没有操作
+	r.frame = 1
+	for f := uint32(1); f <= uint32(r.delay); f++ {
+		r.inputs[r.local][f%rollbackBuffer] = netcode.InputFrame{Seq: f}
+	}
+	r.localLast = uint32(r.delay)
+	r.started = true
+	log.Printf("versus started as player %d, seed %d, input delay %d", r.local+1, r.seed, r.delay)
+}
+
+// input 玩家 i 在第 f 帧的操作，对方的操作还没有到达时沿用对方最后一帧的移动，按键视为没有按下
+func (r *Rollback) input(i int, f uint32) netcode.InputFrame {
+	if i == r.local || f <= r.remoteLast {
+		return r.inputs[i][f%rollbackBuffer]
+	}
+	predicted := r.inputs[i][r.remoteLast%rollbackBuffer]
+	predicted.Seq = f
+	predicted.Buttons = 0
+	r.inputs[i][f%rollbackBuffer] = predicted
+	return predicted
+}
+
+// simulate 保存第 f 帧开始时的状态，然后按双方的操作模拟这一帧
+func (r *Rollback) simulate(f uint32) {
+	g := r.game
+	saved := savedState{frame: f, state: g.SaveState()}
+	if f%checksumInterval == 0 {
+		saved.checksum = g.Checksum()
+	}
+	r.states[f%rollbackBuffer] = saved
+
+	restart := false
+	for i, source := range r.sources {
+		source.in = decodeInputFrame(r.input(i, f))
+		restart = restart || source.in.Fire
+	}
+	if g.mode != config.ModeGameOver {
+		g.Update()
+		return
+	}
+	// 游戏结束后任意一方按开火键开始下一局，种子取自当前的随机数生成器，双方保持一致
+	if restart {
+		g.seed = int64(g.rng.Uint64()) | 1
+		g.init()
+		g.mode = config.ModeGame
+	}
+}
+
+// resimulate 恢复到预测错误的那一帧，按修正后的操作重新模拟到当前帧
+func (r *Rollback) resimulate() {
+	from := r.rollbackFrom
+	r.rollbackFrom = 0
+	saved := r.states[from%rollbackBuffer]
+	if saved.frame != from {
+		log.Printf("rollback: state of frame %d is gone", from)
+		return
+	}
+	r.game.LoadState(saved.state)
+	r.game.resimulating = true
+	for f := from; f < r.frame; f++ {
+		r.simulate(f)
+	}
+	r.game.resimulating = false
+	r.rollbacks = int(r.frame - from)
+}
+
+// receive 处理收到的所有数据包
+func (r *Rollback) receive() {
+	for {
+		select {
+		case d, ok := <-r.packets:
+			if !ok {
+				return
+			}
+			r.handle(d)
+		default:
+			return
+		}
+	}
+}
+
+func (r *Rollback) handle(d netcode.Datagram) {
+	reader, packetType := netcode.NewReader(d.Data)
+	switch packetType {
+	case netcode.PacketPeerHello:
+		hello, err := netcode.DecodePeerHello(reader)
+		if err != nil {
+			log.Println("versus:", err)
+			return
+		}
+		if int(hello.Player) == r.local {
+			log.Printf("versus: both peers are player %d", r.local+1)
+			return
+		}
+		if r.started {
+			// 对方还没有收到握手信息时继续回复
+			if r.remoteLast == 0 {
+				r.sendHello()
+			}
+			return
+		}
+		if r.local == 1 {
+			r.seed = hello.Seed
+			r.arena = &ArenaRules{
+				RoundTime:    time.Duration(hello.RoundTime) * time.Second,
+				RoundsToWin:  int(hello.Rounds),
+				FriendlyFire: hello.Rules&netcode.RuleFriendlyFire != 0,
+				Monsters:     hello.Rules&netcode.RuleMonsters != 0,
+			}
+		}
+		r.sendHello()
+		r.start(hello.Character)
+	case netcode.PacketInput:
+		packet, err := netcode.DecodeInput(reader)
+		if err != nil || !r.started {
+			return
+		}
+		r.remoteAck = max(r.remoteAck, min(packet.Ack, r.localLast))
+		remote := 1 - r.local
+		for _, f := range packet.Frames {
+			if f.Seq != r.remoteLast+1 {
+				continue
+			}
+			// 已经按预测模拟过的帧，预测错误时需要回滚
+			if f.Seq < r.frame {
+				predicted := r.inputs[remote][f.Seq%rollbackBuffer]
+				if predicted != f && (r.rollbackFrom == 0 || f.Seq < r.rollbackFrom) {
+					r.rollbackFrom = f.Seq
+				}
+			}
+			r.inputs[remote][f.Seq%rollbackBuffer] = f
+			r.remoteLast = f.Seq
+		}
+	case netcode.PacketChecksum:
+		c, err := netcode.DecodeChecksum(reader)
+		if err != nil {
+			return
+		}
+		r.remoteChecksums[c.Frame] = c.Sum
+		r.compareChecksum(c.Frame)
+	case netcode.PacketBye:
+		r.peerLeft = true
+	}
+}
+
+func (r *Rollback) sendHello() {
+	hello := &netcode.PeerHello{
+		Version:   netcode.ProtocolVersion,
+		Player:    uint8(r.local),
+		Seed:      r.seed,
+		Character: r.character,
+		RoundTime: uint16(r.arena.RoundTime / time.Second),
+		Rounds:    uint8(r.arena.RoundsToWin),
+	}
+	if r.arena.FriendlyFire {
+		hello.Rules |= netcode.RuleFriendlyFire
+	}
+	if r.arena.Monsters {
+		hello.Rules |= netcode.RuleMonsters
+	}
+	r.conn.Send(hello.Encode(), r.remote)
+}
+
+// sendInputs 发送对方还没有确认收到的本地操作
+func (r *Rollback) sendInputs() {
+	from := max(r.remoteAck+1, r.localLast-min(r.localLast, maxInputsPerPacket-1))
+	packet := &netcode.InputPacket{Ack: r.remoteLast}
+	for f := from; f <= r.localLast; f++ {
+		packet.Frames = append(packet.Frames, r.inputs[r.local][f%rollbackBuffer])
+	}
+	r.conn.Send(packet.Encode(), r.remote)
+}
+
+// exchangeChecksums 双方的操作都已经确定的帧不会再回滚，发送这些帧的校验值与对方比较
+func (r *Rollback) exchangeChecksums() {
+	next := r.lastChecksum.Frame + checksumInterval
+	if next < r.frame && next <= r.remoteLast+1 {
+		saved := r.states[next%rollbackBuffer]
+		r.lastChecksum.Frame = next
+		if saved.frame == next {
+			r.lastChecksum.Sum = saved.checksum
+			r.localChecksums[next] = saved.checksum
+			r.compareChecksum(next)
+			r.conn.Send(r.lastChecksum.Encode(), r.remote)
+		}
+	}
+	// 校验值可能丢失，定期重复发送最近一次的校验值
+	if r.lastChecksum.Frame != 0 && r.ticks%checksumResend == 0 {
+		r.conn.Send(r.lastChecksum.Encode(), r.remote)
+	}
+}
+
+// compareChecksum 双方都有第 f 帧的校验值时比较，不一致时记录最早的一帧
+func (r *Rollback) compareChecksum(f uint32) {
+	local, ok := r.localChecksums[f]
+	if !ok {
+		return
+	}
+	remote, ok := r.remoteChecksums[f]
+	if !ok {
+		return
+	}
+	if local != remote && r.desync == 0 {
+		r.desync = f
+		log.Printf("versus: desync at frame %d, local %08x, remote %08x", f, local, remote)
+	}
+	delete(r.localChecksums, f)
+	delete(r.remoteChecksums, f)
+	// 丢弃过早的校验值，对应的数据包已经丢失
+	for frame := range r.localChecksums {
+		if frame+rollbackBuffer*checksumInterval < f {
+			delete(r.localChecksums, frame)
+		}
+	}
+	for frame := range r.remoteChecksums {
+		if frame+rollbackBuffer*checksumInterval < f {
+			delete(r.remoteChecksums, frame)
+		}
+	}
+}
+
+func (r *Rollback) Draw(screen *ebiten.Image) {
+	face := arcadeFace(config.FontSize)
+	if !r.started {
+		op := &text.DrawOptions{}
+		op.GeoM.Translate(config.ScreenWidth/2, config.ScreenHeight/2-config.TitleFontSize)
+		op.ColorScale.ScaleWithColor(color.White)
+		op.PrimaryAlign = text.AlignCenter
+		text.Draw(screen, T("versus.waiting", r.remote.String()), arcadeFace(config.TitleFontSize), op)
+		return
+	}
+	r.game.Draw(screen)
+
+	// 右上角绘制输入延迟和最近一次回滚的帧数
+	op := &text.DrawOptions{}
+	op.GeoM.Translate(config.ScreenWidth-3, 3)
+	op.ColorScale.ScaleWithColor(color.Gray{0x80})
+	op.PrimaryAlign = text.AlignEnd
+	text.Draw(screen, fmt.Sprintf("DELAY %d RB %d", r.delay, r.rollbacks), face, op)
+
+	var warning string
+	switch {
+	case r.desync != 0:
+		warning = T("versus.desync", int(r.desync))
+	case r.peerLeft:
+		warning = T("versus.peer_left")
+	case r.frame-r.remoteLast > maxRollback:
+		warning = T("versus.waiting_peer")
+	}
+	if warning != "" {
+		op := &text.DrawOptions{}
+		op.GeoM.Translate(config.ScreenWidth/2, config.ScreenHeight/2)
+		op.ColorScale.ScaleWithColor(color.RGBA{0xFF, 0x40, 0x40, 0xFF})
+		op.PrimaryAlign = text.AlignCenter
+		text.Draw(screen, warning, face, op)
+	}
+}
+
+func (r *Rollback) Layout(outsideWidth, outsideHeight int) (int, int) {
+	return config.ScreenWidth, config.ScreenHeight
+}
@@ -0,0 +1,596 @@
+package main
+
+import (
+	"avoid-the-enemies/content/config"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"math"
+	"os"
+	"path/filepath"
+	"time"
+
+	"golang.org/x/image/math/f64"
+)
+
+// runSaveVersion 进行中的游戏存档的当前版本，修改格式时递增并在 runSaveMigrations 中追加迁移函数
+const runSaveVersion = 1
+
+// runSaveMigrations 存档迁移函数，第 i-1 个函数将版本 i 的存档迁移到版本 i+1
+var runSaveMigrations []func(raw map[string]any)
+
+// RunSave 进行中的一局游戏的完整状态，用于快速存档以及崩溃后继续游戏
+// 图片、音效等资源不写入存档，读取时按武器类型、技能名称和角色重新创建
+type RunSave struct {
+	Version   int       `json:"version"`
+	SavedAt   time.Time `json:"saved_at"` // 保存时的现实时间
+	Clock     int64     `json:"clock"`    // 游戏时钟，时间均以游戏时钟起点之后的纳秒数保存
+	StartTime int64     `json:"start_time"`
+	Rng       uint64    `json:"rng"`
+	UniqueID  int       `json:"unique_id"`
+	TimeScale float64   `json:"time_scale"`
+
+	WeaponTimer int64 `json:"weapon_timer"`
+	HazardTimer int64 `json:"hazard_timer"`
+	PickupTimer int64 `json:"pickup_timer"`
+
+	Players                  []savedPlayer        `json:"players"`
+	Monsters                 map[int]savedPlayer  `json:"monsters"`
+	MonsterTarget            map[int]f64.Vec2     `json:"monster_target"`
+	MonsterTimer             map[int]int          `json:"monster_timer"`
+	WeaponPosition           map[int]f64.Vec2     `json:"weapon_position"`
+	WeaponPositionBeenPicked map[int]bool         `json:"weapon_position_been_picked"`
+	Weapons                  map[int]savedWeapon  `json:"weapons"`
+	Suspends                 map[int]savedSuspend `json:"suspends"`
+	Hazards                  map[int]savedHazard  `json:"hazards"`
+	Pickups                  map[int]savedPickup  `json:"pickups"`
+}
+
+type savedPlayer struct {
+	ID                   int           `json:"id"`
+	Character            string        `json:"character,omitempty"` // 怪物为空
+	Score                int           `json:"score"`
+	Count                int           `json:"count"`
+	X                    float64       `json:"x"`
+	Y                    float64       `json:"y"`
+	Speed                float64       `json:"speed"`
+	Weapon               *savedWeapon  `json:"weapon,omitempty"`
+	WeaponX              float64       `json:"weapon_x"`
+	WeaponY              float64       `json:"weapon_y"`
+	Health               float64       `json:"health"`
+	MaxHealth            float64       `json:"max_health"`
+	LastCollisionTime    int64         `json:"last_collision_time"`
+	DirectIdx            int           `json:"direct_idx"`
+	InvincibleUntil      int64         `json:"invincible_until"`
+	Skills               []savedSkill  `json:"skills,omitempty"`
+	DashCharges          float64       `json:"dash_charges"`
+	DashFrame            int           `json:"dash_frame"`
+	DashTime             int64         `json:"dash_time"`
+	DashX                float64       `json:"dash_x"`
+	DashY                float64       `json:"dash_y"`
+	Statuses             []savedStatus `json:"statuses,omitempty"`
+	Team                 Team          `json:"team"`
+	Downed               bool          `json:"downed"`
+	ReviveProgress       int           `json:"revive_progress"`
+	Shield               float64       `json:"shield"`
+	ShieldUntil          int64         `json:"shield_until"`
+	SpeedBoostUntil      int64         `json:"speed_boost_until"`
+	ScoreMultiplierUntil int64         `json:"score_multiplier_until"`
+	MagnetUntil          int64         `json:"magnet_until"`
+	Kills                int           `json:"kills"`
+	DamageTaken          float64       `json:"damage_taken"`
+	XP                   int           `json:"xp"`
+	Level                int           `json:"level"`
+	SpinBonus            float64       `json:"spin_bonus"`
+	Pierce               int           `json:"pierce"`
+	CooldownReduction    float64       `json:"cooldown_reduction"`
+	DashRecharge         float64       `json:"dash_recharge"`
+
+	Target                  int      `json:"target,omitempty"` // 怪物追逐的玩家 id，为 0 时没有目标
+	TargetWeakest           bool     `json:"target_weakest,omitempty"`
+	HasSteadyWeaponPosition bool     `json:"has_steady_weapon_position,omitempty"`
+	SteadyWeaponID          int      `json:"steady_weapon_id,omitempty"`
+	SteadyWeaponPosition    f64.Vec2 `json:"steady_weapon_position"`
+}
+
+type savedWeapon struct {
+	Type         string     `json:"type"`
+	Angle        float64    `json:"angle,omitempty"`
+	Trail        []f64.Vec2 `json:"trail,omitempty"`
+	SpinBonus    float64    `json:"spin_bonus,omitempty"`
+	LastFireTime int64      `json:"last_fire_time,omitempty"`
+	Pierce       int        `json:"pierce,omitempty"`
+}
+
+type savedSkill struct {
+	Name     string           `json:"name"`
+	LastTime int64            `json:"last_time"`
+	Active   bool             `json:"active"`
+	Frame    int              `json:"frame"`
+	Pushed   map[int]f64.Vec2 `json:"pushed,omitempty"` // 冲击波击退的怪物
+	Pos      f64.Vec2         `json:"pos"`              // 诱饵的位置
+}
+
+type savedStatus struct {
+	Kind      StatusKind `json:"kind"`
+	Remaining int        `json:"remaining"`
+	Stacks    int        `json:"stacks"`
+}
+
+type savedSuspend struct {
+	Weapon      string    `json:"weapon"` // 开火的武器类型
+	Pos         f64.Vec2  `json:"pos"`
+	From        f64.Vec2  `json:"from"`
+	DirectIndex int       `json:"direct_index"`
+	Time        int       `json:"time"`
+	PlayerID    int       `json:"player_id"`
+	Team        Team      `json:"team"`
+	Direction   *f64.Vec2 `json:"direction,omitempty"`
+	Pierce      int       `json:"pierce"`
+}
+
+type savedHazard struct {
+	Pos       f64.Vec2   `json:"pos"`
+	Radius    float64    `json:"radius"`
+	Effect    StatusKind `json:"effect"`
+	Remaining int        `json:"remaining"`
+	Frame     int        `json:"frame"`
+}
+
+type savedPickup struct {
+	Kind      PickupKind `json:"kind"`
+	Pos       f64.Vec2   `json:"pos"`
+	Remaining int        `json:"remaining"`
+}
+
+// clockEpoch 游戏时钟的起点，与 NewClock 一致
+var clockEpoch = time.Unix(0, 0)
+
+// saveTime 将游戏时间转换为时钟起点之后的纳秒数，零值时间保存为 math.MinInt64
+func saveTime(t time.Time) int64 {
+	if t.IsZero() {
+		return math.MinInt64
+	}
+	return int64(t.Sub(clockEpoch))
+}
+
+func loadTime(v int64) time.Time {
+	if v == math.MinInt64 {
+		return time.Time{}
+	}
+	return clockEpoch.Add(time.Duration(v))
+}
+
+// RunSavePath 进行中的游戏存档的路径，与玩家档案保存在同一目录，档案只保存在内存中时不保存游戏
+func (p *Profile) RunSavePath() string {
+	if p.path == "" {
+		return ""
+	}
+	return filepath.Join(filepath.Dir(p.path), "run.json")
+}
+
+// LoadRunSave 读取进行中的游戏存档，存档不存在时返回 nil
+func LoadRunSave(path string) (*RunSave, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// 数字保留原文，避免随机数状态等 64 位整数经过 float64 后丢失精度
+	raw := make(map[string]any)
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	if err := decoder.Decode(&raw); err != nil {
+		return nil, err
+	}
+	if err := migrate(raw, runSaveMigrations, 1, runSaveVersion); err != nil {
+		return nil, fmt.Errorf("run save: %w", err)
+	}
+
+	if data, err = json.Marshal(raw); err != nil {
+		return nil, err
+	}
+	s := &RunSave{}
+	if err := json.Unmarshal(data, s); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Save 保存游戏
+func (s *RunSave) Save(path string) error {
+	data, err := json.Marshal(s)
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(path, data)
+}
+
+// canSaveRun 只保存本地的生存模式，联网对战和竞技场不保存
+func (g *Game) canSaveRun() bool {
+	return g.profile.RunSavePath() != "" && g.seats == nil && g.arena == nil
+}
+
+// saveRun 保存进行中的游戏
+func (g *Game) saveRun() {
+	if !g.canSaveRun() {
+		return
+	}
+	if err := g.snapshotRun().Save(g.profile.RunSavePath()); err != nil {
+		log.Println("save run:", err)
+		return
+	}
+	g.autosaveTimer = g.clock.Now()
+}
+
+// deleteRun 游戏结束后删除存档，结束的游戏不能继续
+func (g *Game) deleteRun() {
+	path := g.profile.RunSavePath()
+	if path == "" {
+		return
+	}
+	if err := os.Remove(path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		log.Println("delete run:", err)
+	}
+	g.canContinue = false
+}
+
+// resolveAutosave 游戏进行中每隔一段时间自动保存
+func (g *Game) resolveAutosave() {
+	if g.clock.Since(g.autosaveTimer) >= config.AutosaveInterval*time.Second {
+		g.saveRun()
+	}
+}
+
+// continueRun 读取存档并从保存时的状态继续游戏，失败时留在标题界面
+func (g *Game) continueRun() {
+	s, err := LoadRunSave(g.profile.RunSavePath())
+	if err == nil && s == nil {
+		err = errors.New("no run saved")
+	}
+	if err == nil {
+		err = g.restoreRun(s)
+	}
+	if err != nil {
+		log.Println("continue run:", err)
+		g.init()
+		g.canContinue = false
+		return
+	}
+	g.mode = config.ModeGame
+}
+
+// snapshotRun 将进行中的游戏转换为存档
+func (g *Game) snapshotRun() *RunSave {
+	s := &RunSave{
+		Version:                  runSaveVersion,
+		SavedAt:                  time.Now(),
+		Clock:                    saveTime(g.clock.Now()),
+		StartTime:                saveTime(g.startTime),
+		Rng:                      g.rng.state,
+		UniqueID:                 g.uniqueId,
+		TimeScale:                g.timeScale,
+		WeaponTimer:              saveTime(g.weaponTimer),
+		HazardTimer:              saveTime(g.hazardTimer),
+		PickupTimer:              saveTime(g.pickupTimer),
+		Monsters:                 make(map[int]savedPlayer, len(g.monsters)),
+		MonsterTarget:            g.monsterTarget,
+		MonsterTimer:             g.monsterTimer,
+		WeaponPosition:           g.weaponPosition,
+		WeaponPositionBeenPicked: g.weaponPositionBeenPicked,
+		Weapons:                  make(map[int]savedWeapon, len(g.weapons)),
+		Suspends:                 make(map[int]savedSuspend, len(g.suspends)),
+		Hazards:                  make(map[int]savedHazard, len(g.hazards)),
+		Pickups:                  make(map[int]savedPickup, len(g.pickups)),
+	}
+	for _, p := range g.players {
+		s.Players = append(s.Players, snapshotPlayer(p))
+	}
+	for id, monster := range g.monsters {
+		s.Monsters[id] = snapshotPlayer(monster)
+	}
+	for id, weapon := range g.weapons {
+		s.Weapons[id] = *snapshotWeapon(weapon)
+	}
+	for id, suspend := range g.suspends {
+		saved := savedSuspend{
+			Weapon:      suspend.rangeWeapon.Type,
+			Pos:         suspend.pos,
+			From:        suspend.from,
+			DirectIndex: suspend.directIndex,
+			Time:        suspend.time,
+			PlayerID:    suspend.PlayerID,
+			Team:        suspend.team,
+			Pierce:      suspend.pierce,
+		}
+		if suspend.direction != nil {
+			saved.Direction = &f64.Vec2{suspend.direction.x, suspend.direction.y}
+		}
+		s.Suspends[id] = saved
+	}
+	for id, h := range g.hazards {
+		s.Hazards[id] = savedHazard{Pos: h.pos, Radius: h.radius, Effect: h.effect, Remaining: h.remaining, Frame: h.frame}
+	}
+	for id, pickup := range g.pickups {
+		s.Pickups[id] = savedPickup{Kind: pickup.kind, Pos: pickup.pos, Remaining: pickup.remaining}
+	}
+	return s
+}
+
+func snapshotPlayer(p *Player) savedPlayer {
+	s := savedPlayer{
+		ID:                      p.id,
+		Score:                   p.score,
+		Count:                   p.count,
+		X:                       p.x,
+		Y:                       p.y,
+		Speed:                   p.speed,
+		WeaponX:                 p.weaponX,
+		WeaponY:                 p.weaponY,
+		Health:                  p.health,
+		MaxHealth:               p.maxHealth,
+		LastCollisionTime:       saveTime(p.lastCollisionTime),
+		DirectIdx:               p.directIdx,
+		InvincibleUntil:         saveTime(p.invincibleUntil),
+		DashCharges:             p.dashCharges,
+		DashFrame:               p.dashFrame,
+		DashTime:                saveTime(p.dashTime),
+		DashX:                   p.dashX,
+		DashY:                   p.dashY,
+		Team:                    p.team,
+		Downed:                  p.downed,
+		ReviveProgress:          p.reviveProgress,
+		Shield:                  p.shield,
+		ShieldUntil:             saveTime(p.shieldUntil),
+		SpeedBoostUntil:         saveTime(p.speedBoostUntil),
+		ScoreMultiplierUntil:    saveTime(p.scoreMultiplierUntil),
+		MagnetUntil:             saveTime(p.magnetUntil),
+		Kills:                   p.kills,
+		DamageTaken:             p.damageTaken,
+		XP:                      p.xp,
+		Level:                   p.level,
+		SpinBonus:               p.mods.spinBonus,
+		Pierce:                  p.mods.pierce,
+		CooldownReduction:       p.mods.cooldownReduction,
+		DashRecharge:            p.mods.dashRecharge,
+		TargetWeakest:           p.targetWeakest,
+		HasSteadyWeaponPosition: p.hasSteadyWeaponPosition,
+		SteadyWeaponID:          p.steadyWeaponId,
+		SteadyWeaponPosition:    p.steadyWeaponPosition,
+	}
+	if p.character != nil {
+		s.Character = p.character.ID
+	}
+	if p.target != nil {
+		s.Target = p.target.id
+	}
+	if p.weapon != nil {
+		s.Weapon = snapshotWeapon(p.weapon)
+	}
+	for _, slot := range p.skills {
+		saved := savedSkill{Name: slot.skill.Name(), LastTime: saveTime(slot.lastTime), Active: slot.active}
+		switch skill := slot.skill.(type) {
+		case *InvincibleSkill:
+			saved.Frame = skill.frame
+		case *DashSkill:
+			saved.Frame = skill.frame
+		case *ShockwaveSkill:
+			saved.Frame, saved.Pushed = skill.frame, skill.pushed
+		case *TimeSlowSkill:
+			saved.Frame = skill.frame
+		case *DecoySkill:
+			saved.Frame, saved.Pos = skill.frame, skill.pos
+		}
+		s.Skills = append(s.Skills, saved)
+	}
+	for _, kind := range sortedKeys(p.statuses) {
+		status := p.statuses[kind]
+		s.Statuses = append(s.Statuses, savedStatus{Kind: kind, Remaining: status.remaining, Stacks: status.stacks})
+	}
+	return s
+}
+
+func snapshotWeapon(weapon Weapon) *savedWeapon {
+	s := &savedWeapon{Type: weapon.GetType()}
+	switch weapon := weapon.(type) {
+	case *MeleeWeapon:
+		s.Angle, s.Trail, s.SpinBonus = weapon.angle, weapon.Trail, weapon.spinBonus
+	case *RangedWeapon:
+		s.LastFireTime, s.Pierce = saveTime(weapon.LastFireTime), weapon.pierce
+	}
+	return s
+}
+
+// restoreRun 按存档重建游戏状态，玩家数量超过可用的操作来源时失败
+func (g *Game) restoreRun(s *RunSave) error {
+	sources := InputSources(len(s.Players))
+	if len(s.Players) == 0 || len(sources) < len(s.Players) {
+		return fmt.Errorf("run needs %d players, %d input sources available", len(s.Players), len(sources))
+	}
+
+	g.playerCount = len(s.Players)
+	g.arena = nil
+	g.init()
+	g.clock.now = loadTime(s.Clock)
+	g.startTime = loadTime(s.StartTime)
+	g.rng.state = s.Rng
+	g.uniqueId = s.UniqueID
+	g.timeScale = s.TimeScale
+	g.weaponTimer = loadTime(s.WeaponTimer)
+	g.hazardTimer = loadTime(s.HazardTimer)
+	g.pickupTimer = loadTime(s.PickupTimer)
+	g.autosaveTimer = g.clock.Now()
+
+	g.players = nil
+	for i, saved := range s.Players {
+		p, err := g.restorePlayer(saved)
+		if err != nil {
+			return err
+		}
+		p.input = sources[i]
+		g.players = append(g.players, p)
+	}
+	for id, saved := range s.Monsters {
+		monster, err := g.restorePlayer(saved)
+		if err != nil {
+			return err
+		}
+		g.monsters[id] = monster
+	}
+	// 怪物追逐的玩家在所有玩家创建之后再关联
+	for id, monster := range g.monsters {
+		if target := s.Monsters[id].Target; target != 0 {
+			monster.target = g.playerByID(target)
+		}
+	}
+	for id, v := range s.MonsterTarget {
+		g.monsterTarget[id] = v
+	}
+	for id, v := range s.MonsterTimer {
+		g.monsterTimer[id] = v
+	}
+	for id, v := range s.WeaponPosition {
+		g.weaponPosition[id] = v
+	}
+	for id, v := range s.WeaponPositionBeenPicked {
+		g.weaponPositionBeenPicked[id] = v
+	}
+	for id, saved := range s.Weapons {
+		weapon, err := restoreWeapon(&saved)
+		if err != nil {
+			return err
+		}
+		g.weapons[id] = weapon
+	}
+	for id, saved := range s.Suspends {
+		weapon, ok := NewWeapon(saved.Weapon).(*RangedWeapon)
+		if !ok {
+			return fmt.Errorf("bullet %d: unknown ranged weapon %q", id, saved.Weapon)
+		}
+		suspend := &Suspend{
+			pos:         saved.Pos,
+			rangeWeapon: weapon,
+			from:        saved.From,
+			directIndex: saved.DirectIndex,
+			time:        saved.Time,
+			PlayerID:    saved.PlayerID,
+			team:        saved.Team,
+			pierce:      saved.Pierce,
+		}
+		if saved.Direction != nil {
+			suspend.direction = &SuspendDirection{x: saved.Direction[0], y: saved.Direction[1]}
+		}
+		g.suspends[id] = suspend
+	}
+	for id, saved := range s.Hazards {
+		g.hazards[id] = &Hazard{pos: saved.Pos, radius: saved.Radius, effect: saved.Effect, remaining: saved.Remaining, frame: saved.Frame}
+	}
+	for id, saved := range s.Pickups {
+		g.pickups[id] = &Pickup{kind: saved.Kind, pos: saved.Pos, remaining: saved.Remaining}
+	}
+
+	// 诱饵的位置指向生效中的诱饵技能
+	for _, p := range g.players {
+		for _, slot := range p.skills {
+			if skill, ok := slot.skill.(*DecoySkill); ok && slot.active {
+				g.decoy = &skill.pos
+			}
+		}
+	}
+	return nil
+}
+
+func (g *Game) restorePlayer(s savedPlayer) (*Player, error) {
+	p := &Player{
+		id:                      s.ID,
+		clock:                   g.clock,
+		score:                   s.Score,
+		count:                   s.Count,
+		x:                       s.X,
+		y:                       s.Y,
+		speed:                   s.Speed,
+		weaponX:                 s.WeaponX,
+		weaponY:                 s.WeaponY,
+		health:                  s.Health,
+		maxHealth:               s.MaxHealth,
+		lastCollisionTime:       loadTime(s.LastCollisionTime),
+		directIdx:               s.DirectIdx,
+		invincibleUntil:         loadTime(s.InvincibleUntil),
+		dashCharges:             s.DashCharges,
+		dashFrame:               s.DashFrame,
+		dashTime:                loadTime(s.DashTime),
+		dashX:                   s.DashX,
+		dashY:                   s.DashY,
+		statuses:                make(map[StatusKind]*Status),
+		team:                    s.Team,
+		downed:                  s.Downed,
+		reviveProgress:          s.ReviveProgress,
+		shield:                  s.Shield,
+		shieldUntil:             loadTime(s.ShieldUntil),
+		speedBoostUntil:         loadTime(s.SpeedBoostUntil),
+		scoreMultiplierUntil:    loadTime(s.ScoreMultiplierUntil),
+		magnetUntil:             loadTime(s.MagnetUntil),
+		kills:                   s.Kills,
+		damageTaken:             s.DamageTaken,
+		xp:                      s.XP,
+		level:                   s.Level,
+		mods:                    Modifiers{spinBonus: s.SpinBonus, pierce: s.Pierce, cooldownReduction: s.CooldownReduction, dashRecharge: s.DashRecharge},
+		targetWeakest:           s.TargetWeakest,
+		hasSteadyWeaponPosition: s.HasSteadyWeaponPosition,
+		steadyWeaponId:          s.SteadyWeaponID,
+		steadyWeaponPosition:    s.SteadyWeaponPosition,
+	}
+	if s.Character != "" {
+		p.character = CharacterByID(s.Character)
+	}
+	if s.Weapon != nil {
+		weapon, err := restoreWeapon(s.Weapon)
+		if err != nil {
+			return nil, fmt.Errorf("player %d: %w", s.ID, err)
+		}
+		p.weapon = weapon
+	}
+	for _, saved := range s.Skills {
+		skill := NewSkill(saved.Name)
+		if skill == nil {
+			return nil, fmt.Errorf("player %d: unknown skill %q", s.ID, saved.Name)
+		}
+		switch skill := skill.(type) {
+		case *InvincibleSkill:
+			skill.frame = saved.Frame
+		case *DashSkill:
+			skill.frame = saved.Frame
+		case *ShockwaveSkill:
+			skill.frame, skill.pushed = saved.Frame, saved.Pushed
+		case *TimeSlowSkill:
+			skill.frame = saved.Frame
+		case *DecoySkill:
+			skill.frame, skill.pos = saved.Frame, saved.Pos
+		}
+		p.skills = append(p.skills, &SkillSlot{skill: skill, lastTime: loadTime(saved.LastTime), active: saved.Active})
+	}
+	for _, saved := range s.Statuses {
+		p.statuses[saved.Kind] = &Status{kind: saved.Kind, remaining: saved.Remaining, stacks: saved.Stacks}
+	}
+	return p, nil
+}
+
+// restoreWeapon 按武器类型重新创建武器，图片和音效取自武器列表，再恢复武器的状态
+func restoreWeapon(s *savedWeapon) (Weapon, error) {
+	weapon := NewWeapon(s.Type)
+	switch weapon := weapon.(type) {
+	case *MeleeWeapon:
+		weapon.angle, weapon.Trail, weapon.spinBonus = s.Angle, s.Trail, s.SpinBonus
+	case *RangedWeapon:
+		weapon.LastFireTime, weapon.pierce = loadTime(s.LastFireTime), s.Pierce
+	case nil:
+		return nil, fmt.Errorf("unknown weapon %q", s.Type)
+	}
+	return weapon, nil
+}
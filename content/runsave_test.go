@@ -0,0 +1,237 @@
+package main
+
+import (
+	"avoid-the-enemies/content/config"
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"golang.org/x/image/math/f64"
+)
+
+// newRunSaveTestGame 以固定的种子开始一局单人游戏，模拟 frames 帧后补充一些自然模拟中不一定出现的状态
+func newRunSaveTestGame(t *testing.T, frames int) *Game {
+	t.Helper()
+	g := &Game{profile: NewProfile(""), seed: 777}
+	g.profile.Character = "knight"
+	g.init()
+	g.mode = config.ModeGame
+	for f := 0; f < frames; f++ {
+		if err := g.Update(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	p := g.players[0]
+	sword, ok := p.weapon.(*MeleeWeapon)
+	if !ok {
+		t.Fatalf("knight carries %T, want a melee weapon", p.weapon)
+	}
+	sword.angle = 1.25
+	sword.Trail = []f64.Vec2{{10, 20}, {12.5, 21.75}, {15, 23}}
+
+	// 飞行中的子弹
+	ak := NewWeapon("ak").(*RangedWeapon)
+	g.uniqueId++
+	g.suspends[g.uniqueId] = &Suspend{
+		pos:         f64.Vec2{100, 120},
+		rangeWeapon: ak,
+		from:        f64.Vec2{90, 110},
+		directIndex: 2,
+		time:        5,
+		PlayerID:    p.id,
+		team:        p.team,
+		direction:   &SuspendDirection{x: 0.6, y: 0.8},
+		pierce:      1,
+	}
+
+	// 释放所有技能后再模拟几帧，技能动画的帧数不为 0
+	p.score = 1000
+	for _, slot := range p.skills {
+		slot.Use(g, p)
+	}
+	p.ApplyStatus(StatusPoison)
+	for f := 0; f < 10; f++ {
+		if err := g.Update(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return g
+}
+
+// saveAndRestore 保存游戏到临时文件，再像继续游戏时一样读取到一局新的游戏中
+func saveAndRestore(t *testing.T, g *Game) (*RunSave, *Game) {
+	t.Helper()
+	saved := g.snapshotRun()
+	path := filepath.Join(t.TempDir(), "run.json")
+	if err := saved.Save(path); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := LoadRunSave(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	restored := &Game{profile: NewProfile("")}
+	if err := restored.restoreRun(loaded); err != nil {
+		t.Fatal(err)
+	}
+	restored.mode = config.ModeGame // 与 continueRun 相同
+	return saved, restored
+}
+
+func TestRunSaveRoundTrip(t *testing.T) {
+	g := newRunSaveTestGame(t, 300)
+	saved, restored := saveAndRestore(t, g)
+
+	// 恢复的游戏再次保存，得到的存档应该与原来的完全相同
+	again := restored.snapshotRun()
+	again.SavedAt = saved.SavedAt
+	want, _ := json.Marshal(saved)
+	got, _ := json.Marshal(again)
+	if !bytes.Equal(got, want) {
+		t.Errorf("restored run saves differently\ngot  %s\nwant %s", got, want)
+	}
+	if c, want := restored.Checksum(), g.Checksum(); c != want {
+		t.Errorf("checksum of the restored run = %08x, want %08x", c, want)
+	}
+
+	p, q := g.players[0], restored.players[0]
+	sword, restoredSword := p.weapon.(*MeleeWeapon), q.weapon.(*MeleeWeapon)
+	if restoredSword.angle != sword.angle || !reflect.DeepEqual(restoredSword.Trail, sword.Trail) {
+		t.Errorf("weapon angle %v trail %v, want %v %v", restoredSword.angle, restoredSword.Trail, sword.angle, sword.Trail)
+	}
+	if len(restored.suspends) != len(g.suspends) {
+		t.Fatalf("%d bullets restored, want %d", len(restored.suspends), len(g.suspends))
+	}
+	for id, s := range g.suspends {
+		r := restored.suspends[id]
+		if r == nil || r.pos != s.pos || r.from != s.from || r.rangeWeapon.Type != s.rangeWeapon.Type || r.pierce != s.pierce ||
+			(s.direction == nil) != (r.direction == nil) || (s.direction != nil && *r.direction != *s.direction) {
+			t.Errorf("bullet %d = %+v, want %+v", id, r, s)
+		}
+	}
+	active := 0
+	for i, slot := range p.skills {
+		r := q.skills[i]
+		if r.skill.Name() != slot.skill.Name() || r.active != slot.active || r.lastTime != slot.lastTime {
+			t.Errorf("skill slot %d = %s active %v, want %s active %v", i, r.skill.Name(), r.active, slot.skill.Name(), slot.active)
+		}
+		if !reflect.DeepEqual(r.skill, slot.skill) {
+			t.Errorf("skill %s = %+v, want %+v", slot.skill.Name(), r.skill, slot.skill)
+		}
+		if slot.active {
+			active++
+		}
+	}
+	if active == 0 {
+		t.Error("no active skill was saved, the test does not cover skill frames")
+	}
+	targets := 0
+	for id, monster := range g.monsters {
+		r := restored.monsters[id]
+		if r == nil {
+			t.Errorf("monster %d is missing", id)
+			continue
+		}
+		if monster.target != nil {
+			targets++
+			if r.target == nil || r.target.id != monster.target.id || r.target != restored.playerByID(monster.target.id) {
+				t.Errorf("monster %d chases %v, want player %d of the restored run", id, r.target, monster.target.id)
+			}
+		}
+		if restored.monsterTarget[id] != g.monsterTarget[id] {
+			t.Errorf("monster %d moves to %v, want %v", id, restored.monsterTarget[id], g.monsterTarget[id])
+		}
+	}
+	if targets == 0 {
+		t.Error("no monster chases a player, the test does not cover monster targets")
+	}
+}
+
+func TestRunSaveContinue(t *testing.T) {
+	g := newRunSaveTestGame(t, 200)
+	_, restored := saveAndRestore(t, g)
+
+	// 继续模拟时两局游戏的结果应该相同
+	for f := 0; f < 300; f++ {
+		if err := g.Update(); err != nil {
+			t.Fatal(err)
+		}
+		if err := restored.Update(); err != nil {
+			t.Fatal(err)
+		}
+		if c, want := restored.Checksum(), g.Checksum(); c != want {
+			t.Fatalf("frame %d after restoring: checksum %08x, want %08x", f, c, want)
+		}
+	}
+}
+
+func TestRunSaveVersion(t *testing.T) {
+	if runSaveVersion != len(runSaveMigrations)+1 {
+		t.Fatalf("run save version %d needs %d migrations, have %d", runSaveVersion, runSaveVersion-1, len(runSaveMigrations))
+	}
+
+	for _, version := range []string{"0", "2", ""} {
+		path := filepath.Join(t.TempDir(), "run.json")
+		data := `{"players": []}`
+		if version != "" {
+			data = `{"version": ` + version + `, "players": []}`
+		}
+		if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := LoadRunSave(path); err == nil {
+			t.Errorf("LoadRunSave accepted version %q", version)
+		}
+	}
+
+	if s, err := LoadRunSave(filepath.Join(t.TempDir(), "missing.json")); s != nil || err != nil {
+		t.Errorf("LoadRunSave of a missing file = %v, %v, want nil, nil", s, err)
+	}
+}
+
+func TestRunSaveMigrations(t *testing.T) {
+	// 版本 1 -> 2 重命名字段，版本 2 -> 3 补全新的字段，迁移按顺序执行
+	migrations := []func(raw map[string]any){
+		func(raw map[string]any) {
+			raw["score"] = raw["points"]
+			delete(raw, "points")
+		},
+		func(raw map[string]any) {
+			if _, ok := raw["bonus"]; !ok {
+				raw["bonus"] = raw["score"]
+			}
+		},
+	}
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{`{"version": 1, "points": 5}`, `{"bonus":5,"score":5,"version":3}`},
+		{`{"version": 2, "score": 7}`, `{"bonus":7,"score":7,"version":3}`},
+		{`{"version": 3, "score": 7, "bonus": 1}`, `{"bonus":1,"score":7,"version":3}`},
+	}
+	for _, test := range tests {
+		raw := make(map[string]any)
+		decoder := json.NewDecoder(bytes.NewReader([]byte(test.in)))
+		decoder.UseNumber()
+		if err := decoder.Decode(&raw); err != nil {
+			t.Fatal(err)
+		}
+		if err := migrate(raw, migrations, 1, 3); err != nil {
+			t.Errorf("migrate %s: %v", test.in, err)
+			continue
+		}
+		if got, _ := json.Marshal(raw); string(got) != test.want {
+			t.Errorf("migrate %s = %s, want %s", test.in, got, test.want)
+		}
+	}
+
+	raw := map[string]any{"version": json.Number("4")}
+	if err := migrate(raw, migrations, 1, 3); err == nil {
+		t.Error("migrate accepted a save newer than the latest version")
+	}
+}
@@ -0,0 +1,182 @@
+package main
+
+import (
+	"avoid-the-enemies/content/config"
+	"image/color"
+	"log"
+	"slices"
+	"strconv"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/inpututil"
+	"github.com/hajimehoshi/ebiten/v2/text/v2"
+	"github.com/hajimehoshi/ebiten/v2/vector"
+)
+
+// volumeStep 设置界面中每次调整音量的幅度（百分比）
+const volumeStep = 10
+
+// Settings 保存在玩家档案中的游戏设置
+type Settings struct {
+	MasterVolume int    `json:"master_volume"` // 主音量（百分比）
+	MusicVolume  int    `json:"music_volume"`  // 音乐音量（百分比）
+	SFXVolume    int    `json:"sfx_volume"`    // 音效音量（百分比）
+	Language     string `json:"language"`      // 界面语言的 ID，为空时按系统语言选择
+	DamageFlash  bool   `json:"damage_flash"`  // 受伤时画面闪烁红色
+	Vignette     bool   `json:"vignette"`      // 低血量时画面边缘变暗
+	ScreenShake  bool   `json:"screen_shake"`  // 受伤时画面震动
+	HitStop      bool   `json:"hit_stop"`      // 击杀怪物时游戏短暂停顿
+	ChromaPulse  bool   `json:"chroma_pulse"`  // 释放无敌技能时画面出现色差
+}
+
+func DefaultSettings() Settings {
+	return Settings{
+		MasterVolume: 100, MusicVolume: 100, SFXVolume: 100,
+		DamageFlash: true, Vignette: true, ScreenShake: true, HitStop: true, ChromaPulse: true,
+	}
+}
+
+// Apply 使设置生效
+func (s *Settings) Apply() {
+	SetBusVolume(BusMaster, float64(s.MasterVolume)/100)
+	SetBusVolume(BusMusic, float64(s.MusicVolume)/100)
+	SetBusVolume(BusSFX, float64(s.SFXVolume)/100)
+	SetLocale(s.Language)
+	effectToggles = EffectToggles{
+		Flash:    s.DamageFlash,
+		Vignette: s.Vignette,
+		Shake:    s.ScreenShake,
+		HitStop:  s.HitStop,
+		Chroma:   s.ChromaPulse,
+	}
+}
+
+// settingItem 设置界面中的一项，左右键调整取值
+type settingItem struct {
+	name   string // 名称的字符串键
+	value  func(s *Settings) string
+	adjust func(s *Settings, delta int) // delta 为 -1 或者 1
+}
+
+var settingItems = []*settingItem{
+	volumeSetting("settings.master_volume", func(s *Settings) *int { return &s.MasterVolume }),
+	volumeSetting("settings.music_volume", func(s *Settings) *int { return &s.MusicVolume }),
+	volumeSetting("settings.sfx_volume", func(s *Settings) *int { return &s.SFXVolume }),
+	{
+		name: "settings.language",
+		value: func(s *Settings) string {
+			return currentLocale.Name
+		},
+		adjust: func(s *Settings, delta int) {
+			// 按系统语言选择时从当前的语言开始切换
+			i := slices.Index(localeList, currentLocale)
+			s.Language = localeList[(i+delta+len(localeList))%len(localeList)].ID
+		},
+	},
+	toggleSetting("settings.damage_flash", func(s *Settings) *bool { return &s.DamageFlash }),
+	toggleSetting("settings.vignette", func(s *Settings) *bool { return &s.Vignette }),
+	toggleSetting("settings.screen_shake", func(s *Settings) *bool { return &s.ScreenShake }),
+	toggleSetting("settings.hit_stop", func(s *Settings) *bool { return &s.HitStop }),
+	toggleSetting("settings.chroma_pulse", func(s *Settings) *bool { return &s.ChromaPulse }),
+}
+
+// volumeSetting 调整 field 指向的音量，取值为 0 到 100
+func volumeSetting(name string, field func(s *Settings) *int) *settingItem {
+	return &settingItem{
+		name: name,
+		value: func(s *Settings) string {
+			return strconv.Itoa(*field(s)) + "%"
+		},
+		adjust: func(s *Settings, delta int) {
+			v := field(s)
+			*v = min(max(*v+delta*volumeStep, 0), 100)
+		},
+	}
+}
+
+// toggleSetting 开关 field 指向的选项，左右键都会切换
+func toggleSetting(name string, field func(s *Settings) *bool) *settingItem {
+	return &settingItem{
+		name: name,
+		value: func(s *Settings) string {
+			if *field(s) {
+				return T("common.on")
+			}
+			return T("common.off")
+		},
+		adjust: func(s *Settings, delta int) {
+			v := field(s)
+			*v = !*v
+		},
+	}
+}
+
+// openSettings 打开设置界面，返回时回到当前的界面
+func (g *Game) openSettings() {
+	g.settingsReturn = g.mode
+	g.settingsCursor = 0
+	g.mode = config.ModeSettings
+}
+
+// resolveModeSettings 上下键选择设置项，左右键调整，调整后立即生效，Esc 键保存并返回
+func (g *Game) resolveModeSettings() {
+	if inpututil.IsKeyJustPressed(ebiten.KeyEscape) || inpututil.IsKeyJustPressed(ebiten.KeyBackspace) {
+		if err := g.profile.Save(); err != nil {
+			log.Println("save profile:", err)
+		}
+		g.mode = g.settingsReturn
+		return
+	}
+	if inpututil.IsKeyJustPressed(ebiten.KeyArrowUp) {
+		g.settingsCursor = (g.settingsCursor + len(settingItems) - 1) % len(settingItems)
+	}
+	if inpututil.IsKeyJustPressed(ebiten.KeyArrowDown) {
+		g.settingsCursor = (g.settingsCursor + 1) % len(settingItems)
+	}
+	delta := 0
+	if inpututil.IsKeyJustPressed(ebiten.KeyArrowLeft) {
+		delta--
+	}
+	if inpututil.IsKeyJustPressed(ebiten.KeyArrowRight) {
+		delta++
+	}
+	if delta != 0 {
+		settingItems[g.settingsCursor].adjust(&g.profile.Settings, delta)
+		g.profile.Settings.Apply()
+	}
+}
+
+// DrawSettings 绘制设置界面
+func DrawSettings(screen *ebiten.Image, g *Game) {
+	face := arcadeFace(config.FontSize)
+
+	op := &text.DrawOptions{}
+	op.GeoM.Translate(config.ScreenWidth/2, 2*config.TitleFontSize)
+	op.ColorScale.ScaleWithColor(color.White)
+	op.PrimaryAlign = text.AlignCenter
+	text.Draw(screen, T("settings.title"), arcadeFace(config.TitleFontSize), op)
+
+	const top, lineHeight = 56, 11
+	for i, item := range settingItems {
+		y := float64(top + i*lineHeight)
+		if i == g.settingsCursor {
+			vector.DrawFilledRect(screen, 16, float32(y)-3, config.ScreenWidth-32, lineHeight, color.RGBA{0x30, 0x30, 0x60, 0xFF}, false)
+		}
+		op = &text.DrawOptions{}
+		op.GeoM.Translate(24, y)
+		op.ColorScale.ScaleWithColor(color.White)
+		text.Draw(screen, T(item.name), face, op)
+
+		op = &text.DrawOptions{}
+		op.GeoM.Translate(config.ScreenWidth-24, y)
+		op.ColorScale.ScaleWithColor(color.Gray{0xC0})
+		op.PrimaryAlign = text.AlignEnd
+		text.Draw(screen, "< "+item.value(&g.profile.Settings)+" >", face, op)
+	}
+
+	op = &text.DrawOptions{}
+	op.GeoM.Translate(config.ScreenWidth/2, config.ScreenHeight-1.5*config.FontSize)
+	op.ColorScale.ScaleWithColor(color.Gray{0x80})
+	op.PrimaryAlign = text.AlignCenter
+	text.Draw(screen, T("settings.hint"), arcadeFace(6), op)
+}
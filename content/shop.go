@@ -0,0 +1,224 @@
+package main
+
+import (
+	"avoid-the-enemies/content/config"
+	"errors"
+	"image/color"
+	"log"
+	"strconv"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/inpututil"
+	"github.com/hajimehoshi/ebiten/v2/text/v2"
+	"github.com/hajimehoshi/ebiten/v2/vector"
+)
+
+type ShopItemKind int
+
+const (
+	ShopWeapon    ShopItemKind = iota // 开局携带的武器
+	ShopSkill                         // 可以装备的技能
+	ShopStat                          // 永久属性加成，可以多次购买
+	ShopCharacter                     // 可以选择的角色，由 InitCharacter 按角色数据添加
+)
+
+// 购买失败的错误信息是字符串键，显示时翻译
+var (
+	errNotEnoughCoins = errors.New("shop.not_enough_coins")
+	errMaxLevel       = errors.New("shop.max_level")
+	errOwned          = errors.New("shop.already_owned")
+)
+
+// ShopItem 商店中的商品
+type ShopItem struct {
+	id       string // 存档中记录的键，名称和说明的字符串键为 shop.<id> 和 shop.<id>.desc
+	kind     ShopItemKind
+	price    int    // 价格，永久属性加成每升一级价格增加一倍
+	maxLevel int    // 永久属性加成的最大等级
+	target   string // 武器类型或者技能名称
+}
+
+var shopItems = []*ShopItem{
+	{id: "weapon:sickle", kind: ShopWeapon, price: 50, target: "sickle"},
+	{id: "weapon:sword", kind: ShopWeapon, price: 80, target: "sword"},
+	{id: "weapon:ak", kind: ShopWeapon, price: 120, target: "ak"},
+	{id: "skill:dash", kind: ShopSkill, price: 60, target: "dash"},
+	{id: "skill:decoy", kind: ShopSkill, price: 80, target: "decoy"},
+	{id: "skill:timeslow", kind: ShopSkill, price: 100, target: "timeslow"},
+	{id: "stat:health", kind: ShopStat, price: 40, maxLevel: 5},
+	{id: "stat:speed", kind: ShopStat, price: 60, maxLevel: 5},
+	{id: "stat:score", kind: ShopStat, price: 50, maxLevel: 5},
+}
+
+// Name 商品显示的名称，角色为角色的名称
+func (item *ShopItem) Name() string {
+	if item.kind == ShopCharacter {
+		return CharacterByID(item.target).DisplayName()
+	}
+	return T("shop." + item.id)
+}
+
+// Desc 商品显示的说明
+func (item *ShopItem) Desc() string {
+	if item.kind == ShopCharacter {
+		return T("shop.character.desc", CharacterByID(item.target).DisplayName())
+	}
+	return T("shop." + item.id + ".desc")
+}
+
+// Price 商品当前的价格
+func (item *ShopItem) Price(p *Profile) int {
+	if item.kind == ShopStat {
+		return item.price * (p.Levels[item.id] + 1)
+	}
+	return item.price
+}
+
+// Buy 购买商品，金币不足、已拥有或者已满级时购买失败
+func (p *Profile) Buy(item *ShopItem) error {
+	switch {
+	case item.kind == ShopStat && p.Levels[item.id] >= item.maxLevel:
+		return errMaxLevel
+	case item.kind != ShopStat && p.Unlocked[item.id]:
+		return errOwned
+	case p.Coins < item.Price(p):
+		return errNotEnoughCoins
+	}
+	p.Coins -= item.Price(p)
+	if item.kind == ShopStat {
+		p.Levels[item.id]++
+	} else {
+		p.Unlocked[item.id] = true
+	}
+	return nil
+}
+
+// SkillUnlocked 技能是否可以装备，不在商店中出售的技能默认解锁
+func (p *Profile) SkillUnlocked(name string) bool {
+	for _, item := range shopItems {
+		if item.kind == ShopSkill && item.target == name {
+			return p.Unlocked[item.id]
+		}
+	}
+	return true
+}
+
+// ApplyProfile 开局时应用档案中的永久属性加成以及开局武器
+func (p *Profile) ApplyProfile(player *Player) {
+	health := 10 * float64(p.Levels["stat:health"])
+	player.maxHealth += health
+	player.health += health
+	player.speed *= 1 + 0.05*float64(p.Levels["stat:speed"])
+	player.score += 10 * p.Levels["stat:score"]
+	if p.StartWeapon != "" && p.Unlocked["weapon:"+p.StartWeapon] {
+		player.weapon = NewWeapon(p.StartWeapon)
+		player.ApplyModifiers()
+	}
+}
+
+// resolveModeShop 上下键选择商品，空格键购买或者装备开局武器，Esc 键返回标题界面
+func (g *Game) resolveModeShop() {
+	if inpututil.IsKeyJustPressed(ebiten.KeyEscape) || inpututil.IsKeyJustPressed(ebiten.KeyBackspace) {
+		g.shopMessage = ""
+		g.init()
+		return
+	}
+	if inpututil.IsKeyJustPressed(ebiten.KeyArrowUp) {
+		g.shopCursor = (g.shopCursor + len(shopItems) - 1) % len(shopItems)
+		g.shopMessage = ""
+	}
+	if inpututil.IsKeyJustPressed(ebiten.KeyArrowDown) {
+		g.shopCursor = (g.shopCursor + 1) % len(shopItems)
+		g.shopMessage = ""
+	}
+	if !inpututil.IsKeyJustPressed(ebiten.KeySpace) {
+		return
+	}
+
+	item := shopItems[g.shopCursor]
+	// 已拥有的武器可以设置为开局武器，再次选择时取消
+	if item.kind == ShopWeapon && g.profile.Unlocked[item.id] {
+		if g.profile.StartWeapon == item.target {
+			g.profile.StartWeapon = ""
+		} else {
+			g.profile.StartWeapon = item.target
+		}
+	} else if err := g.profile.Buy(item); err != nil {
+		g.shopMessage = err.Error()
+		return
+	}
+	g.shopMessage = ""
+	if err := g.profile.Save(); err != nil {
+		log.Println("save profile:", err)
+	}
+}
+
+// shopItemStatus 商品在列表中显示的状态
+func (g *Game) shopItemStatus(item *ShopItem) string {
+	switch {
+	case item.kind == ShopStat && g.profile.Levels[item.id] >= item.maxLevel:
+		return T("shop.max")
+	case item.kind == ShopStat:
+		return T("shop.level", g.profile.Levels[item.id], item.Price(g.profile))
+	case item.kind == ShopWeapon && g.profile.StartWeapon == item.target:
+		return T("shop.equipped")
+	case g.profile.Unlocked[item.id]:
+		return T("shop.owned")
+	default:
+		return strconv.Itoa(item.Price(g.profile))
+	}
+}
+
+// DrawShop 绘制商店界面
+func DrawShop(screen *ebiten.Image, g *Game) {
+	face := arcadeFace(config.FontSize)
+
+	op := &text.DrawOptions{}
+	op.GeoM.Translate(config.ScreenWidth/2, 2*config.TitleFontSize)
+	op.ColorScale.ScaleWithColor(color.White)
+	op.PrimaryAlign = text.AlignCenter
+	text.Draw(screen, T("shop.title"), arcadeFace(config.TitleFontSize), op)
+
+	op = &text.DrawOptions{}
+	op.GeoM.Translate(config.ScreenWidth/2, 2*config.TitleFontSize+config.TitleFontSize+4)
+	op.ColorScale.ScaleWithColor(color.RGBA{0xFF, 0xE0, 0x30, 0xFF})
+	op.PrimaryAlign = text.AlignCenter
+	text.Draw(screen, T("shop.coins", g.profile.Coins), face, op)
+
+	const top, lineHeight = 56, 11
+	for i, item := range shopItems {
+		y := float64(top + i*lineHeight)
+		if i == g.shopCursor {
+			vector.DrawFilledRect(screen, 16, float32(y)-3, config.ScreenWidth-32, lineHeight, color.RGBA{0x30, 0x30, 0x60, 0xFF}, false)
+		}
+		op = &text.DrawOptions{}
+		op.GeoM.Translate(24, y)
+		op.ColorScale.ScaleWithColor(color.White)
+		text.Draw(screen, item.Name(), face, op)
+
+		op = &text.DrawOptions{}
+		op.GeoM.Translate(config.ScreenWidth-24, y)
+		op.ColorScale.ScaleWithColor(color.Gray{0xC0})
+		op.PrimaryAlign = text.AlignEnd
+		text.Draw(screen, g.shopItemStatus(item), face, op)
+	}
+
+	// 绘制选中商品的说明或者购买失败的提示
+	message := shopItems[g.shopCursor].Desc()
+	messageColor := color.Color(color.Gray{0xC0})
+	if g.shopMessage != "" {
+		message = T(g.shopMessage)
+		messageColor = color.RGBA{0xFF, 0x40, 0x40, 0xFF}
+	}
+	op = &text.DrawOptions{}
+	op.GeoM.Translate(config.ScreenWidth/2, config.ScreenHeight-3*config.FontSize)
+	op.ColorScale.ScaleWithColor(messageColor)
+	op.PrimaryAlign = text.AlignCenter
+	text.Draw(screen, message, face, op)
+
+	op = &text.DrawOptions{}
+	op.GeoM.Translate(config.ScreenWidth/2, config.ScreenHeight-1.5*config.FontSize)
+	op.ColorScale.ScaleWithColor(color.Gray{0x80})
+	op.PrimaryAlign = text.AlignCenter
+	text.Draw(screen, T("shop.hint"), arcadeFace(6), op)
+}
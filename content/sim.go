@@ -0,0 +1,329 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"runtime"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// simAxis 参数矩阵中的一个维度，每个取值都会与其他维度的取值组合
+type simAxis struct {
+	name   string // 参数名，如 monster_speed、ak.damage
+	values []float64
+	apply  func(b *Balance, v float64)
+}
+
+// weaponFlags 可以重复的 -weapon 参数，格式为 类型.属性=取值1,取值2
+type weaponFlags []string
+
+func (f *weaponFlags) String() string {
+	return strings.Join(*f, " ")
+}
+
+func (f *weaponFlags) Set(s string) error {
+	*f = append(*f, s)
+	return nil
+}
+
+// SimReport 一组参数下多局游戏的统计结果
+type SimReport struct {
+	Params      map[string]float64 `json:"params"`        // 参数矩阵中这一组的取值
+	Balance     *Balance           `json:"balance"`       // 对应的平衡参数
+	Games       int                `json:"games"`         // 模拟的局数
+	Deaths      int                `json:"deaths"`        // 在时间上限前死亡的局数
+	TimeToDeath Distribution       `json:"time_to_death"` // 死亡的局的存活时间（秒）
+	Survival    Distribution       `json:"survival"`      // 所有局的存活时间（秒），达到时间上限的局按上限计算
+	Score       Distribution       `json:"score"`
+	Kills       map[string]float64 `json:"kills"`    // 平均每局按武器类型统计的击杀数
+	Damage      map[string]float64 `json:"damage"`   // 平均每局按来源统计损失的生命值
+	Monsters    []float64          `json:"monsters"` // 每隔 interval 秒的平均怪物数量，只统计当时还在进行的局
+	Interval    int                `json:"interval"` // 怪物数量采样的间隔（秒）
+}
+
+// RunSim 按参数矩阵无界面运行电脑玩家游戏，比较不同平衡参数下的结果，输出 CSV 或 JSON，
+// 用法：avoid-the-enemies sim [-games 100] [-monster-speed 1,1.5] [-spawn-base 3] [-spawn-divisor 10,8]
+// [-skill-cost 1] [-weapon ak.damage=25,35] [-format csv|json] [-out file] [flags]
+func RunSim(args []string) {
+	fs := flag.NewFlagSet("sim", flag.ExitOnError)
+	games := fs.Int("games", 100, "games per parameter combination")
+	seed := fs.Int64("seed", 1, "seed of the first game, game i uses seed+i in every combination")
+	difficulty := fs.String("difficulty", "normal", "bot difficulty: easy, normal or hard")
+	characterID := fs.String("character", "", "character id, the first character when empty")
+	maxTime := fs.Duration("max-time", 10*time.Minute, "game time limit of each game, 0 for no limit")
+	workers := fs.Int("workers", runtime.NumCPU(), "games simulated in parallel")
+	monsterSpeed := fs.String("monster-speed", "1", "comma separated monster speed multipliers")
+	spawnBase := fs.String("spawn-base", strconv.Itoa(defaultBalance.SpawnBase), "comma separated base monster caps")
+	spawnDivisor := fs.String("spawn-divisor", strconv.Itoa(defaultBalance.SpawnDivisor), "comma separated ids per extra monster")
+	skillCost := fs.String("skill-cost", "1", "comma separated skill cost multipliers")
+	interval := fs.Duration("interval", 30*time.Second, "sampling interval of the monster count")
+	format := fs.String("format", "csv", "report format, csv or json")
+	out := fs.String("out", "", "report file, stdout when empty")
+	var weapons weaponFlags
+	fs.Var(&weapons, "weapon", "weapon stat values as type.stat=v1,v2 with stat spin, damage, speed or distance, repeatable")
+	fs.Parse(args)
+	profile := BotProfileByName(*difficulty)
+	if profile == nil {
+		log.Fatalf("unknown difficulty %q", *difficulty)
+	}
+	if *games < 1 || *workers < 1 || *interval < time.Second {
+		log.Fatal("games and workers must be positive and interval at least 1s")
+	}
+	if *format != "csv" && *format != "json" {
+		log.Fatalf("unknown format %q", *format)
+	}
+
+	headless = true
+	Init()
+	axes, err := simAxes(*monsterSpeed, *spawnBase, *spawnDivisor, *skillCost, weapons)
+	if err != nil {
+		log.Fatal(err)
+	}
+	character := CharacterByID(*characterID)
+	maxFrames := int(maxTime.Seconds() * 60)
+	combos := 1
+	for _, axis := range axes {
+		combos *= len(axis.values)
+	}
+
+	// 所有组合的所有局一起并行，每个组合使用同样的一组种子
+	balances := make([]*Balance, combos)
+	params := make([]map[string]float64, combos)
+	for c := range balances {
+		balances[c], params[c] = simCombo(axes, c)
+	}
+	results := make([]BotResult, combos**games)
+	parallel(len(results), *workers, func(i int) {
+		results[i] = RunBotGame(*seed+int64(i%*games), profile, character, maxFrames, balances[i / *games])
+	})
+
+	reports := make([]SimReport, combos)
+	for c := range reports {
+		reports[c] = NewSimReport(params[c], balances[c], results[c**games:(c+1)**games], int(interval.Seconds()))
+	}
+
+	w := io.Writer(os.Stdout)
+	if *out != "" {
+		f, err := os.Create(*out)
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer f.Close()
+		w = f
+	}
+	if *format == "json" {
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		err = encoder.Encode(reports)
+	} else {
+		err = WriteSimCSV(w, axes, reports)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+// simAxes 解析命令行参数得到参数矩阵的各个维度
+func simAxes(monsterSpeed, spawnBase, spawnDivisor, skillCost string, weapons []string) ([]simAxis, error) {
+	axes := []simAxis{
+		{name: "monster_speed", apply: func(b *Balance, v float64) { b.MonsterSpeed = v }},
+		{name: "spawn_base", apply: func(b *Balance, v float64) { b.SpawnBase = int(v) }},
+		{name: "spawn_divisor", apply: func(b *Balance, v float64) { b.SpawnDivisor = int(v) }},
+		{name: "skill_cost", apply: func(b *Balance, v float64) { b.SkillCost = v }},
+	}
+	for i, list := range []string{monsterSpeed, spawnBase, spawnDivisor, skillCost} {
+		values, err := parseFloats(list)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", axes[i].name, err)
+		}
+		axes[i].values = values
+	}
+	if slices.ContainsFunc(axes[2].values, func(v float64) bool { return v < 1 }) {
+		return nil, fmt.Errorf("spawn_divisor must be at least 1")
+	}
+	for _, spec := range weapons {
+		name, list, ok := strings.Cut(spec, "=")
+		typ, stat, ok2 := strings.Cut(name, ".")
+		if !ok || !ok2 {
+			return nil, fmt.Errorf("weapon %q: want type.stat=v1,v2", spec)
+		}
+		if NewWeapon(typ) == nil {
+			return nil, fmt.Errorf("weapon %q: unknown weapon type %q", spec, typ)
+		}
+		var set func(s *WeaponStats, v float64)
+		switch stat {
+		case "spin":
+			set = func(s *WeaponStats, v float64) { s.Spin = v }
+		case "damage":
+			set = func(s *WeaponStats, v float64) { s.Damage = v }
+		case "speed":
+			set = func(s *WeaponStats, v float64) { s.Speed = v }
+		case "distance":
+			set = func(s *WeaponStats, v float64) { s.Distance = v }
+		default:
+			return nil, fmt.Errorf("weapon %q: unknown stat %q", spec, stat)
+		}
+		values, err := parseFloats(list)
+		if err != nil {
+			return nil, fmt.Errorf("weapon %q: %w", spec, err)
+		}
+		axes = append(axes, simAxis{name: name, values: values, apply: func(b *Balance, v float64) {
+			stats := b.Weapons[typ]
+			set(&stats, v)
+			b.Weapons[typ] = stats
+		}})
+	}
+	return axes, nil
+}
+
+// simCombo 第 c 个参数组合，最后一个维度变化最快
+func simCombo(axes []simAxis, c int) (*Balance, map[string]float64) {
+	b := DefaultBalance()
+	b.Weapons = make(map[string]WeaponStats)
+	params := make(map[string]float64)
+	for i := len(axes) - 1; i >= 0; i-- {
+		axis := axes[i]
+		v := axis.values[c%len(axis.values)]
+		c /= len(axis.values)
+		axis.apply(b, v)
+		params[axis.name] = v
+	}
+	return b, params
+}
+
+func parseFloats(list string) ([]float64, error) {
+	var values []float64
+	for _, s := range strings.Split(list, ",") {
+		v, err := strconv.ParseFloat(strings.TrimSpace(s), 64)
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, v)
+	}
+	return values, nil
+}
+
+// NewSimReport 汇总一组参数下所有局的结果，interval 为怪物数量采样的间隔（秒）
+func NewSimReport(params map[string]float64, balance *Balance, results []BotResult, interval int) SimReport {
+	r := SimReport{
+		Params:   params,
+		Balance:  balance,
+		Games:    len(results),
+		Kills:    make(map[string]float64),
+		Damage:   make(map[string]float64),
+		Interval: interval,
+	}
+	var deaths, survival, score []float64
+	var samples []int
+	for _, result := range results {
+		survival = append(survival, result.Survival)
+		score = append(score, float64(result.Score))
+		if result.Died {
+			deaths = append(deaths, result.Survival)
+		}
+		for typ, kills := range result.Stats.Kills {
+			r.Kills[typ] += float64(kills) / float64(len(results))
+		}
+		for source, damage := range result.Stats.Damage {
+			r.Damage[source] += damage / float64(len(results))
+		}
+		for i := 0; i*interval < len(result.Stats.Monsters); i++ {
+			if i == len(r.Monsters) {
+				r.Monsters = append(r.Monsters, 0)
+				samples = append(samples, 0)
+			}
+			r.Monsters[i] += float64(result.Stats.Monsters[i*interval])
+			samples[i]++
+		}
+	}
+	for i := range r.Monsters {
+		r.Monsters[i] /= float64(samples[i])
+	}
+	r.Deaths = len(deaths)
+	r.TimeToDeath = NewDistribution(deaths)
+	r.Survival = NewDistribution(survival)
+	r.Score = NewDistribution(score)
+	return r
+}
+
+// WriteSimCSV 每个参数组合输出一行，击杀、伤害和怪物数量按所有组合中出现过的列展开
+func WriteSimCSV(w io.Writer, axes []simAxis, reports []SimReport) error {
+	kills := make(map[string]bool)
+	damage := make(map[string]bool)
+	samples := 0
+	for _, r := range reports {
+		for typ := range r.Kills {
+			kills[typ] = true
+		}
+		for source := range r.Damage {
+			damage[source] = true
+		}
+		samples = max(samples, len(r.Monsters))
+	}
+
+	var header []string
+	for _, axis := range axes {
+		header = append(header, axis.name)
+	}
+	header = append(header, "games", "deaths")
+	for _, name := range []string{"ttd", "survival", "score"} {
+		header = append(header, name+"_mean", name+"_p10", name+"_p50", name+"_p90")
+	}
+	for _, typ := range sortedKeys(kills) {
+		header = append(header, "kills_"+typ)
+	}
+	for _, source := range sortedKeys(damage) {
+		header = append(header, "damage_"+source)
+	}
+	interval := 0
+	if len(reports) > 0 {
+		interval = reports[0].Interval
+	}
+	for i := 0; i < samples; i++ {
+		header = append(header, fmt.Sprintf("monsters_%ds", i*interval))
+	}
+
+	cw := csv.NewWriter(w)
+	if err := cw.Write(header); err != nil {
+		return err
+	}
+	number := func(v float64) string {
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	}
+	for _, r := range reports {
+		var row []string
+		for _, axis := range axes {
+			row = append(row, number(r.Params[axis.name]))
+		}
+		row = append(row, strconv.Itoa(r.Games), strconv.Itoa(r.Deaths))
+		for _, d := range []Distribution{r.TimeToDeath, r.Survival, r.Score} {
+			row = append(row, fmt.Sprintf("%.2f", d.Mean), fmt.Sprintf("%.2f", d.P10), fmt.Sprintf("%.2f", d.P50), fmt.Sprintf("%.2f", d.P90))
+		}
+		for _, typ := range sortedKeys(kills) {
+			row = append(row, fmt.Sprintf("%.2f", r.Kills[typ]))
+		}
+		for _, source := range sortedKeys(damage) {
+			row = append(row, fmt.Sprintf("%.2f", r.Damage[source]))
+		}
+		for i := 0; i < samples; i++ {
+			if i < len(r.Monsters) {
+				row = append(row, fmt.Sprintf("%.2f", r.Monsters[i]))
+			} else {
+				row = append(row, "")
+			}
+		}
+		if err := cw.Write(row); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
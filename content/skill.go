@@ -0,0 +1,403 @@
+package main
+
+import (
+	"avoid-the-enemies/content/config"
+	"image"
+	"image/color"
+	"log"
+	"maps"
+	"math"
+	"slices"
+	"time"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/vector"
+	"golang.org/x/image/math/f64"
+
+	"avoid-the-enemies/content/utils"
+)
+
+var (
+	skillRegistry = make(map[string]func() Skill)          // 技能名称到技能构造函数的映射
+	skillNames    []string                                 // 按注册顺序排列的技能名称
+	skillKeys     = []ebiten.Key{ebiten.KeyQ, ebiten.KeyE} // 可以在标题界面切换技能的技能槽
+	// 角色专属技能的技能槽
+	signatureSkillKey = ebiten.KeyR
+)
+
+// Skill 主动技能，消耗积分释放，持续一段时间后进入冷却
+type Skill interface {
+	Name() string
+	Cost() int                            // 释放技能消耗的积分
+	Cooldown() time.Duration              // 冷却时间，从释放时开始计算
+	Duration() time.Duration              // 持续时间
+	Activate(g *Game, p *Player)          // 释放技能时调用一次
+	Update(g *Game, p *Player)            // 技能持续期间每帧调用
+	Deactivate(g *Game, p *Player)        // 技能结束时调用一次
+	Draw(screen *ebiten.Image, p *Player) // 技能持续期间绘制技能效果
+	Clone() Skill                         // 复制技能的全部状态，用于保存游戏状态
+}
+
+// RegisterSkill 注册技能，同名技能会被覆盖
+func RegisterSkill(name string, newSkill func() Skill) {
+	if _, ok := skillRegistry[name]; !ok {
+		skillNames = append(skillNames, name)
+	}
+	skillRegistry[name] = newSkill
+}
+
+// NewSkill 根据名称创建技能，技能不存在时返回 nil
+func NewSkill(name string) Skill {
+	newSkill, ok := skillRegistry[name]
+	if !ok {
+		return nil
+	}
+	return newSkill()
+}
+
+// NextSkillName 返回注册顺序中 name 之后下一个可以装备的技能名称，没有其他可以装备的技能时返回 name
+func NextSkillName(name string, unlocked func(name string) bool) string {
+	start := 0
+	for i, n := range skillNames {
+		if n == name {
+			start = i
+		}
+	}
+	for i := 1; i <= len(skillNames); i++ {
+		n := skillNames[(start+i)%len(skillNames)]
+		if unlocked(n) {
+			return n
+		}
+	}
+	return name
+}
+
+// skillEquippable 技能槽 slot 是否可以装备技能 name：技能已经解锁，没有装备在其他技能槽上，也不是任何一名玩家的专属技能。
+// 同一个技能装备在两个技能槽上时两个技能槽分别冷却，交替释放可以让技能一直生效
+func (g *Game) skillEquippable(slot int, name string) bool {
+	if !g.profile.SkillUnlocked(name) {
+		return false
+	}
+	for i, equipped := range g.equippedSkills {
+		if i != slot && equipped == name {
+			return false
+		}
+	}
+	return !slices.ContainsFunc(g.players, func(p *Player) bool { return p.character.Skill == name })
+}
+
+// equipSkills 按 equippedSkills 重新创建玩家的技能槽，专属技能已经装备在其他技能槽上时不再占用 signatureSkillKey
+func (g *Game) equipSkills(p *Player) {
+	p.skills = nil
+	for _, name := range g.equippedSkills {
+		p.skills = append(p.skills, NewSkillSlot(name))
+	}
+	if !slices.Contains(g.equippedSkills, p.character.Skill) {
+		p.skills = append(p.skills, NewSkillSlot(p.character.Skill))
+	}
+}
+
+func InitSkill() {
+	RegisterSkill("invincible", func() Skill { return &InvincibleSkill{} })
+	RegisterSkill("dash", func() Skill { return &DashSkill{} })
+	RegisterSkill("shockwave", func() Skill { return &ShockwaveSkill{} })
+	RegisterSkill("timeslow", func() Skill { return &TimeSlowSkill{} })
+	RegisterSkill("decoy", func() Skill { return &DecoySkill{} })
+}
+
+// SkillSlot 玩家装备的技能槽，释放技能的按键由玩家的操作来源决定
+type SkillSlot struct {
+	skill    Skill
+	lastTime time.Time // 上次释放技能的时间
+	active   bool      // 技能是否正在生效
+}
+
+func NewSkillSlot(name string) *SkillSlot {
+	return &SkillSlot{
+		skill: NewSkill(name),
+	}
+}
+
+// Cooldown 考虑升级加成之后的冷却时间
+func (s *SkillSlot) Cooldown(p *Player) time.Duration {
+	return time.Duration(float64(s.skill.Cooldown()) * (1 - p.mods.cooldownReduction))
+}
+
+// Ready 技能是否已经冷却完毕
+func (s *SkillSlot) Ready(p *Player) bool {
+	return p.clock.Since(s.lastTime) > s.Cooldown(p)
+}
+
+// CooldownProgress 冷却进度，0 表示刚释放，1 表示冷却完毕
+func (s *SkillSlot) CooldownProgress(p *Player) float64 {
+	if s.Ready(p) {
+		return 1
+	}
+	return float64(p.clock.Since(s.lastTime)) / float64(s.Cooldown(p))
+}
+
+// Use 尝试释放技能，积分不足或者正在冷却时释放失败
+func (s *SkillSlot) Use(g *Game, p *Player) bool {
+	if s.active || !s.Ready(p) || p.score < g.skillCost(s.skill) {
+		return false
+	}
+	p.score -= g.skillCost(s.skill)
+	s.lastTime = p.clock.Now()
+	s.active = true
+	s.skill.Activate(g, p)
+	return true
+}
+
+// activeSkill 任意一名玩家正在生效的名为 name 的技能，没有时返回 nil。
+// 时间流速、诱饵等全局状态由多名玩家的技能共用，技能结束时用来判断是否还有其他玩家的同一个技能在生效
+func (g *Game) activeSkill(name string) Skill {
+	for _, p := range g.players {
+		for _, slot := range p.skills {
+			if slot.active && slot.skill.Name() == name {
+				return slot.skill
+			}
+		}
+	}
+	return nil
+}
+
+// Update 更新生效中的技能，持续时间结束后关闭技能
+func (s *SkillSlot) Update(g *Game, p *Player) {
+	if !s.active {
+		return
+	}
+	if p.clock.Since(s.lastTime) > s.skill.Duration() {
+		s.active = false
+		s.skill.Deactivate(g, p)
+		return
+	}
+	s.skill.Update(g, p)
+}
+
+// skillBase 记录技能动画的帧数，并提供空的钩子实现
+type skillBase struct {
+	frame int // 技能动画的帧数
+}
+
+func (s *skillBase) Update(g *Game, p *Player) {
+	s.frame++
+}
+
+func (s *skillBase) Deactivate(g *Game, p *Player) {}
+
+func (s *skillBase) Draw(screen *ebiten.Image, p *Player) {}
+
+// InvincibleSkill 无敌：一段时间内不受伤害
+type InvincibleSkill struct {
+	skillBase
+}
+
+func (s *InvincibleSkill) Name() string            { return "invincible" }
+func (s *InvincibleSkill) Cost() int               { return 20 }
+func (s *InvincibleSkill) Cooldown() time.Duration { return time.Second * 5 }
+func (s *InvincibleSkill) Duration() time.Duration { return time.Second * 3 }
+
+func (s *InvincibleSkill) Clone() Skill { c := *s; return &c }
+
+func (s *InvincibleSkill) Activate(g *Game, p *Player) {
+	s.frame = 0
+	p.invincibleUntil = g.clock.Now().Add(s.Duration())
+	g.chromaEffect()
+}
+
+func (s *InvincibleSkill) Draw(screen *ebiten.Image, p *Player) {
+	op := &ebiten.DrawImageOptions{}
+	// 位于血条上方，血条高度为 5
+	op.GeoM.Translate(p.x-16, p.y-5-16)
+	i := (s.frame / 5) % 4
+	sx, sy := i*64, 0
+	screen.DrawImage(fireImage.Sub(image.Rect(sx, sy, sx+64, sy+64)), op)
+}
+
+// DashSkill 冲刺：立即恢复全部冲刺次数并沿人物朝向冲刺
+type DashSkill struct {
+	skillBase
+}
+
+func (s *DashSkill) Name() string            { return "dash" }
+func (s *DashSkill) Cost() int               { return 5 }
+func (s *DashSkill) Cooldown() time.Duration { return time.Second * 2 }
+func (s *DashSkill) Duration() time.Duration { return config.DashInvincible * time.Millisecond }
+
+func (s *DashSkill) Clone() Skill { c := *s; return &c }
+
+func (s *DashSkill) Activate(g *Game, p *Player) {
+	p.dashCharges = config.DashMaxCharges
+	p.dashTime = time.Time{}
+	p.Dash(0, 0)
+}
+
+// ShockwaveSkill 冲击波：将附近的怪物击退并眩晕
+type ShockwaveSkill struct {
+	skillBase
+	pushed map[int]f64.Vec2 // 被击退的怪物以及击退方向
+}
+
+const (
+	shockwaveRadius = 80  // 冲击波的作用半径
+	shockwaveForce  = 3.0 // 每帧击退的距离
+)
+
+func (s *ShockwaveSkill) Name() string            { return "shockwave" }
+func (s *ShockwaveSkill) Cost() int               { return 10 }
+func (s *ShockwaveSkill) Cooldown() time.Duration { return time.Second * 4 }
+func (s *ShockwaveSkill) Duration() time.Duration { return time.Millisecond * 500 }
+
+func (s *ShockwaveSkill) Clone() Skill {
+	c := *s
+	c.pushed = maps.Clone(s.pushed)
+	return &c
+}
+
+func (s *ShockwaveSkill) Activate(g *Game, p *Player) {
+	s.frame = 0
+	s.pushed = make(map[int]f64.Vec2)
+	if err := g.playSound(boomSound, p.x+config.FrameWidth/2, p.y+config.FrameHeight/2); err != nil {
+		log.Println("play shockwave sound:", err)
+	}
+	for id, monster := range g.monsters {
+		if utils.GetDistance(p.x, p.y, monster.x, monster.y) > shockwaveRadius {
+			continue
+		}
+		dx, dy := monster.x-p.x, monster.y-p.y
+		if dx == 0 && dy == 0 {
+			dx = 1
+		}
+		dx, dy = utils.Normal(dx, dy)
+		s.pushed[id] = f64.Vec2{dx, dy}
+		monster.ApplyStatus(StatusStun)
+	}
+}
+
+func (s *ShockwaveSkill) Update(g *Game, p *Player) {
+	s.frame++
+	for id, direction := range s.pushed {
+		monster, ok := g.monsters[id]
+		if !ok {
+			delete(s.pushed, id)
+			continue
+		}
+		monster.Move(direction[0]*shockwaveForce, direction[1]*shockwaveForce)
+	}
+}
+
+func (s *ShockwaveSkill) Draw(screen *ebiten.Image, p *Player) {
+	// 冲击波的光圈随时间扩大
+	progress := float64(s.frame) / (s.Duration().Seconds() * 60)
+	radius := float32(shockwaveRadius * math.Min(progress, 1))
+	cx, cy := float32(p.x+config.FrameWidth/2), float32(p.y+config.FrameHeight/2)
+	vector.StrokeCircle(screen, cx, cy, radius, 2, color.RGBA{0x80, 0xC0, 0xFF, 0xC0}, true)
+
+	op := &ebiten.DrawImageOptions{}
+	op.GeoM.Translate(p.x+config.FrameWidth/2-24, p.y+config.FrameHeight/2-18)
+	i := (s.frame * 3) % 89
+	sx, sy := i*48, 0
+	screen.DrawImage(skillImage.Sub(image.Rect(sx, sy, sx+48, sy+36)), op)
+}
+
+// TimeSlowSkill 时间减缓：怪物与怪物的子弹变慢
+type TimeSlowSkill struct {
+	skillBase
+}
+
+func (s *TimeSlowSkill) Name() string            { return "timeslow" }
+func (s *TimeSlowSkill) Cost() int               { return 15 }
+func (s *TimeSlowSkill) Cooldown() time.Duration { return time.Second * 8 }
+func (s *TimeSlowSkill) Duration() time.Duration { return time.Second * 4 }
+
+func (s *TimeSlowSkill) Clone() Skill { c := *s; return &c }
+
+func (s *TimeSlowSkill) Activate(g *Game, p *Player) {
+	g.timeScale = 0.3
+}
+
+func (s *TimeSlowSkill) Deactivate(g *Game, p *Player) {
+	// 其他玩家的时间减缓还在生效时保持减缓
+	if g.activeSkill("timeslow") == nil {
+		g.timeScale = 1
+	}
+}
+
+func (s *TimeSlowSkill) Draw(screen *ebiten.Image, p *Player) {
+	vector.DrawFilledRect(screen, 0, 0, config.ScreenWidth, config.ScreenHeight, color.RGBA{0x00, 0x20, 0x40, 0x40}, false)
+}
+
+// DecoySkill 诱饵：在原地留下一个替身，吸引怪物前往
+type DecoySkill struct {
+	skillBase
+	pos f64.Vec2 // 诱饵的位置
+}
+
+func (s *DecoySkill) Name() string            { return "decoy" }
+func (s *DecoySkill) Cost() int               { return 10 }
+func (s *DecoySkill) Cooldown() time.Duration { return time.Second * 10 }
+func (s *DecoySkill) Duration() time.Duration { return time.Second * 4 }
+
+func (s *DecoySkill) Clone() Skill { c := *s; return &c }
+
+func (s *DecoySkill) Activate(g *Game, p *Player) {
+	s.pos = f64.Vec2{p.x, p.y}
+	g.decoy = &s.pos
+}
+
+func (s *DecoySkill) Deactivate(g *Game, p *Player) {
+	if g.decoy != &s.pos {
+		return
+	}
+	// 其他玩家的诱饵还在生效时怪物改为前往那个诱饵
+	g.decoy = nil
+	if other, ok := g.activeSkill("decoy").(*DecoySkill); ok {
+		g.decoy = &other.pos
+	}
+}
+
+func (s *DecoySkill) Draw(screen *ebiten.Image, p *Player) {
+	op := &ebiten.DrawImageOptions{}
+	op.GeoM.Translate(s.pos[0], s.pos[1])
+	op.ColorScale.ScaleAlpha(0.5)
+	DrawCharacter(screen, p, op)
+}
+
+// skillReadyFlash 技能冷却完毕后技能槽闪烁的时间
+const skillReadyFlash = 300 * time.Millisecond
+
+// DrawSkillHUD 在屏幕底部从 left 开始绘制技能槽以及装备的武器。冷却中的技能槽被顺时针扫过的扇形遮住，
+// 冷却完毕时闪烁一下；边框黄色表示可以释放，灰色表示正在冷却，红色表示积分不足
+func DrawSkillHUD(screen *ebiten.Image, g *Game, p *Player, left float64) {
+	const size = 20
+	for i, slot := range p.skills {
+		x := left + float64(i*(size+3))
+		hud.Place(screen, AnchorBottomLeft, x, -3, &Icon{Width: size, Height: size, Back: color.RGBA{0x40, 0x40, 0x40, 0xFF}})
+		hud.Place(screen, AnchorBottomLeft, x+1, -4, &Radial{Radius: size/2 - 1, Progress: slot.CooldownProgress(p), Color: color.RGBA{0x00, 0x00, 0x00, 0xC0}})
+
+		affordable := p.score >= g.skillCost(slot.skill)
+		border := color.RGBA{0xFF, 0xE0, 0x30, 0xFF}
+		switch {
+		case !affordable:
+			border = color.RGBA{0xFF, 0x00, 0x00, 0xFF}
+		case !slot.Ready(p):
+			border = color.RGBA{0x80, 0x80, 0x80, 0xFF}
+		}
+		if since := p.clock.Since(slot.lastTime) - slot.Cooldown(p); affordable && since >= 0 && since < skillReadyFlash {
+			flash := fade(color.RGBA{0xFF, 0xFF, 0xFF, 0xFF}, 0.6*(1-float64(since)/float64(skillReadyFlash)))
+			hud.Place(screen, AnchorBottomLeft, x, -3, &Icon{Width: size, Height: size, Back: flash})
+		}
+		hud.Place(screen, AnchorBottomLeft, x, -3, &Icon{Width: size, Height: size, Border: border})
+
+		label := &Label{Text: p.input.SkillLabel(i), FontSize: config.FontSize, Color: color.RGBA{0xFF, 0xFF, 0xFF, 0xFF}}
+		w, h := label.Size()
+		hud.Place(screen, AnchorBottomLeft, x+(size-w)/2, -3-(size-h)/2, label)
+	}
+
+	// 技能槽右侧绘制装备的武器，空手时只绘制空的格子
+	weapon := &Icon{Width: size, Height: size, Back: color.RGBA{0x20, 0x20, 0x20, 0xFF}, Border: color.RGBA{0x80, 0x80, 0x80, 0xFF}}
+	if p.weapon != nil {
+		weapon.Image = p.weapon.GetImage().Sub(frameRect)
+	}
+	hud.Place(screen, AnchorBottomLeft, left+float64(len(p.skills)*(size+3)), -3, weapon)
+}
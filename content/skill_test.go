@@ -0,0 +1,71 @@
+package main
+
+import (
+	"testing"
+)
+
+// TestSkillSlotsUnique 每个角色的技能槽中没有重复的技能，标题界面切换技能时也不会切换到已经装备的技能
+func TestSkillSlotsUnique(t *testing.T) {
+	for _, c := range characters {
+		g := &Game{profile: NewProfile(""), seats: []Seat{{input: &frameInput{}, character: c}}}
+		g.init()
+		p := g.players[0]
+		for round := 0; ; round++ {
+			seen := make(map[string]bool)
+			for _, slot := range p.skills {
+				if seen[slot.skill.Name()] {
+					t.Fatalf("%s equips %s twice: %v", c.ID, slot.skill.Name(), g.equippedSkills)
+				}
+				seen[slot.skill.Name()] = true
+			}
+			if !seen[c.Skill] {
+				t.Fatalf("%s lost its signature skill %s: %v", c.ID, c.Skill, g.equippedSkills)
+			}
+			if round == len(skillNames) {
+				break
+			}
+			// 与标题界面按技能键相同
+			i := round % len(skillKeys)
+			g.equippedSkills[i] = NextSkillName(g.equippedSkills[i], func(name string) bool { return g.skillEquippable(i, name) })
+			g.equipSkills(p)
+		}
+	}
+}
+
+// TestSharedSkillsOverlap 一名玩家的时间减缓和诱饵结束时，另一名玩家还在生效的同一个技能不受影响
+func TestSharedSkillsOverlap(t *testing.T) {
+	g := &Game{profile: NewProfile(""), equippedSkills: []string{"timeslow", "decoy"}}
+	for i := 0; i < 2; i++ {
+		g.seats = append(g.seats, Seat{input: &frameInput{}, character: characters[0]})
+	}
+	g.init()
+	first, second := g.players[0], g.players[1]
+	for f := 0; f <= 400; f++ {
+		for i, p := range g.players {
+			if f == 120*i {
+				p.score = 100
+				for _, slot := range p.skills[:2] {
+					slot.Use(g, p)
+				}
+			}
+			for _, slot := range p.skills {
+				slot.Update(g, p)
+			}
+		}
+		switch f {
+		case 60:
+			if g.timeScale == 1 || g.decoy != &first.skills[1].skill.(*DecoySkill).pos {
+				t.Fatalf("frame %d: time scale %v, decoy %p, want the first player's skills", f, g.timeScale, g.decoy)
+			}
+		case 300: // 第一名玩家的技能已经结束
+			if g.timeScale == 1 || g.decoy != &second.skills[1].skill.(*DecoySkill).pos {
+				t.Fatalf("frame %d: time scale %v, decoy %p, want the second player's skills", f, g.timeScale, g.decoy)
+			}
+		case 400:
+			if g.timeScale != 1 || g.decoy != nil {
+				t.Fatalf("frame %d: time scale %v, decoy %p, want both skills over", f, g.timeScale, g.decoy)
+			}
+		}
+		g.clock.Tick()
+	}
+}
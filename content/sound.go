@@ -0,0 +1,238 @@
+package main
+
+import (
+	"avoid-the-enemies/content/config"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"path"
+	"sync/atomic"
+
+	"github.com/hajimehoshi/ebiten/v2/audio"
+	"github.com/hajimehoshi/ebiten/v2/audio/mp3"
+	"github.com/hajimehoshi/ebiten/v2/audio/vorbis"
+	"github.com/hajimehoshi/ebiten/v2/audio/wav"
+)
+
+// sampleRate 音频的采样率，所有音效和音乐都重采样到这个采样率
+const sampleRate = 48000
+
+const (
+	soundNear    = 48                 // 与听者的距离小于这个距离时音量不衰减
+	soundFar     = config.ScreenWidth // 与听者的距离达到这个距离时音量衰减到 soundMinGain
+	soundMinGain = 0.15               // 远处的声音最低的音量比例
+	soundMaxPan  = 0.8                // 屏幕边缘的声音的声像，另一侧仍然能听到一些
+)
+
+// Bus 音量总线，音乐和音效的最终音量为所在总线的音量乘以主音量
+type Bus int
+
+const (
+	BusMaster Bus = iota
+	BusMusic
+	BusSFX
+)
+
+// busVolumes 按 Bus 排列的音量，取值为 0 到 1，由设置中的音量决定
+var busVolumes = [3]float64{1, 1, 1}
+
+var (
+	shotSound = &Sound{file: "audio/shot.mp3", maxVoices: 4} // 射击音效
+	hitSound  = &Sound{file: "audio/jab.wav", maxVoices: 4}  // 击中音效
+	dashSound = &Sound{file: "audio/jump.ogg", maxVoices: 2} // 冲刺音效
+	boomSound = &Sound{file: "audio/shot.mp3", maxVoices: 2} // 冲击波的爆炸音效
+)
+
+// sounds 所有音效，按顺序加载
+var sounds = []*Sound{shotSound, hitSound, dashSound, boomSound}
+
+// Sound 一个音效，解码后的数据由多个声部共享，同时播放的声部数量不超过 maxVoices。
+// 重新加载时替换其中的数据和声部，持有它的武器不需要改变
+type Sound struct {
+	file      string // 相对于 resources 的路径
+	maxVoices int    // 同时播放的声部数量上限，超过时打断播放时间最长的声部
+	pcm       []byte // 解码后的数据
+	voices    []*voice
+}
+
+// voice 音效的一个声部，每个声部有自己的声像
+type voice struct {
+	player *audio.Player
+	stream *panStream
+}
+
+func InitSound() {
+	if err := loadSounds(); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// SetBusVolume 设置总线的音量，v 取值为 0 到 1
+func SetBusVolume(bus Bus, v float64) {
+	busVolumes[bus] = min(max(v, 0), 1)
+}
+
+// BusVolume 总线的最终音量，主音量之外的总线乘以主音量
+func BusVolume(bus Bus) float64 {
+	if bus == BusMaster {
+		return busVolumes[BusMaster]
+	}
+	return busVolumes[BusMaster] * busVolumes[bus]
+}
+
+// loadSounds 解码所有音效，无界面运行时不创建音效。任何一个音效加载失败时都保留原来的音效
+func loadSounds() error {
+	if headless {
+		return nil
+	}
+	initAudioContext()
+	pcm := make([][]byte, len(sounds))
+	for i, s := range sounds {
+		raw, err := ReadAsset(s.file)
+		if err != nil {
+			return err
+		}
+		stream, err := decodeAudio(s.file, raw)
+		if err != nil {
+			return fmt.Errorf("%s: %w", s.file, err)
+		}
+		if pcm[i], err = io.ReadAll(stream); err != nil {
+			return fmt.Errorf("%s: %w", s.file, err)
+		}
+	}
+	for i, s := range sounds {
+		for _, v := range s.voices {
+			v.player.Close()
+		}
+		s.pcm, s.voices = pcm[i], nil
+	}
+	return nil
+}
+
+// initAudioContext 创建全局唯一的音频上下文
+func initAudioContext() {
+	if audioContext == nil {
+		audioContext = audio.NewContext(sampleRate)
+	}
+}
+
+// decodeAudio 按扩展名解码音频文件并重采样到 sampleRate
+func decodeAudio(file string, raw []byte) (audioStream, error) {
+	switch path.Ext(file) {
+	case ".mp3":
+		return mp3.DecodeWithSampleRate(sampleRate, bytes.NewReader(raw))
+	case ".wav":
+		return wav.DecodeWithSampleRate(sampleRate, bytes.NewReader(raw))
+	case ".ogg":
+		return vorbis.DecodeWithSampleRate(sampleRate, bytes.NewReader(raw))
+	}
+	return nil, fmt.Errorf("unsupported audio format")
+}
+
+// audioStream 解码后的音频流，长度用于循环播放
+type audioStream interface {
+	io.ReadSeeker
+	Length() int64
+}
+
+// Play 以 SFX 总线的音量从头播放音效，声音位于正中，无界面运行时没有音效
+func (s *Sound) Play() error {
+	return s.PlayAt(1, 0)
+}
+
+// PlayAt 以 SFX 总线音量的 gain 倍播放音效，pan 为声像，-1 为最左、1 为最右。
+// 优先使用空闲的声部，没有空闲的声部时新建声部，声部数量达到上限时打断播放时间最长的声部
+func (s *Sound) PlayAt(gain, pan float64) error {
+	if s.pcm == nil {
+		return nil
+	}
+	var v *voice
+	for _, sv := range s.voices {
+		if !sv.player.IsPlaying() {
+			v = sv
+			break
+		}
+	}
+	if v == nil && len(s.voices) < s.maxVoices {
+		stream := &panStream{ReadSeeker: bytes.NewReader(s.pcm)}
+		player, err := audioContext.NewPlayer(stream)
+		if err != nil {
+			return err
+		}
+		v = &voice{player: player, stream: stream}
+		s.voices = append(s.voices, v)
+	}
+	if v == nil {
+		v = s.voices[0]
+		for _, sv := range s.voices[1:] {
+			if sv.player.Position() > v.player.Position() {
+				v = sv
+			}
+		}
+	}
+	v.stream.SetPan(pan)
+	if err := v.player.Rewind(); err != nil {
+		return err
+	}
+	v.player.SetVolume(gain * BusVolume(BusSFX))
+	v.player.Play()
+	return nil
+}
+
+// panStream 按声像调整左右声道音量的 16 位立体声音频流，声像在播放器的协程中读取
+type panStream struct {
+	io.ReadSeeker
+	pan atomic.Uint64 // float64 的位
+}
+
+func (s *panStream) SetPan(pan float64) {
+	s.pan.Store(math.Float64bits(min(max(pan, -1), 1)))
+}
+
+// Read 声像偏左时降低右声道的音量，偏右时降低左声道的音量
+func (s *panStream) Read(p []byte) (int, error) {
+	n, err := s.ReadSeeker.Read(p)
+	pan := math.Float64frombits(s.pan.Load())
+	if pan == 0 {
+		return n, err
+	}
+	left, right := min(1-pan, 1), min(1+pan, 1)
+	for i := 0; i+4 <= n; i += 4 {
+		l := float64(int16(binary.LittleEndian.Uint16(p[i:])))
+		r := float64(int16(binary.LittleEndian.Uint16(p[i+2:])))
+		binary.LittleEndian.PutUint16(p[i:], uint16(int16(l*left)))
+		binary.LittleEndian.PutUint16(p[i+2:], uint16(int16(r*right)))
+	}
+	return n, err
+}
+
+// spatialize 位于 (x, y) 的声音对于位于 (lx, ly) 的听者的音量比例和声像，
+// 音量在 soundNear 到 soundFar 之间随距离线性衰减，声像按水平距离计算
+func spatialize(x, y, lx, ly float64) (gain, pan float64) {
+	d := math.Hypot(x-lx, y-ly)
+	t := min(max((d-soundNear)/(soundFar-soundNear), 0), 1)
+	gain = 1 - t*(1-soundMinGain)
+	pan = min(max((x-lx)/(config.ScreenWidth/2), -1), 1) * soundMaxPan
+	return gain, pan
+}
+
+// listener 听者的位置：单人游戏时为玩家的中心，多名玩家时为屏幕中心
+func (g *Game) listener() (float64, float64) {
+	if len(g.players) == 1 {
+		p := g.players[0]
+		return p.x + config.FrameWidth/2, p.y + config.FrameHeight/2
+	}
+	return config.ScreenWidth / 2, config.ScreenHeight / 2
+}
+
+// playSound 在 (x, y) 播放音效，按与听者的距离和方向调整音量和声像，回滚重新模拟时没有音效
+func (g *Game) playSound(s *Sound, x, y float64) error {
+	if g.resimulating {
+		return nil
+	}
+	lx, ly := g.listener()
+	return s.PlayAt(spatialize(x, y, lx, ly))
+}
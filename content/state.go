@@ -0,0 +1,200 @@
+package main
+
+import (
+	"encoding/binary"
+	"hash/fnv"
+	"maps"
+	"math"
+	"slices"
+)
+
+// GameState 某一帧的完整模拟状态，回滚时保存和恢复
+// 保存时深拷贝模拟中会改变的数据，图片、音效以及角色、强化等静态数据仍然共享
+type GameState struct {
+	clock Clock
+	game  Game // 只使用 copySimState 复制的字段
+}
+
+// SaveState 保存当前的模拟状态
+func (g *Game) SaveState() *GameState {
+	s := &GameState{clock: *g.clock}
+	s.game.clock = g.clock
+	copySimState(&s.game, g)
+	return s
+}
+
+// LoadState 恢复保存的模拟状态，同一个状态可以多次恢复
+func (g *Game) LoadState(s *GameState) {
+	*g.clock = s.clock
+	copySimState(g, &s.game)
+}
+
+// copySimState 将 src 的模拟状态深拷贝到 dst，人物的时钟指向 dst 的时钟
+func copySimState(dst, src *Game) {
+	dst.mode = src.mode
+	dst.rng = src.rng
+	dst.startTime = src.startTime
+	dst.uniqueId = src.uniqueId
+	dst.weaponTimer = src.weaponTimer
+	dst.hazardTimer = src.hazardTimer
+	dst.pickupTimer = src.pickupTimer
+	dst.timeScale = src.timeScale
+	dst.upgradeChoices = slices.Clone(src.upgradeChoices)
+	dst.upgradeCursor = src.upgradeCursor
+	dst.coinsEarned = src.coinsEarned
+	dst.round = src.round
+	dst.roundWins = maps.Clone(src.roundWins)
+	dst.roundWinner = src.roundWinner
+	dst.roundOverFrames = src.roundOverFrames
+
+	dst.players = make([]*Player, len(src.players))
+	for i, p := range src.players {
+		dst.players[i] = p.clone(dst.clock)
+	}
+	dst.monsters = make(map[int]*Player, len(src.monsters))
+	for id, monster := range src.monsters {
+		dst.monsters[id] = monster.clone(dst.clock)
+	}
+	// 怪物追逐的玩家指向复制后的玩家
+	for _, monster := range dst.monsters {
+		if monster.target != nil {
+			monster.target = dst.playerByID(monster.target.id)
+		}
+	}
+	dst.levelUpPlayer = nil
+	if src.levelUpPlayer != nil {
+		dst.levelUpPlayer = dst.playerByID(src.levelUpPlayer.id)
+	}
+
+	dst.monsterTarget = maps.Clone(src.monsterTarget)
+	dst.monsterTimer = maps.Clone(src.monsterTimer)
+	dst.weaponPosition = maps.Clone(src.weaponPosition)
+	dst.weaponPositionBeenPicked = maps.Clone(src.weaponPositionBeenPicked)
+	dst.weapons = make(map[int]Weapon, len(src.weapons))
+	for id, weapon := range src.weapons {
+		dst.weapons[id] = weapon.Clone()
+	}
+	// 子弹只读取武器的速度、射程等固定属性，可以继续共享开火的武器
+	dst.suspends = make(map[int]*Suspend, len(src.suspends))
+	for id, s := range src.suspends {
+		c := *s
+		dst.suspends[id] = &c
+	}
+	dst.hazards = make(map[int]*Hazard, len(src.hazards))
+	for id, h := range src.hazards {
+		c := *h
+		dst.hazards[id] = &c
+	}
+	dst.pickups = make(map[int]*Pickup, len(src.pickups))
+	for id, pickup := range src.pickups {
+		c := *pickup
+		dst.pickups[id] = &c
+	}
+
+	// 诱饵的位置保存在诱饵技能中，指向复制后的技能
+	dst.decoy = nil
+	if src.decoy != nil {
+		decoy := *src.decoy
+		dst.decoy = &decoy
+		for i, p := range src.players {
+			for j, slot := range p.skills {
+				if skill, ok := slot.skill.(*DecoySkill); ok && &skill.pos == src.decoy {
+					dst.decoy = &dst.players[i].skills[j].skill.(*DecoySkill).pos
+				}
+			}
+		}
+	}
+}
+
+// clone 深拷贝人物，包括武器、技能和状态效果
+func (p *Player) clone(clock *Clock) *Player {
+	c := *p
+	c.clock = clock
+	if p.weapon != nil {
+		c.weapon = p.weapon.Clone()
+	}
+	c.skills = make([]*SkillSlot, len(p.skills))
+	for i, slot := range p.skills {
+		s := *slot
+		if slot.skill != nil {
+			s.skill = slot.skill.Clone()
+		}
+		c.skills[i] = &s
+	}
+	c.statuses = make(map[StatusKind]*Status, len(p.statuses))
+	for kind, status := range p.statuses {
+		s := *status
+		c.statuses[kind] = &s
+	}
+	return &c
+}
+
+// Checksum 模拟状态的校验值，双方的校验值不同说明模拟结果已经不一致
+func (g *Game) Checksum() uint32 {
+	var b []byte
+	u := func(v uint64) { b = binary.LittleEndian.AppendUint64(b, v) }
+	f := func(v float64) { u(math.Float64bits(v)) }
+	person := func(p *Player) {
+		u(uint64(p.id))
+		f(p.x)
+		f(p.y)
+		f(p.health)
+		u(uint64(p.score))
+		if p.weapon != nil {
+			b = append(b, p.weapon.GetType()...)
+		}
+		if weapon, ok := p.weapon.(*MeleeWeapon); ok {
+			f(weapon.angle)
+		}
+		f(p.shield)
+		for _, kind := range sortedKeys(p.statuses) {
+			s := p.statuses[kind]
+			u(uint64(s.kind))
+			u(uint64(s.remaining))
+			u(uint64(s.stacks))
+		}
+	}
+
+	u(uint64(g.mode))
+	u(uint64(g.clock.Now().UnixNano()))
+	u(g.rng.state)
+	u(uint64(g.uniqueId))
+	u(uint64(g.round))
+	for _, p := range g.players {
+		person(p)
+		u(uint64(p.xp))
+		u(uint64(p.level))
+		f(p.dashCharges)
+		if p.downed {
+			u(1)
+		}
+	}
+	for _, id := range sortedKeys(g.monsters) {
+		person(g.monsters[id])
+	}
+	for _, id := range sortedKeys(g.suspends) {
+		s := g.suspends[id]
+		u(uint64(id))
+		f(s.pos[0])
+		f(s.pos[1])
+	}
+	for _, id := range sortedKeys(g.weapons) {
+		u(uint64(id))
+		b = append(b, g.weapons[id].GetType()...)
+	}
+	for _, id := range sortedKeys(g.pickups) {
+		pickup := g.pickups[id]
+		u(uint64(id))
+		u(uint64(pickup.kind))
+		f(pickup.pos[0])
+		f(pickup.pos[1])
+	}
+	for _, id := range sortedKeys(g.hazards) {
+		u(uint64(id))
+		u(uint64(g.hazards[id].remaining))
+	}
+
+	h := fnv.New32a()
+	h.Write(b)
+	return h.Sum32()
+}
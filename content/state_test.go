@@ -0,0 +1,151 @@
+package main
+
+import (
+	"avoid-the-enemies/content/config"
+	"testing"
+)
+
+const (
+	stateTestSeed   = 12345
+	stateTestFrames = 600 // 模拟的总帧数
+	stateTestFrom   = 400 // 回滚到的帧
+)
+
+// scriptedInput 玩家 i 在第 f 帧的操作，只取决于 i 和 f
+func scriptedInput(i, f int) Input {
+	in := Input{
+		X: float64((f/40+i)%3 - 1),
+		Y: float64((f/25+2*i)%3 - 1),
+	}
+	in.Fire = (f+i)%13 == 0
+	in.Dash = (f+3*i)%90 == 0
+	in.Skills[(f/120+i)%maxSkillSlots] = (f+i)%150 == 0
+	return in
+}
+
+// newStateTestGame 以固定的种子开始一局双人游戏，返回游戏和两名玩家的操作来源
+func newStateTestGame(t *testing.T) (*Game, []*frameInput) {
+	t.Helper()
+	g := &Game{profile: NewProfile(""), seed: stateTestSeed}
+	sources := []*frameInput{{}, {}}
+	for _, source := range sources {
+		g.seats = append(g.seats, Seat{input: source, character: characters[0]})
+	}
+	g.init()
+	g.mode = config.ModeGame
+	return g, sources
+}
+
+// step 按 input 给出的操作模拟第 f 帧
+func step(g *Game, sources []*frameInput, f int, input func(i, f int) Input) {
+	for i, source := range sources {
+		source.in = input(i, f)
+	}
+	if err := g.Update(); err != nil {
+		panic(err)
+	}
+}
+
+func TestStateDeterministic(t *testing.T) {
+	a, sourcesA := newStateTestGame(t)
+	b, sourcesB := newStateTestGame(t)
+	for f := 0; f < stateTestFrames; f++ {
+		step(a, sourcesA, f, scriptedInput)
+		step(b, sourcesB, f, scriptedInput)
+		if ca, cb := a.Checksum(), b.Checksum(); ca != cb {
+			t.Fatalf("frame %d: checksums %08x and %08x differ for the same seed and inputs", f, ca, cb)
+		}
+	}
+	if len(a.monsters) == 0 {
+		t.Error("no monsters after the scripted run, the test does not cover much")
+	}
+}
+
+func TestStateRollback(t *testing.T) {
+	// 按正确的操作一直模拟，记录每一帧之后的校验值
+	ref, sources := newStateTestGame(t)
+	want := make([]uint32, stateTestFrames)
+	for f := 0; f < stateTestFrames; f++ {
+		step(ref, sources, f, scriptedInput)
+		want[f] = ref.Checksum()
+	}
+
+	g, sources := newStateTestGame(t)
+	for f := 0; f < stateTestFrom; f++ {
+		step(g, sources, f, scriptedInput)
+	}
+	saved := g.SaveState()
+	savedChecksum := g.Checksum()
+
+	// 对方的操作没有到达时按预测模拟，第二名玩家一直停在原地
+	predicted := func(i, f int) Input {
+		if i == 1 {
+			return Input{}
+		}
+		return scriptedInput(i, f)
+	}
+	for f := stateTestFrom; f < stateTestFrames; f++ {
+		step(g, sources, f, predicted)
+	}
+	if g.Checksum() == want[stateTestFrames-1] {
+		t.Fatal("predicted inputs gave the same result, the rollback is not tested")
+	}
+
+	// 回滚到保存的帧，按正确的操作重新模拟，结果应该与一直按正确操作模拟的相同
+	g.LoadState(saved)
+	if c := g.Checksum(); c != savedChecksum {
+		t.Fatalf("checksum after LoadState = %08x, want %08x", c, savedChecksum)
+	}
+	g.resimulating = true
+	for f := stateTestFrom; f < stateTestFrames; f++ {
+		step(g, sources, f, scriptedInput)
+		if c := g.Checksum(); c != want[f] {
+			t.Fatalf("frame %d after rollback: checksum %08x, want %08x", f, c, want[f])
+		}
+	}
+}
+
+func TestStateLoadTwice(t *testing.T) {
+	g, sources := newStateTestGame(t)
+	for f := 0; f < stateTestFrom; f++ {
+		step(g, sources, f, scriptedInput)
+	}
+	saved := g.SaveState()
+
+	// 同一个状态恢复两次，第一次恢复后的模拟不能改变保存的状态
+	var first uint32
+	for n := 0; n < 2; n++ {
+		g.LoadState(saved)
+		for f := stateTestFrom; f < stateTestFrames; f++ {
+			step(g, sources, f, scriptedInput)
+		}
+		if n == 0 {
+			first = g.Checksum()
+		} else if c := g.Checksum(); c != first {
+			t.Errorf("second replay from the same state: checksum %08x, want %08x", c, first)
+		}
+	}
+}
+
+// TestChecksumStatuses 只有状态效果或护盾不同时校验值也不同
+func TestChecksumStatuses(t *testing.T) {
+	g, _ := newStateTestGame(t)
+	p := g.players[0]
+	seen := map[uint32]string{g.Checksum(): "initial"}
+	check := func(what string) {
+		t.Helper()
+		c := g.Checksum()
+		if prev, ok := seen[c]; ok {
+			t.Errorf("%s: checksum %08x is the same as after %s", what, c, prev)
+		}
+		seen[c] = what
+	}
+	p.ApplyStatus(StatusPoison)
+	check("poison")
+	p.ApplyStatus(StatusPoison)
+	check("second poison stack")
+	p.statuses[StatusPoison].remaining--
+	check("poison tick")
+	p.shield = 10
+	check("shield")
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"image/color"
+
+	"github.com/hajimehoshi/ebiten/v2"
+)
+
+type StatusKind int
+
+const (
+	StatusNone   StatusKind = iota
+	StatusPoison            // 中毒：持续掉血，可叠加层数
+	StatusSlow              // 减速：移动速度降低
+	StatusBurn              // 灼烧：持续掉血
+	StatusStun              // 眩晕：无法移动和攻击
+)
+
+// StackPolicy 状态效果重复施加时的处理方式
+type StackPolicy int
+
+const (
+	StackRefresh   StackPolicy = iota // 重置持续时间
+	StackIntensity                    // 叠加层数并重置持续时间
+	StackIgnore                       // 效果结束前不能再次施加
+)
+
+type statusDef struct {
+	frames     int         // 持续的帧数
+	policy     StackPolicy // 重复施加时的处理方式
+	maxStacks  int         // 最大层数
+	damage     float64     // 每层每秒造成的伤害
+	speedScale float64     // 移动速度倍率
+	tint       color.RGBA  // 受到效果影响时人物的颜色
+}
+
+var statusDefs = map[StatusKind]statusDef{
+	StatusPoison: {frames: 180, policy: StackIntensity, maxStacks: 5, damage: 3, speedScale: 1, tint: color.RGBA{0x60, 0xFF, 0x60, 0xFF}},
+	StatusSlow:   {frames: 120, policy: StackRefresh, maxStacks: 1, speedScale: 0.5, tint: color.RGBA{0x80, 0xA0, 0xFF, 0xFF}},
+	StatusBurn:   {frames: 60, policy: StackRefresh, maxStacks: 1, damage: 8, speedScale: 1, tint: color.RGBA{0xFF, 0x90, 0x40, 0xFF}},
+	StatusStun:   {frames: 45, policy: StackIgnore, maxStacks: 1, speedScale: 0, tint: color.RGBA{0xFF, 0xFF, 0x60, 0xFF}},
+}
+
+// Status 人物身上生效中的状态效果
+type Status struct {
+	kind      StatusKind
+	remaining int // 剩余的帧数
+	stacks    int // 层数
+}
+
+// ApplyStatus 为人物施加状态效果
+func (p *Player) ApplyStatus(kind StatusKind) {
+	def, ok := statusDefs[kind]
+	if !ok {
+		return
+	}
+	if p.statuses == nil {
+		p.statuses = make(map[StatusKind]*Status)
+	}
+	status, ok := p.statuses[kind]
+	if !ok {
+		p.statuses[kind] = &Status{kind: kind, remaining: def.frames, stacks: 1}
+		return
+	}
+	switch def.policy {
+	case StackRefresh:
+		status.remaining = def.frames
+	case StackIntensity:
+		status.remaining = def.frames
+		if status.stacks < def.maxStacks {
+			status.stacks++
+		}
+	case StackIgnore:
+	}
+}
+
+// UpdateStatus 推进所有状态效果，返回本帧状态效果造成的伤害
+func (p *Player) UpdateStatus() float64 {
+	damage := 0.0
+	for _, kind := range sortedKeys(p.statuses) {
+		status := p.statuses[kind]
+		damage += statusDefs[kind].damage * float64(status.stacks) / 60
+		status.remaining--
+		if status.remaining <= 0 {
+			delete(p.statuses, kind)
+		}
+	}
+	return damage
+}
+
+// HasStatus 人物是否受到某个状态效果的影响
+func (p *Player) HasStatus(kind StatusKind) bool {
+	_, ok := p.statuses[kind]
+	return ok
+}
+
+// Stunned 是否处于眩晕状态
+func (p *Player) Stunned() bool {
+	return p.HasStatus(StatusStun)
+}
+
+// Speed 考虑状态效果和加速道具之后的移动速度
+func (p *Player) Speed() float64 {
+	speed := p.speed
+	if p.clock.Now().Before(p.speedBoostUntil) {
+		speed *= pickupDefs[PickupSpeed].value
+	}
+	for _, kind := range sortedKeys(p.statuses) {
+		speed *= statusDefs[kind].speedScale
+	}
+	return speed
+}
+
+// TopStatus 优先级最高的状态效果，没有状态效果时返回 StatusNone
+func (p *Player) TopStatus() StatusKind {
+	for _, kind := range []StatusKind{StatusStun, StatusBurn, StatusPoison, StatusSlow} {
+		if p.HasStatus(kind) {
+			return kind
+		}
+	}
+	return StatusNone
+}
+
+// StatusTint 按状态效果为人物的绘制着色，多个效果时取优先级最高的效果
+func (p *Player) StatusTint(op *ebiten.DrawImageOptions) {
+	if kind := p.TopStatus(); kind != StatusNone {
+		op.ColorScale.ScaleWithColor(statusDefs[kind].tint)
+	}
+}
+
+// updateStatuses 推进玩家与怪物身上的状态效果，并结算持续伤害
+func (g *Game) updateStatuses() {
+	for _, p := range g.livingPlayers() {
+		if damage := p.UpdateStatus(); damage > 0 && !p.Invincible() {
+			g.damage(p, damage, DamageStatus)
+		}
+	}
+	for _, id := range sortedKeys(g.monsters) {
+		monster := g.monsters[id]
+		if damage := monster.UpdateStatus(); damage > 0 {
+			g.damage(monster, damage, DamageStatus)
+		}
+	}
+}
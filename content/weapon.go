@@ -2,19 +2,14 @@ package main
 
 import (
 	"avoid-the-enemies/content/config"
-	"bytes"
 	"image/color"
 	"math"
-	"math/rand"
+	"slices"
 	"time"
 
 	"github.com/hajimehoshi/ebiten/v2"
-	"github.com/hajimehoshi/ebiten/v2/audio"
-	"github.com/hajimehoshi/ebiten/v2/audio/mp3"
 	"github.com/hajimehoshi/ebiten/v2/ebitenutil"
 	"golang.org/x/image/math/f64"
-
-	raudio "avoid-the-enemies/resources/audio"
 )
 
 var (
@@ -22,73 +17,103 @@ var (
 )
 
 func InitWeapon() {
-	s, err := mp3.DecodeWithoutResampling(bytes.NewReader(raudio.Shotgun_mp3))
-	if err != nil {
-		return
-	}
-	if audioContext == nil {
-		audioContext = audio.NewContext(48000)
-	}
-	p, err := audioContext.NewPlayer(s)
-	if err != nil {
-		return
-	}
 	weaponList = append(weaponList,
 		&MeleeWeapon{
-			Type:  "sickle",
-			Image: sickleImage,
-			spin:  1.75 * math.Pi / 60, // 每帧转动的角度（弧度）
-			angle: 0,
+			Type:   "sickle",
+			Image:  sickleImage,
+			spin:   1.75 * math.Pi / 60, // 每帧转动的角度（弧度）
+			angle:  0,
+			effect: StatusPoison,
 		},
 		&MeleeWeapon{
-			Type:  "sword",
-			Image: swordImage,
-			spin:  2 * math.Pi / 60, // 每帧转动的角度（弧度）
-			angle: 0,
+			Type:   "sword",
+			Image:  swordImage,
+			spin:   2 * math.Pi / 60, // 每帧转动的角度（弧度）
+			angle:  0,
+			effect: StatusStun,
 		},
 		&RangedWeapon{
-			Type:       "ak",
-			Image:      akImage,
-			bullet:     bulletImage,
-			speed:      0.5,
-			distance:   config.ScreenWidth,
-			damage:     25,
-			shotPlayer: p,
+			Type:     "ak",
+			Image:    akImage,
+			bullet:   bulletImage,
+			speed:    0.5,
+			distance: config.ScreenWidth,
+			damage:   25,
+			effect:   StatusSlow,
+			shot:     shotSound,
 		},
 	)
 }
 
+// NewWeapon 根据武器类型创建一把新的武器，类型不存在时返回 nil
+func NewWeapon(typ string) Weapon {
+	for _, weapon := range weaponList {
+		switch weapon := weapon.(type) {
+		case *MeleeWeapon:
+			if weapon.Type == typ {
+				return weapon.Copy()
+			}
+		case *RangedWeapon:
+			if weapon.Type == typ {
+				return weapon.Copy()
+			}
+		}
+	}
+	return nil
+}
+
 type Weapon interface {
-	GetImage() *ebiten.Image
+	GetType() string
+	GetImage() *Region
+	ApplyModifiers(m Modifiers) // 应用升级获得的属性加成
+	Clone() Weapon              // 复制武器的全部状态，用于保存游戏状态
 }
 
 type MeleeWeapon struct {
-	Type  string        // 武器类型
-	Image *ebiten.Image // 加载武器的图片
-	angle float64       // 武器的旋转角度
-	spin  float64       // 武器的旋转速度
-	Trail []f64.Vec2    // 武器的轨迹
+	Type      string     // 武器类型
+	Image     *Region    // 加载武器的图片
+	angle     float64    // 武器的旋转角度
+	spin      float64    // 武器的旋转速度
+	Trail     []f64.Vec2 // 武器的轨迹
+	effect    StatusKind // 击中玩家时施加的状态效果，怪物被玩家的武器击中时直接死亡，不受武器的状态效果影响
+	spinBonus float64    // 旋转速度加成
+}
+
+func (w *MeleeWeapon) GetType() string {
+	return w.Type
 }
 
-func (w *MeleeWeapon) GetImage() *ebiten.Image {
+func (w *MeleeWeapon) GetImage() *Region {
 	return w.Image
 }
 
+func (w *MeleeWeapon) ApplyModifiers(m Modifiers) {
+	w.spinBonus = m.spinBonus
+}
+
 func (w *MeleeWeapon) Spin() {
-	w.angle += w.spin
+	w.angle += w.spin * (1 + w.spinBonus)
 	w.angle = math.Mod(w.angle, 2*math.Pi)
 }
 
 func (w *MeleeWeapon) Copy() *MeleeWeapon {
 	return &MeleeWeapon{
-		Type:  w.Type,
-		Image: w.Image,
-		angle: w.angle,
-		spin:  w.spin,
-		Trail: w.Trail,
+		Type:      w.Type,
+		Image:     w.Image,
+		angle:     w.angle,
+		spin:      w.spin,
+		Trail:     w.Trail,
+		effect:    w.effect,
+		spinBonus: w.spinBonus,
 	}
 }
 
+func (w *MeleeWeapon) Clone() Weapon {
+	c := *w
+	c.Trail = slices.Clone(w.Trail)
+	return &c
+}
+
 // DrawTrail 在绘制时，绘制轨迹效果
 func (w *MeleeWeapon) DrawTrail(screen *ebiten.Image) {
 	// 绘制轨迹效果
@@ -103,32 +128,49 @@ func (w *MeleeWeapon) DrawTrail(screen *ebiten.Image) {
 }
 
 type RangedWeapon struct {
-	Type         string        // 武器类型
-	Image        *ebiten.Image // 加载武器的图片
-	bullet       *ebiten.Image // 子弹图片
-	speed        float64       // 子弹的速度
-	distance     float64       // 子弹的射程
-	damage       float64       // 子弹的伤害值
-	LastFireTime time.Time     // 上次开火的时间
-	shotPlayer   *audio.Player // 射击音效
+	Type         string     // 武器类型
+	Image        *Region    // 加载武器的图片
+	bullet       *Region    // 子弹图片
+	speed        float64    // 子弹的速度
+	distance     float64    // 子弹的射程
+	damage       float64    // 子弹的伤害值
+	LastFireTime time.Time  // 上次开火的时间
+	effect       StatusKind // 子弹击中玩家时施加的状态效果，与近战武器相同只作用于玩家
+	pierce       int        // 子弹可以穿透的敌人数量
+	shot         *Sound     // 射击音效
+}
+
+func (w *RangedWeapon) GetType() string {
+	return w.Type
 }
 
-func (w *RangedWeapon) GetImage() *ebiten.Image {
+func (w *RangedWeapon) GetImage() *Region {
 	return w.Image
 }
 
+func (w *RangedWeapon) ApplyModifiers(m Modifiers) {
+	w.pierce = m.pierce
+}
+
 func (w *RangedWeapon) Copy() *RangedWeapon {
 	return &RangedWeapon{
-		Type:       w.Type,
-		Image:      w.Image,
-		bullet:     w.bullet,
-		speed:      w.speed,
-		distance:   w.distance,
-		damage:     w.damage,
-		shotPlayer: w.shotPlayer,
+		Type:     w.Type,
+		Image:    w.Image,
+		bullet:   w.bullet,
+		speed:    w.speed,
+		distance: w.distance,
+		damage:   w.damage,
+		effect:   w.effect,
+		pierce:   w.pierce,
+		shot:     w.shot,
 	}
 }
 
+func (w *RangedWeapon) Clone() Weapon {
+	c := *w
+	return &c
+}
+
 type FireOption func(s *Suspend)
 
 func WithBulletDirection(x, y float64) FireOption {
@@ -141,10 +183,11 @@ func WithBulletDirection(x, y float64) FireOption {
 }
 
 func (w *RangedWeapon) Fire(g *Game, player *Player, options ...FireOption) {
-	if err := w.shotPlayer.Rewind(); err != nil {
+	// 在开火的人物处播放射击音效，远处的怪物开火时声音更小并偏向所在的一侧
+	if err := g.playSound(w.shot, player.x+config.FrameWidth/2, player.y+config.FrameHeight/2); err != nil {
 		return
 	}
-	w.shotPlayer.Play()
+	w.LastFireTime = g.clock.Now()
 	// 每次开火生成一颗子弹，移动的距离为 distance 速度为 speed 图片为 bullet
 	x, y := player.x+player.weaponX, player.y+player.weaponY
 	weapon := player.weapon.(*RangedWeapon)
@@ -155,11 +198,18 @@ func (w *RangedWeapon) Fire(g *Game, player *Player, options ...FireOption) {
 		from:        f64.Vec2{x, y},
 		directIndex: player.directIdx,
 		PlayerID:    player.id,
+		team:        player.team,
+		pierce:      weapon.pierce,
 	}
 
 	for _, option := range options {
 		option(bullet)
 	}
+	angle := directions[bullet.directIndex].spin
+	if bullet.direction != nil {
+		angle = math.Atan2(bullet.direction.y, bullet.direction.x)
+	}
+	g.emit(&muzzleFlash, x, y, angle)
 
 	g.uniqueId++
 	g.suspends[g.uniqueId] = bullet
@@ -172,29 +222,38 @@ type Suspend struct {
 	directIndex int               // 子弹的方向
 	time        int               // 子弹的生命周期
 	PlayerID    int               // 子弹的拥有者
+	team        Team              // 拥有者的阵营
 	direction   *SuspendDirection // 子弹运动的方向向量
+	pierce      int               // 剩余可以穿透的敌人数量
 }
 
 type SuspendDirection struct {
 	x, y float64
 }
 
+// RandomSpawnPosition 地图上随机的刷新位置
+func RandomSpawnPosition(rng *Rand) f64.Vec2 {
+	return f64.Vec2{rng.Float64() * (config.ScreenWidth - config.FrameWidth/2), rng.Float64() * (config.ScreenHeight - config.FrameHeight/2)}
+}
+
 func GenerateWeapon(g *Game) {
-	if time.Since(g.weaponTimer) > time.Second*5 {
-		g.weaponTimer = time.Now()
+	if g.clock.Since(g.weaponTimer) > time.Second*5 {
+		g.weaponTimer = g.clock.Now()
 		if len(g.weapons) < 2 {
 			g.uniqueId++
-			weapon := weaponList[rand.Intn(len(weaponList))]
+			weapon := weaponList[g.rng.Intn(len(weaponList))]
 			switch weapon.(type) {
 			case *MeleeWeapon:
 				newWeapon := weapon.(*MeleeWeapon).Copy()
 				// 使用指针类型有拷贝的bug，当两个人获得同一把武器的时候，旋转会画两次，所以看起来快了一倍
+				g.tuneWeapon(newWeapon)
 				g.weapons[g.uniqueId] = newWeapon
-				g.weaponPosition[g.uniqueId] = f64.Vec2{rand.Float64() * (config.ScreenWidth - config.FrameWidth/2), rand.Float64() * (config.ScreenHeight - config.FrameHeight/2)}
+				g.weaponPosition[g.uniqueId] = RandomSpawnPosition(&g.rng)
 			case *RangedWeapon:
 				newWeapon := weapon.(*RangedWeapon).Copy()
+				g.tuneWeapon(newWeapon)
 				g.weapons[g.uniqueId] = newWeapon
-				g.weaponPosition[g.uniqueId] = f64.Vec2{rand.Float64() * (config.ScreenWidth - config.FrameWidth/2), rand.Float64() * (config.ScreenHeight - config.FrameHeight/2)}
+				g.weaponPosition[g.uniqueId] = RandomSpawnPosition(&g.rng)
 			}
 		}
 	}
@@ -202,15 +261,23 @@ func GenerateWeapon(g *Game) {
 
 // SuspendMove 更新所有远程武器的发射产物位置
 func SuspendMove(g *Game) {
-	for id, s := range g.suspends {
+	for _, id := range sortedKeys(g.suspends) {
+		s := g.suspends[id]
 		s.time++
 
+		// 时间减缓只对怪物的子弹生效
+		owner := g.playerByID(s.PlayerID)
+		scale := 1.0
+		if s.team == TeamNeutral {
+			scale = g.timeScale
+		}
+
 		if s.direction != nil {
-			s.pos[0] += s.direction.x * s.rangeWeapon.speed * float64(s.time)
-			s.pos[1] += s.direction.y * s.rangeWeapon.speed * float64(s.time)
+			s.pos[0] += s.direction.x * s.rangeWeapon.speed * float64(s.time) * scale
+			s.pos[1] += s.direction.y * s.rangeWeapon.speed * float64(s.time) * scale
 		} else {
-			s.pos[0] += directions[s.directIndex].dx * s.rangeWeapon.speed * float64(s.time)
-			s.pos[1] += directions[s.directIndex].dy * s.rangeWeapon.speed * float64(s.time)
+			s.pos[0] += directions[s.directIndex].dx * s.rangeWeapon.speed * float64(s.time) * scale
+			s.pos[1] += directions[s.directIndex].dy * s.rangeWeapon.speed * float64(s.time) * scale
 		}
 
 		// 如果子弹超出射程，删除子弹
@@ -218,21 +285,32 @@ func SuspendMove(g *Game) {
 			delete(g.suspends, id)
 			continue
 		}
-		// 如果子弹碰撞到怪物，怪物消失
-		for _, m := range g.monsters {
-			if m.id != s.PlayerID && IsTouch(s.pos[0], s.pos[1], m.x+config.FrameWidth/2, m.y+config.FrameHeight/2) {
+		// 如果子弹碰撞到怪物，怪物消失，子弹的状态效果只作用于玩家
+		for _, monsterID := range sortedKeys(g.monsters) {
+			m := g.monsters[monsterID]
+			if g.canHit(s, m) && IsTouch(s.pos[0], s.pos[1], m.x+config.FrameWidth/2, m.y+config.FrameHeight/2) {
+				g.emit(&impactSparks, s.pos[0], s.pos[1], 0)
+				g.killMonster(m.id, owner)
+				// 子弹还可以穿透时继续飞行
+				if s.pierce > 0 {
+					s.pierce--
+					continue
+				}
 				delete(g.suspends, id)
-				delete(g.monsters, m.id)
-				g.player.score++
 				break
 			}
 		}
-		// 如果子弹碰撞到玩家，且玩家不是无敌状态，玩家减血
-		if s.PlayerID != g.player.id && !g.player.Invincible() && IsTouch(s.pos[0], s.pos[1], g.player.x+config.FrameWidth/2, g.player.y+config.FrameHeight/2) {
-			g.player.health -= s.rangeWeapon.damage
-			delete(g.suspends, id)
-			if g.player.health <= 0 {
-				g.mode = config.ModeGameOver
+		// 如果子弹碰撞到敌对的玩家，且玩家不是无敌状态，玩家减血并受到子弹的状态效果
+		if _, ok := g.suspends[id]; !ok {
+			continue
+		}
+		for _, p := range g.livingPlayers() {
+			if g.canHit(s, p) && !p.Invincible() && IsTouch(s.pos[0], s.pos[1], p.x+config.FrameWidth/2, p.y+config.FrameHeight/2) {
+				delete(g.suspends, id)
+				g.emit(&impactSparks, s.pos[0], s.pos[1], 0)
+				p.ApplyStatus(s.rangeWeapon.effect)
+				g.damage(p, s.rangeWeapon.damage, s.rangeWeapon.Type)
+				break
 			}
 		}
 	}
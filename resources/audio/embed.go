@@ -15,22 +15,13 @@
 package audio
 
 import (
-	_ "embed"
+	"embed"
 )
 
-var (
-	//go:embed jab.wav
-	Jab_wav []byte
+// 游戏中的音乐按频段分成的各层，由完整的 ragtime.mp3 生成（ragtime.mp3 不嵌入）：go generate ./resources/audio
+//go:generate go run ../../tools/stems -in ragtime.mp3 -stem low=lowpass:1200:8000 -stem high=highpass:1200:16000 -stem bass=lowpass:150:4000
 
-	//go:embed jump.ogg
-	Jump_ogg []byte
-
-	//go:embed ragtime.mp3
-	Ragtime_mp3 []byte
-
-	//go:embed ragtime.ogg
-	Ragtime_ogg []byte
-
-	//go:embed shot.mp3
-	Shotgun_mp3 []byte
-)
+// FS 嵌入的默认音效和音乐，资源包中的同名文件会覆盖这里的文件
+//
+//go:embed jab.wav jump.ogg ragtime.ogg ragtime_low.wav ragtime_high.wav ragtime_bass.wav shot.mp3
+var FS embed.FS
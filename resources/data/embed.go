@@ -0,0 +1,10 @@
+package data
+
+import (
+	"embed"
+)
+
+// FS 嵌入的默认数据文件，资源包中的同名文件会覆盖这里的文件
+//
+//go:embed characters.json music.json
+var FS embed.FS
@@ -15,13 +15,12 @@
 package fonts
 
 import (
-	_ "embed"
+	"embed"
 )
 
-var (
-	//go:embed mplus-1p-regular.ttf
-	MPlus1pRegular_ttf []byte
+//go:generate go run ../../tools/cjkfont -bdf cjk.bdf -locale ../locales/zh.json -out cjk.ttf
 
-	//go:embed pressstart2p.ttf
-	PressStart2P_ttf []byte
-)
+// FS 嵌入的默认字体，资源包中的同名文件会覆盖这里的文件
+//
+//go:embed cjk.ttf mplus-1p-regular.ttf pressstart2p.ttf
+var FS embed.FS
@@ -15,28 +15,15 @@
 package images
 
 import (
-	_ "embed"
+	"embed"
 )
 
-var (
-	//go:embed runner.png
-	Runner_png []byte
+// 修改或者添加图片后重新生成图集：go generate ./resources/images。
+// 各角色的精灵图由跑者的精灵图换色生成，修改跑者的精灵图后会一起重新生成
+//go:generate go run ../../tools/recolor -dir . -src runner
+//go:generate go run ../../tools/atlas -dir . -split skill=48
 
-	//go:embed sickle.png
-	Sickle_png []byte
-
-	//go:embed sword.png
-	Sword_png []byte
-
-	//go:embed ak.png
-	AK_png []byte
-
-	//go:embed bullet.png
-	Bullet_png []byte
-
-	//go:embed skill.png
-	Skill_png []byte
-
-	//go:embed fire.png
-	Fire_png []byte
-)
+// FS 嵌入的默认图集和精灵图描述（原图只用于生成图集，不嵌入），资源包中的同名文件会覆盖这里的文件
+//
+//go:embed atlas.png atlas.json runner.json knight.json reaper.json gunner.json scout.json
+var FS embed.FS
@@ -0,0 +1,10 @@
+package locales
+
+import (
+	"embed"
+)
+
+// FS 嵌入的语言文件，每个文件是一种语言的字符串表，资源包中的同名文件会覆盖这里的文件
+//
+//go:embed *.json
+var FS embed.FS
@@ -0,0 +1,10 @@
+package shaders
+
+import (
+	"embed"
+)
+
+// FS 嵌入的默认着色器，资源包中的同名文件会覆盖这里的文件
+//
+//go:embed *.kage
+var FS embed.FS
@@ -0,0 +1,108 @@
+// atlas 将一个目录中的所有 PNG 图片打包成一张图集，并输出每张图片在图集中的位置。
+// 超过最大宽度的横条动画按帧宽度切成几段分别摆放，描述文件记录每一段在原图中的区域，
+// 用法：go run ./tools/atlas [-dir resources/images] [-out atlas] [-padding 1] [-max-width 1024] [-split skill=48]
+package main
+
+import (
+	"avoid-the-enemies/content/atlas"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"image/png"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// splitFlag 形如 skill=48 的参数，指定横条动画的帧宽度
+type splitFlag map[string]int
+
+func (f splitFlag) String() string {
+	var parts []string
+	for name, w := range f {
+		parts = append(parts, name+"="+strconv.Itoa(w))
+	}
+	return strings.Join(parts, ",")
+}
+
+func (f splitFlag) Set(s string) error {
+	for _, part := range strings.Split(s, ",") {
+		name, w, ok := strings.Cut(part, "=")
+		width, err := strconv.Atoi(w)
+		if !ok || err != nil || width <= 0 {
+			return fmt.Errorf("want name=frame-width, got %q", part)
+		}
+		f[name] = width
+	}
+	return nil
+}
+
+func main() {
+	dir := flag.String("dir", "resources/images", "directory of the source images")
+	out := flag.String("out", "atlas", "base name of the atlas image and description written into dir")
+	padding := flag.Int("padding", atlas.DefaultOptions.Padding, "transparent pixels around every piece")
+	maxWidth := flag.Int("max-width", atlas.DefaultOptions.MaxWidth, "max width of the atlas")
+	split := splitFlag{}
+	flag.Var(split, "split", "frame width of strips wider than max-width, as name=width, repeatable")
+	flag.Parse()
+
+	sources, err := load(*dir, *out, split)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if len(sources) == 0 {
+		log.Fatalf("no images in %s", *dir)
+	}
+	dst, a, err := atlas.Pack(sources, atlas.Options{Padding: *padding, MaxWidth: *maxWidth})
+	if err != nil {
+		log.Fatal(err)
+	}
+	a.Image = *out + ".png"
+
+	f, err := os.Create(filepath.Join(*dir, *out+".png"))
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := png.Encode(f, dst); err != nil {
+		log.Fatal(err)
+	}
+	if err := f.Close(); err != nil {
+		log.Fatal(err)
+	}
+	data, err := json.MarshalIndent(a, "", "  ")
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(*dir, *out+".json"), append(data, '\n'), 0o644); err != nil {
+		log.Fatal(err)
+	}
+	fmt.Printf("packed %d images into %s.png (%dx%d)\n", len(sources), *out, a.Width, a.Height)
+}
+
+// load 读取目录中除图集本身以外的所有 PNG 图片
+func load(dir, out string, split splitFlag) ([]atlas.Source, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.png"))
+	if err != nil {
+		return nil, err
+	}
+	var sources []atlas.Source
+	for _, path := range paths {
+		name := strings.TrimSuffix(filepath.Base(path), ".png")
+		if name == out {
+			continue
+		}
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		img, err := png.Decode(f)
+		f.Close()
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		sources = append(sources, atlas.Source{Name: name, Image: img, Split: split[name]})
+	}
+	return sources, nil
+}
@@ -0,0 +1,493 @@
+// cjkfont 把 BDF 格式的点阵字体转换为 TrueType 字体，每个点转换为一个正方形，字号为点阵的高度时与点阵完全对齐。
+// 指定语言文件时只保留语言文件中用到的字符，点阵字体中缺少的非 ASCII 字符会列出来，游戏中由语言的下一个字体显示，
+// 用法：go run ./tools/cjkfont -bdf resources/fonts/cjk.bdf -out resources/fonts/cjk.ttf [-locale resources/locales/zh.json ...]
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf16"
+)
+
+// unitsPerPixel 每个点在字体中的大小，字体的 em 为点阵的像素大小（SIZE）个点
+const unitsPerPixel = 100
+
+// glyph 点阵字体中的一个字符，rows 从上到下，每一行的最高位为最左侧的点
+type glyph struct {
+	r                      rune
+	advance                int // 像素
+	w, h, xOff, yOff       int // BBX，yOff 为最下面一行相对于基线的位置
+	rows                   []uint64
+	xMin, yMin, xMax, yMax int    // 轮廓的范围，字体单位
+	numContours, numPoints int    // 轮廓和点的数量
+	data                   []byte // glyf 表中的数据，没有点时为空
+}
+
+// bdfFont BDF 中用到的字段
+type bdfFont struct {
+	size            int // 像素
+	ascent, descent int // 像素
+	glyphs          []*glyph
+}
+
+// localeFlag 可以重复的 -locale 参数
+type localeFlag []string
+
+func (f *localeFlag) String() string { return strings.Join(*f, ",") }
+
+func (f *localeFlag) Set(v string) error {
+	*f = append(*f, v)
+	return nil
+}
+
+func main() {
+	in := flag.String("bdf", "", "BDF bitmap font to convert")
+	out := flag.String("out", "", "TrueType font to write")
+	family := flag.String("family", "Avoid the Enemies CJK", "font family name")
+	var locales localeFlag
+	flag.Var(&locales, "locale", "locale JSON file whose strings the font must cover, repeatable; keeps only the characters they use")
+	flag.Parse()
+	if *in == "" || *out == "" {
+		log.Fatal("give the BDF font with -bdf and the output with -out")
+	}
+
+	font, err := parseBDF(*in)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if len(locales) > 0 {
+		used, err := localeRunes(locales)
+		if err != nil {
+			log.Fatal(err)
+		}
+		var missing []rune
+		font.glyphs, missing = subset(font.glyphs, used)
+		if len(missing) > 0 {
+			log.Printf("%s: no glyphs for %d characters, they fall back to the next font: %s", *in, len(missing), string(missing))
+		}
+	}
+	if len(font.glyphs) == 0 {
+		log.Fatalf("%s: no glyphs", *in)
+	}
+	raw := encodeTTF(font, *family)
+	if err := os.WriteFile(*out, raw, 0o644); err != nil {
+		log.Fatal(err)
+	}
+	log.Printf("wrote %s: %d glyphs, %d bytes", *out, len(font.glyphs), len(raw))
+}
+
+// parseBDF 解析 BDF 字体，只支持每个字符都有 BBX 和 DWIDTH 的字体
+func parseBDF(file string) (*bdfFont, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	font := &bdfFont{}
+	var g *glyph
+	bitmap := false
+	sc := bufio.NewScanner(f)
+	for line := 1; sc.Scan(); line++ {
+		fields := strings.Fields(sc.Text())
+		if len(fields) == 0 {
+			continue
+		}
+		if bitmap && fields[0] != "ENDCHAR" {
+			v, err := strconv.ParseUint(fields[0], 16, 64)
+			if err != nil {
+				return nil, fmt.Errorf("%s:%d: %w", file, line, err)
+			}
+			g.rows = append(g.rows, v<<(64-4*len(fields[0])))
+			continue
+		}
+		ints, err := atois(fields[1:])
+		switch fields[0] {
+		case "SIZE":
+			if err == nil && len(ints) > 0 {
+				font.size = ints[0]
+			}
+		case "FONT_ASCENT":
+			if err == nil && len(ints) == 1 {
+				font.ascent = ints[0]
+			}
+		case "FONT_DESCENT":
+			if err == nil && len(ints) == 1 {
+				font.descent = ints[0]
+			}
+		case "STARTCHAR":
+			g = &glyph{r: -1}
+		case "ENCODING":
+			if err == nil && len(ints) > 0 {
+				g.r = rune(ints[0])
+			}
+		case "DWIDTH":
+			if err == nil && len(ints) == 2 {
+				g.advance = ints[0]
+			}
+		case "BBX":
+			if err != nil || len(ints) != 4 {
+				return nil, fmt.Errorf("%s:%d: invalid BBX", file, line)
+			}
+			g.w, g.h, g.xOff, g.yOff = ints[0], ints[1], ints[2], ints[3]
+		case "BITMAP":
+			bitmap = true
+		case "ENDCHAR":
+			bitmap = false
+			if g.r < 0 {
+				continue // 没有编码的字符
+			}
+			if len(g.rows) != g.h || g.w > 64 {
+				return nil, fmt.Errorf("%s:%d: %U has %d rows of %d, want %d", file, line, g.r, len(g.rows), g.w, g.h)
+			}
+			font.glyphs = append(font.glyphs, g)
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	if font.size <= 0 || font.ascent <= 0 {
+		return nil, fmt.Errorf("%s: missing SIZE or FONT_ASCENT", file)
+	}
+	slices.SortFunc(font.glyphs, func(a, b *glyph) int { return int(a.r - b.r) })
+	return font, nil
+}
+
+func atois(fields []string) ([]int, error) {
+	ints := make([]int, len(fields))
+	for i, s := range fields {
+		v, err := strconv.Atoi(s)
+		if err != nil {
+			return nil, err
+		}
+		ints[i] = v
+	}
+	return ints, nil
+}
+
+// localeRunes 语言文件的字符串中用到的非 ASCII 字符，不包括空白
+func localeRunes(files []string) (map[rune]bool, error) {
+	used := make(map[rune]bool)
+	for _, file := range files {
+		raw, err := os.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		var l struct {
+			Strings map[string]string `json:"strings"`
+		}
+		if err := json.Unmarshal(raw, &l); err != nil {
+			return nil, fmt.Errorf("%s: %w", file, err)
+		}
+		for _, s := range l.Strings {
+			for _, r := range s {
+				if r > unicode.MaxASCII && !unicode.IsSpace(r) {
+					used[r] = true
+				}
+			}
+		}
+	}
+	return used, nil
+}
+
+// subset 只保留 used 中的字符，同时返回点阵字体中缺少的 used 中的字符
+func subset(glyphs []*glyph, used map[rune]bool) (kept []*glyph, missing []rune) {
+	has := make(map[rune]bool)
+	for _, g := range glyphs {
+		if used[g.r] {
+			kept = append(kept, g)
+			has[g.r] = true
+		}
+	}
+	for r := range used {
+		if !has[r] {
+			missing = append(missing, r)
+		}
+	}
+	slices.Sort(missing)
+	return kept, missing
+}
+
+// outline 把点阵转换为 glyf 表中的简单字形，相邻行中左右相同的连续的点合并为一个矩形
+func (g *glyph) outline() {
+	type rect struct{ x0, x1, y0, y1 int } // 像素，y 向上，y0 < y1
+	var rects []rect
+	open := make(map[[2]int]int) // 上一行中的一段对应的矩形
+	for i, row := range g.rows {
+		y := g.yOff + g.h - 1 - i // 这一行的底边
+		next := make(map[[2]int]int)
+		for x := 0; x < g.w; {
+			if row>>(63-x)&1 == 0 {
+				x++
+				continue
+			}
+			start := x
+			for x < g.w && row>>(63-x)&1 != 0 {
+				x++
+			}
+			run := [2]int{start, x}
+			if j, ok := open[run]; ok {
+				rects[j].y0 = y
+				next[run] = j
+			} else {
+				next[run] = len(rects)
+				rects = append(rects, rect{g.xOff + start, g.xOff + x, y, y + 1})
+			}
+		}
+		open = next
+	}
+	if len(rects) == 0 {
+		return
+	}
+
+	g.xMin, g.yMin, g.xMax, g.yMax = rects[0].x0, rects[0].y0, rects[0].x1, rects[0].y1
+	for _, r := range rects {
+		g.xMin, g.yMin = min(g.xMin, r.x0), min(g.yMin, r.y0)
+		g.xMax, g.yMax = max(g.xMax, r.x1), max(g.yMax, r.y1)
+	}
+	g.xMin, g.yMin, g.xMax, g.yMax = g.xMin*unitsPerPixel, g.yMin*unitsPerPixel, g.xMax*unitsPerPixel, g.yMax*unitsPerPixel
+	g.numContours, g.numPoints = len(rects), 4*len(rects)
+
+	var b bytes.Buffer
+	put(&b, int16(len(rects)), int16(g.xMin), int16(g.yMin), int16(g.xMax), int16(g.yMax))
+	for i := range rects {
+		put(&b, uint16(4*i+3))
+	}
+	put(&b, uint16(0)) // 没有指令
+	for i := 0; i < g.numPoints; i++ {
+		b.WriteByte(0x01) // 曲线上的点，坐标为 16 位的差值
+	}
+	// TrueType 的外轮廓为顺时针：左下、左上、右上、右下
+	var xs, ys []int
+	for _, r := range rects {
+		xs = append(xs, r.x0, r.x0, r.x1, r.x1)
+		ys = append(ys, r.y0, r.y1, r.y1, r.y0)
+	}
+	for _, coords := range [][]int{xs, ys} {
+		prev := 0
+		for _, v := range coords {
+			put(&b, int16((v-prev)*unitsPerPixel))
+			prev = v
+		}
+	}
+	g.data = b.Bytes()
+}
+
+// encodeTTF 编码为只有 TrueType 轮廓的字体，第 0 个字形为空的 .notdef
+func encodeTTF(font *bdfFont, family string) []byte {
+	upem := font.size * unitsPerPixel
+	ascent, descent := font.ascent*unitsPerPixel, font.descent*unitsPerPixel
+	numGlyphs := len(font.glyphs) + 1
+
+	var glyf, loca, hmtx bytes.Buffer
+	put(&loca, uint32(0), uint32(0))   // .notdef 没有轮廓
+	put(&hmtx, uint16(upem), int16(0)) // .notdef
+	advanceMax, maxPoints, maxContours := upem, 0, 0
+	xMin, yMin, xMax, yMax := 0, 0, 0, 0
+	minRSB, maxExtent := 0, 0
+	empty := true
+	for _, g := range font.glyphs {
+		g.outline()
+		glyf.Write(g.data)
+		for glyf.Len()%4 != 0 {
+			glyf.WriteByte(0)
+		}
+		put(&loca, uint32(glyf.Len()))
+		advance := g.advance * unitsPerPixel
+		put(&hmtx, uint16(advance), int16(g.xMin))
+		advanceMax = max(advanceMax, advance)
+		maxPoints, maxContours = max(maxPoints, g.numPoints), max(maxContours, g.numContours)
+		if g.data == nil {
+			continue
+		}
+		if empty {
+			xMin, yMin, xMax, yMax = g.xMin, g.yMin, g.xMax, g.yMax
+			minRSB, maxExtent = advance-g.xMax, g.xMax
+			empty = false
+		}
+		xMin, yMin, xMax, yMax = min(xMin, g.xMin), min(yMin, g.yMin), max(xMax, g.xMax), max(yMax, g.yMax)
+		minRSB, maxExtent = min(minRSB, advance-g.xMax), max(maxExtent, g.xMax)
+	}
+
+	var head bytes.Buffer
+	put(&head, uint32(0x00010000), uint32(0x00010000), uint32(0), uint32(0x5F0F3CF5),
+		uint16(0x000B), uint16(upem), int64(0), int64(0), // 基线在 y=0，左边界在 x=0，整数的 ppem；不写创建时间，保证每次生成的文件相同
+		int16(xMin), int16(yMin), int16(xMax), int16(yMax),
+		uint16(0), uint16(font.size), int16(2), int16(1), int16(0))
+
+	var hhea bytes.Buffer
+	put(&hhea, uint32(0x00010000), int16(ascent), int16(-descent), int16(0), uint16(advanceMax),
+		int16(xMin), int16(minRSB), int16(maxExtent), int16(1), int16(0), int16(0),
+		[4]int16{}, int16(0), uint16(numGlyphs))
+
+	var maxp bytes.Buffer
+	put(&maxp, uint32(0x00010000), uint16(numGlyphs), uint16(maxPoints), uint16(maxContours),
+		uint16(0), uint16(0), uint16(2), [8]uint16{})
+
+	first, last := font.glyphs[0].r, font.glyphs[len(font.glyphs)-1].r
+	var os2 bytes.Buffer
+	put(&os2, uint16(4), int16(upem), uint16(400), uint16(5), uint16(0),
+		[10]int16{int16(upem / 2), int16(upem / 2), 0, int16(descent), int16(upem / 2), int16(upem / 2), 0, int16(ascent / 2), int16(unitsPerPixel), int16(ascent / 3)},
+		int16(0), [10]byte{},
+		uint32(0), uint32(1<<27), uint32(0), uint32(0), // CJK Unified Ideographs
+		[4]byte{'N', 'O', 'N', 'E'}, uint16(0x0040), // REGULAR
+		uint16(min(first, 0xFFFF)), uint16(min(last, 0xFFFF)),
+		int16(ascent), int16(-descent), int16(0), uint16(ascent), uint16(descent),
+		uint32(1<<18), uint32(0), // 简体中文（GBK）
+		int16(0), int16(0), uint16(0), uint16(' '), uint16(1))
+
+	var post bytes.Buffer
+	put(&post, uint32(0x00030000), uint32(0), int16(-unitsPerPixel), int16(unitsPerPixel), uint32(1), [4]uint32{})
+
+	tables := map[string][]byte{
+		"OS/2": os2.Bytes(),
+		"cmap": encodeCmap(font.glyphs),
+		"glyf": glyf.Bytes(),
+		"head": head.Bytes(),
+		"hhea": hhea.Bytes(),
+		"hmtx": hmtx.Bytes(),
+		"loca": loca.Bytes(),
+		"maxp": maxp.Bytes(),
+		"name": encodeName(family),
+		"post": post.Bytes(),
+	}
+	raw, offsets := encodeTables(tables)
+
+	// head 中的 checkSumAdjustment 使整个文件的校验和为 0xB1B0AFBA
+	binary.BigEndian.PutUint32(raw[offsets["head"]+8:], 0xB1B0AFBA-checksum(raw))
+	return raw
+}
+
+// encodeCmap 只有 Unicode BMP 的 format 4 子表，码位连续并且字形编号连续的字符合并为一段
+func encodeCmap(glyphs []*glyph) []byte {
+	type segment struct{ start, end, delta int }
+	var segments []segment
+	for i, g := range glyphs {
+		id := i + 1
+		r := int(g.r)
+		if r > 0xFFFE {
+			log.Fatalf("%U is outside the BMP", g.r)
+		}
+		if n := len(segments); n > 0 && segments[n-1].end == r-1 && segments[n-1].delta == id-r {
+			segments[n-1].end = r
+			continue
+		}
+		segments = append(segments, segment{r, r, id - r})
+	}
+	segments = append(segments, segment{0xFFFF, 0xFFFF, 1})
+
+	n := len(segments)
+	entrySelector := 0
+	for 2<<entrySelector <= n {
+		entrySelector++
+	}
+	searchRange := 2 << entrySelector
+
+	var sub bytes.Buffer
+	put(&sub, uint16(4), uint16(16+8*n), uint16(0), uint16(2*n), uint16(searchRange), uint16(entrySelector), uint16(2*n-searchRange))
+	for _, s := range segments {
+		put(&sub, uint16(s.end))
+	}
+	put(&sub, uint16(0))
+	for _, s := range segments {
+		put(&sub, uint16(s.start))
+	}
+	for _, s := range segments {
+		put(&sub, uint16(s.delta))
+	}
+	for range segments {
+		put(&sub, uint16(0))
+	}
+
+	// Unicode 平台和 Windows 平台共用同一个子表
+	var b bytes.Buffer
+	put(&b, uint16(0), uint16(2), uint16(0), uint16(3), uint32(20), uint16(3), uint16(1), uint32(20))
+	b.Write(sub.Bytes())
+	return b.Bytes()
+}
+
+// encodeName Windows 平台的英文名称
+func encodeName(family string) []byte {
+	postscript := strings.ReplaceAll(family, " ", "") + "-Regular"
+	names := []string{
+		0: "Glyphs from M+ Bitmap Fonts (c) 2002-2005 COZ and Baekmuk Gulim (c) 1986-2002 Kim Jeong-Hwan",
+		1: family,
+		2: "Regular",
+		3: postscript,
+		4: family + " Regular",
+		5: "Version 1.0",
+		6: postscript,
+	}
+	var records, strs bytes.Buffer
+	for id, s := range names {
+		u := utf16.Encode([]rune(s))
+		put(&records, uint16(3), uint16(1), uint16(0x0409), uint16(id), uint16(2*len(u)), uint16(strs.Len()))
+		put(&strs, u)
+	}
+	var b bytes.Buffer
+	put(&b, uint16(0), uint16(len(names)), uint16(6+records.Len()))
+	b.Write(records.Bytes())
+	b.Write(strs.Bytes())
+	return b.Bytes()
+}
+
+// encodeTables 按标签排序写出表目录和各个表，每个表按 4 字节对齐，同时返回每个表在文件中的位置
+func encodeTables(tables map[string][]byte) ([]byte, map[string]int) {
+	var tags []string
+	for tag := range tables {
+		tags = append(tags, tag)
+	}
+	slices.Sort(tags)
+
+	n := len(tags)
+	entrySelector := 0
+	for 2<<entrySelector <= n {
+		entrySelector++
+	}
+	searchRange := 16 << entrySelector
+
+	var dir, data bytes.Buffer
+	put(&dir, uint32(0x00010000), uint16(n), uint16(searchRange), uint16(entrySelector), uint16(16*n-searchRange))
+	offsets := make(map[string]int)
+	for _, tag := range tags {
+		t := tables[tag]
+		offsets[tag] = 12 + 16*n + data.Len()
+		dir.WriteString(tag)
+		put(&dir, checksum(t), uint32(offsets[tag]), uint32(len(t)))
+		data.Write(t)
+		for data.Len()%4 != 0 {
+			data.WriteByte(0)
+		}
+	}
+	return append(dir.Bytes(), data.Bytes()...), offsets
+}
+
+// checksum 按大端的 32 位整数求和，不足 4 字节的部分补 0
+func checksum(b []byte) uint32 {
+	var sum uint32
+	for i := 0; i < len(b); i += 4 {
+		var word [4]byte
+		copy(word[:], b[i:])
+		sum += binary.BigEndian.Uint32(word[:])
+	}
+	return sum
+}
+
+// put 按大端写入固定大小的值
+func put(b *bytes.Buffer, values ...any) {
+	for _, v := range values {
+		if err := binary.Write(b, binary.BigEndian, v); err != nil {
+			log.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,221 @@
+// recolor 从跑者的精灵图生成每个角色自己的精灵图和 Aseprite JSON：按调色板换色，
+// 并按每一帧中头带的位置区分头带以上（头盔、帽子、兜帽）和以下的部分，动画的帧和标签与原图相同，
+// 用法：go run ./tools/recolor [-dir resources/images] [-src runner]
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+)
+
+// 跑者精灵图中的颜色
+const (
+	shirt      = "e8e8e8"
+	shirtShade = "cccccc"
+	hair       = "8f563b"
+	hairShade  = "754731"
+	band       = "ac3232"
+	bandShade  = "912c2c"
+	skin       = "eec39a"
+	skinShade  = "c9a583"
+	skinLight  = "deb690"
+	legFront   = "616161"
+	legBack    = "696a6a"
+	shoeFront  = "3b3b3b"
+	shoeBack   = "474747"
+	eye        = "323c39"
+	mouth      = "d95763"
+)
+
+// look 一个角色的外观，head 只作用于头带以及头带以上的像素，优先于 colors
+type look struct {
+	name   string
+	colors map[string]string
+	head   map[string]string
+	crest  string // 头顶后方的羽饰，为空时没有
+}
+
+var looks = []look{
+	{
+		name: "knight",
+		colors: map[string]string{
+			shirt: "b8c4cc", shirtShade: "8796a2",
+			legFront: "3f4f7a", legBack: "35436a", shoeFront: "2a2f3a", shoeBack: "2a2f3a",
+			band: "4a5560", bandShade: "3a434c",
+		},
+		head:  map[string]string{hair: "9aa8b4", hairShade: "74828e"},
+		crest: "ac3232",
+	},
+	{
+		name: "reaper",
+		colors: map[string]string{
+			shirt: "5e3a78", shirtShade: "4a2d60",
+			legFront: "4a2d60", legBack: "3a2350", shoeFront: "1e1428", shoeBack: "1e1428",
+			hair: "3a1f4a", hairShade: "2a1836", band: "2a1836", bandShade: "2a1836",
+			skin: "d8d0e0", skinShade: "aaa0b8", skinLight: "c0b8cc",
+			eye: "e04070", mouth: "8a80a0",
+		},
+	},
+	{
+		name: "gunner",
+		colors: map[string]string{
+			shirt: "c8b48a", shirtShade: "a8966c",
+			legFront: "5b6e3a", legBack: "4f6032", shoeFront: "4a3424", shoeBack: "3e2c1e",
+			band: "46562c", bandShade: "3a4824",
+		},
+		head: map[string]string{hair: "5b6e3a", hairShade: "4f6032"},
+	},
+	{
+		name: "scout",
+		colors: map[string]string{
+			shirt: "6abe30", shirtShade: "4b8f22",
+			legFront: "8a6a44", legBack: "7a5c3a", shoeFront: "4a3424", shoeBack: "3e2c1e",
+			hair: "e8c860", hairShade: "c8a040", band: "f0f0f0", bandShade: "c8c8c8",
+		},
+	},
+}
+
+// asepriteFrames Aseprite JSON 中用到的帧区域
+type asepriteFrames struct {
+	Frames []struct {
+		Frame struct {
+			X, Y, W, H int
+		} `json:"frame"`
+	} `json:"frames"`
+}
+
+func main() {
+	dir := flag.String("dir", "resources/images", "directory of the sprite sheets")
+	src := flag.String("src", "runner", "base name of the sprite sheet to recolor")
+	flag.Parse()
+
+	f, err := os.Open(filepath.Join(*dir, *src+".png"))
+	if err != nil {
+		log.Fatal(err)
+	}
+	img, err := png.Decode(f)
+	f.Close()
+	if err != nil {
+		log.Fatal(err)
+	}
+	raw, err := os.ReadFile(filepath.Join(*dir, *src+".json"))
+	if err != nil {
+		log.Fatal(err)
+	}
+	var doc asepriteFrames
+	if err := json.Unmarshal(raw, &doc); err != nil {
+		log.Fatal(err)
+	}
+
+	for _, l := range looks {
+		dst, err := recolor(img, doc, l)
+		if err != nil {
+			log.Fatalf("%s: %v", l.name, err)
+		}
+		var buf bytes.Buffer
+		if err := png.Encode(&buf, dst); err != nil {
+			log.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(*dir, l.name+".png"), buf.Bytes(), 0o644); err != nil {
+			log.Fatal(err)
+		}
+		// 除了帧的文件名和图片名称以外与原来的描述相同
+		sheet := bytes.ReplaceAll(raw, []byte(`"`+*src+` `), []byte(`"`+l.name+` `))
+		sheet = bytes.ReplaceAll(sheet, []byte(`"`+*src+`.png"`), []byte(`"`+l.name+`.png"`))
+		if err := os.WriteFile(filepath.Join(*dir, l.name+".json"), sheet, 0o644); err != nil {
+			log.Fatal(err)
+		}
+	}
+}
+
+// recolor 逐帧换色，每一帧中头带最低的一行以上使用 head 中的颜色
+func recolor(img image.Image, doc asepriteFrames, l look) (*image.NRGBA, error) {
+	colors, err := parsePalette(l.colors)
+	if err != nil {
+		return nil, err
+	}
+	head, err := parsePalette(l.head)
+	if err != nil {
+		return nil, err
+	}
+	bandColors, _ := parsePalette(map[string]string{band: band, bandShade: bandShade})
+	hairColors, _ := parsePalette(map[string]string{hair: hair, hairShade: hairShade})
+
+	b := img.Bounds()
+	dst := image.NewNRGBA(b)
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			dst.Set(x, y, img.At(x, y))
+		}
+	}
+	for _, frame := range doc.Frames {
+		r := image.Rect(frame.Frame.X, frame.Frame.Y, frame.Frame.X+frame.Frame.W, frame.Frame.Y+frame.Frame.H)
+		bandBottom := r.Min.Y - 1
+		top, back := r.Max.Y, r.Max.X // 头发最高的一行以及这一行最左侧的像素
+		for y := r.Min.Y; y < r.Max.Y; y++ {
+			for x := r.Min.X; x < r.Max.X; x++ {
+				c := color.NRGBAModel.Convert(img.At(x, y)).(color.NRGBA)
+				if _, ok := bandColors[c]; ok {
+					bandBottom = y
+				}
+				if _, ok := hairColors[c]; ok && y < top {
+					top, back = y, x
+				}
+			}
+		}
+		for y := r.Min.Y; y < r.Max.Y; y++ {
+			for x := r.Min.X; x < r.Max.X; x++ {
+				c := color.NRGBAModel.Convert(img.At(x, y)).(color.NRGBA)
+				if to, ok := head[c]; ok && y <= bandBottom {
+					dst.SetNRGBA(x, y, to)
+				} else if to, ok := colors[c]; ok {
+					dst.SetNRGBA(x, y, to)
+				}
+			}
+		}
+		if l.crest != "" && top > r.Min.Y+1 {
+			crest, err := parseColor(l.crest)
+			if err != nil {
+				return nil, err
+			}
+			dst.SetNRGBA(back, top-1, crest)
+			dst.SetNRGBA(back+1, top-1, crest)
+			dst.SetNRGBA(back-1, top, crest)
+		}
+	}
+	return dst, nil
+}
+
+func parsePalette(m map[string]string) (map[color.NRGBA]color.NRGBA, error) {
+	palette := make(map[color.NRGBA]color.NRGBA)
+	for from, to := range m {
+		f, err := parseColor(from)
+		if err != nil {
+			return nil, err
+		}
+		t, err := parseColor(to)
+		if err != nil {
+			return nil, err
+		}
+		palette[f] = t
+	}
+	return palette, nil
+}
+
+// parseColor 解析 rrggbb 格式的不透明颜色
+func parseColor(s string) (color.NRGBA, error) {
+	v, err := strconv.ParseUint(s, 16, 32)
+	if err != nil || len(s) != 6 {
+		return color.NRGBA{}, fmt.Errorf("invalid color %q", s)
+	}
+	return color.NRGBA{R: uint8(v >> 16), G: uint8(v >> 8), B: uint8(v), A: 255}, nil
+}
@@ -0,0 +1,183 @@
+// stems 把一首完整的音乐按频段过滤成自适应音乐的各层，每一层输出为单声道 16 位的 WAV。
+// 每一层只保留过滤后的频段，因此可以使用较低的采样率，采样率必须整除原曲的采样率，保证各层的长度相同，
+// 用法：go run ./tools/stems -in resources/audio/ragtime.mp3 [-out resources/audio/ragtime] -stem low=lowpass:1200:8000 [-stem ...]
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/hajimehoshi/go-mp3"
+)
+
+// stem 输出的一层：名称、滤波器、截止频率以及输出的采样率
+type stem struct {
+	name   string
+	filter string // lowpass 或者 highpass
+	cutoff float64
+	rate   int
+}
+
+// stemFlag 形如 low=lowpass:1200:8000 的参数
+type stemFlag []stem
+
+func (f *stemFlag) String() string {
+	var parts []string
+	for _, s := range *f {
+		parts = append(parts, fmt.Sprintf("%s=%s:%g:%d", s.name, s.filter, s.cutoff, s.rate))
+	}
+	return strings.Join(parts, ",")
+}
+
+func (f *stemFlag) Set(v string) error {
+	name, spec, ok := strings.Cut(v, "=")
+	fields := strings.Split(spec, ":")
+	if !ok || len(fields) != 3 || (fields[0] != "lowpass" && fields[0] != "highpass") {
+		return fmt.Errorf("want name=lowpass|highpass:cutoff:rate, got %q", v)
+	}
+	cutoff, err := strconv.ParseFloat(fields[1], 64)
+	if err != nil || cutoff <= 0 {
+		return fmt.Errorf("invalid cutoff in %q", v)
+	}
+	rate, err := strconv.Atoi(fields[2])
+	if err != nil || rate <= 0 {
+		return fmt.Errorf("invalid sample rate in %q", v)
+	}
+	*f = append(*f, stem{name: name, filter: fields[0], cutoff: cutoff, rate: rate})
+	return nil
+}
+
+func main() {
+	in := flag.String("in", "", "MP3 file of the whole song")
+	out := flag.String("out", "", "prefix of the output files, a stem named low is written to <out>_low.wav; defaults to -in without extension")
+	var stems stemFlag
+	flag.Var(&stems, "stem", "stem to write, as name=lowpass|highpass:cutoff:rate, repeatable")
+	flag.Parse()
+	if *in == "" || len(stems) == 0 {
+		log.Fatal("give the input with -in and at least one -stem")
+	}
+	if *out == "" {
+		*out = strings.TrimSuffix(*in, ".mp3")
+	}
+
+	left, right, rate, err := decode(*in)
+	if err != nil {
+		log.Fatal(err)
+	}
+	for _, s := range stems {
+		if rate%s.rate != 0 {
+			log.Fatalf("stem %s: sample rate %d does not divide %d", s.name, s.rate, rate)
+		}
+		mono := make([]float64, len(left))
+		l, r := filter(left, s, rate), filter(right, s, rate)
+		for i := range mono {
+			mono[i] = (l[i] + r[i]) / 2
+		}
+		samples := decimate(mono, rate/s.rate)
+		path := *out + "_" + s.name + ".wav"
+		if err := os.WriteFile(path, encodeWAV(samples, s.rate), 0o644); err != nil {
+			log.Fatal(err)
+		}
+		log.Printf("wrote %s: %.1f seconds at %d Hz", path, float64(len(samples))/float64(s.rate), s.rate)
+	}
+}
+
+// decode 解码 MP3，返回两个声道的采样以及采样率
+func decode(file string) (left, right []float64, rate int, err error) {
+	raw, err := os.ReadFile(file)
+	if err != nil {
+		return nil, nil, 0, err
+	}
+	d, err := mp3.NewDecoder(bytes.NewReader(raw))
+	if err != nil {
+		return nil, nil, 0, fmt.Errorf("%s: %w", file, err)
+	}
+	pcm, err := io.ReadAll(d)
+	if err != nil {
+		return nil, nil, 0, fmt.Errorf("%s: %w", file, err)
+	}
+	n := len(pcm) / 4
+	left, right = make([]float64, n), make([]float64, n)
+	for i := 0; i < n; i++ {
+		left[i] = float64(int16(binary.LittleEndian.Uint16(pcm[4*i:])))
+		right[i] = float64(int16(binary.LittleEndian.Uint16(pcm[4*i+2:])))
+	}
+	return left, right, d.SampleRate(), nil
+}
+
+// filter 一阶低通滤波器，高通为原始信号减去低通的输出
+func filter(x []float64, s stem, rate int) []float64 {
+	alpha := 1 - math.Exp(-2*math.Pi*s.cutoff/float64(rate))
+	y := make([]float64, len(x))
+	lp := 0.0
+	for i, v := range x {
+		lp += alpha * (v - lp)
+		if s.filter == "lowpass" {
+			y[i] = lp
+		} else {
+			y[i] = v - lp
+		}
+	}
+	return y
+}
+
+// decimate 先用加窗的 sinc 滤掉新的采样率下会混叠的频率，再每 factor 个采样保留一个
+func decimate(x []float64, factor int) []float64 {
+	if factor == 1 {
+		return x
+	}
+	half := 16 * factor
+	cutoff := 0.45 / float64(factor) // 相对于原采样率的截止频率，略低于新的奈奎斯特频率
+	taps := make([]float64, 2*half+1)
+	sum := 0.0
+	for i := range taps {
+		t := float64(i - half)
+		v := 2 * cutoff
+		if t != 0 {
+			v = math.Sin(2*math.Pi*cutoff*t) / (math.Pi * t)
+		}
+		v *= 0.42 - 0.5*math.Cos(2*math.Pi*float64(i)/float64(2*half)) + 0.08*math.Cos(4*math.Pi*float64(i)/float64(2*half)) // Blackman 窗
+		taps[i] = v
+		sum += v
+	}
+	y := make([]float64, len(x)/factor)
+	for i := range y {
+		c := i * factor
+		acc := 0.0
+		for j, tap := range taps {
+			k := c + j - half
+			if k >= 0 && k < len(x) {
+				acc += tap * x[k]
+			}
+		}
+		y[i] = acc / sum
+	}
+	return y
+}
+
+// encodeWAV 编码为单声道 16 位的 WAV
+func encodeWAV(samples []float64, rate int) []byte {
+	var b bytes.Buffer
+	size := 2 * len(samples)
+	b.WriteString("RIFF")
+	binary.Write(&b, binary.LittleEndian, uint32(36+size))
+	b.WriteString("WAVEfmt ")
+	// fmt 块：PCM 格式、单声道、采样率、每秒字节数、每个采样的字节数、位深
+	for _, v := range []any{uint32(16), uint16(1), uint16(1), uint32(rate), uint32(2 * rate), uint16(2), uint16(16)} {
+		binary.Write(&b, binary.LittleEndian, v)
+	}
+	b.WriteString("data")
+	binary.Write(&b, binary.LittleEndian, uint32(size))
+	for _, v := range samples {
+		binary.Write(&b, binary.LittleEndian, int16(min(max(math.Round(v), math.MinInt16), math.MaxInt16)))
+	}
+	return b.Bytes()
+}